@@ -0,0 +1,213 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"sort"
+	"strconv"
+)
+
+// arrayReader is the interface that wraps environments that can hold
+// array variables.
+//
+// String expansion uses it to support `${arr[1]}`, `${arr[@]}` and friends.
+type arrayReader interface {
+	// lookupArray returns the array named by the key. It returns false
+	// if there is no such array.
+	lookupArray(key string) (arrayVar, bool)
+}
+
+// elementSetter is the interface that wraps environments that can change
+// a single element of an array.
+//
+// String expansion uses it to support `${arr[1]:=word}`.
+type elementSetter interface {
+	SetElement(key, index, value string) error
+}
+
+// arrayVar holds the elements of an indexed array, or an associative array
+type arrayVar struct {
+	// assoc is true for associative arrays
+	assoc bool
+
+	// elems holds the values, indexed by their key
+	//
+	// the keys of indexed arrays are always held in their canonical
+	// form (ie, "1", not "01")
+	elems map[string]string
+}
+
+// newIndexedArray creates an indexed array that holds the given values,
+// starting at index 0
+func newIndexedArray(values []string) arrayVar {
+	retval := arrayVar{
+		elems: make(map[string]string, len(values)),
+	}
+	for i, value := range values {
+		retval.elems[strconv.Itoa(i)] = value
+	}
+
+	return retval
+}
+
+// newAssocArray creates an associative array that holds a copy of the
+// given values
+func newAssocArray(values map[string]string) arrayVar {
+	retval := arrayVar{
+		assoc: true,
+		elems: make(map[string]string, len(values)),
+	}
+	for key, value := range values {
+		retval.elems[key] = value
+	}
+
+	return retval
+}
+
+// clone returns a copy of the array, that is safe to change
+func (a arrayVar) clone() arrayVar {
+	retval := arrayVar{
+		assoc: a.assoc,
+		elems: make(map[string]string, len(a.elems)),
+	}
+	for key, value := range a.elems {
+		retval.elems[key] = value
+	}
+
+	return retval
+}
+
+// equals returns true if both arrays hold the same elements
+func (a arrayVar) equals(other arrayVar) bool {
+	if a.assoc != other.assoc || len(a.elems) != len(other.elems) {
+		return false
+	}
+
+	for key, value := range a.elems {
+		otherValue, ok := other.elems[key]
+		if !ok || otherValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// get returns the value of the element at the given index
+func (a arrayVar) get(index string) (string, bool) {
+	index, ok := a.normaliseIndex(index)
+	if !ok {
+		return "", false
+	}
+
+	value, ok := a.elems[index]
+	return value, ok
+}
+
+// keys returns the keys of every element
+//
+// indexed arrays are returned in numerical order, and associative arrays
+// are returned in alphabetical order
+func (a arrayVar) keys() []string {
+	retval := make([]string, 0, len(a.elems))
+	for key := range a.elems {
+		retval = append(retval, key)
+	}
+
+	if a.assoc {
+		sort.Strings(retval)
+		return retval
+	}
+
+	sort.Slice(retval, func(i, j int) bool {
+		left, _ := strconv.Atoi(retval[i])
+		right, _ := strconv.Atoi(retval[j])
+		return left < right
+	})
+	return retval
+}
+
+// values returns the value of every element, in the same order as keys
+func (a arrayVar) values() []string {
+	keys := a.keys()
+	retval := make([]string, len(keys))
+	for i, key := range keys {
+		retval[i] = a.elems[key]
+	}
+
+	return retval
+}
+
+// normaliseIndex turns the given index into the key that we store
+// the element under
+//
+// for indexed arrays, negative indexes count back from the end of the
+// array, just like bash
+func (a arrayVar) normaliseIndex(index string) (string, bool) {
+	// associative arrays accept anything
+	if a.assoc {
+		return index, true
+	}
+
+	// indexed arrays need an integer
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return "", false
+	}
+
+	if i < 0 {
+		i += a.maxIndex() + 1
+		if i < 0 {
+			return "", false
+		}
+	}
+
+	return strconv.Itoa(i), true
+}
+
+// maxIndex returns the highest index in use in an indexed array, or
+// -1 if the array is empty
+func (a arrayVar) maxIndex() int {
+	retval := -1
+	for key := range a.elems {
+		i, _ := strconv.Atoi(key)
+		if i > retval {
+			retval = i
+		}
+	}
+
+	return retval
+}
@@ -0,0 +1,213 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Bind fills in the fields of the struct that v points to, using
+// values from the given environment.
+//
+// It uses these struct tags:
+//
+// * `env:"NAME"` is the variable to read the field's value from. Use
+// `env:"NAME,required"` if the variable must be set.
+//
+// * `default:"VALUE"` is used whenever the variable is not set
+//
+// * `sep:";"` is used to split the value into a slice. The default is ",".
+//
+// * `envPrefix:"PREFIX_"` goes on a nested struct (or pointer to a struct).
+// It is added to the front of every variable name inside that struct.
+//
+// Variables that are set to an empty string are treated as if they are
+// not set. Fields without an `env` tag are left alone.
+//
+// It supports strings, bools, all int, uint and float types,
+// time.Duration, url.URL, any type that implements
+// encoding.TextUnmarshaler, and slices and pointers of these types.
+// Bools can be written as 1/0, true/false, yes/no, or on/off.
+//
+// Bind keeps going when it finds a problem. It returns an ErrBind that
+// lists every problem that it found.
+func Bind(r Reader, v interface{}) error {
+	// do we have an environment to work with?
+	if r == nil {
+		return ErrNilPointer{"Bind"}
+	}
+
+	// do we have a struct to fill in?
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrInvalidBindTarget{reflect.TypeOf(v)}
+	}
+
+	// yes we do
+	b := binder{r: r, visiting: map[reflect.Type]bool{}}
+	b.bindStruct(rv.Elem(), "")
+	if len(b.errs) > 0 {
+		return ErrBind{b.errs}
+	}
+
+	// all done
+	return nil
+}
+
+// binder keeps track of any errors while we fill in a struct
+type binder struct {
+	r    Reader
+	errs []error
+
+	// visiting holds the struct types that we are already filling in,
+	// so that self-referential types (eg linked lists) don't send us
+	// round in circles forever
+	visiting map[reflect.Type]bool
+}
+
+func (b *binder) bindStruct(v reflect.Value, prefix string) {
+	t := v.Type()
+	b.visiting[t] = true
+	defer delete(b.visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// we can't set unexported fields
+		if field.PkgPath != "" {
+			continue
+		}
+
+		// is this a nested struct?
+		name, opts := parseEnvTag(field.Tag)
+		if len(name) == 0 {
+			if isNestedStruct(field.Type, textUnmarshalerType) && !b.visiting[structType(field.Type)] {
+				b.bindStruct(derefStruct(v.Field(i)), prefix+field.Tag.Get("envPrefix"))
+			}
+			continue
+		}
+
+		// no, it's a field we need to fill in
+		b.bindField(v.Field(i), field, prefix+name, opts)
+	}
+}
+
+func (b *binder) bindField(v reflect.Value, field reflect.StructField, key string, opts envTagOptions) {
+	// can we fill in this field?
+	if !isParsableType(field.Type) {
+		b.errs = append(b.errs, ErrUnsupportedType{Key: key, Type: typeName(field.Type)})
+		return
+	}
+
+	value, ok := b.r.LookupEnv(key)
+	if !ok || len(value) == 0 {
+		value, ok = field.Tag.Lookup("default")
+	}
+
+	// do we have a value to use?
+	if !ok {
+		if opts.required {
+			b.errs = append(b.errs, ErrRequiredVariable{key})
+		}
+		return
+	}
+
+	// yes we do
+	sep, ok := field.Tag.Lookup("sep")
+	if !ok {
+		sep = ","
+	}
+
+	if !parseValue(v, value, sep) {
+		b.errs = append(b.errs, ErrInvalidValue{Key: key, Value: value, Type: typeName(field.Type)})
+	}
+}
+
+// envTagOptions are the options that can follow the variable name in
+// an `env` struct tag
+type envTagOptions struct {
+	required bool
+}
+
+// parseEnvTag splits an `env:"NAME,option"` struct tag up
+func parseEnvTag(tag reflect.StructTag) (string, envTagOptions) {
+	var opts envTagOptions
+
+	parts := strings.Split(tag.Get("env"), ",")
+	for _, opt := range parts[1:] {
+		if strings.TrimSpace(opt) == "required" {
+			opts.required = true
+		}
+	}
+
+	return strings.TrimSpace(parts[0]), opts
+}
+
+// isNestedStruct returns true if we should look inside the given type
+// for more fields
+//
+// structs that implement the given text interface are treated as a
+// single value instead
+func isNestedStruct(t reflect.Type, textInterface reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && t != urlType && !reflect.PtrTo(t).Implements(textInterface)
+}
+
+// structType returns the struct type that t is, or points to
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// derefStruct returns the struct that v holds or points to, allocating
+// it if necessary
+func derefStruct(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Ptr {
+		return v
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return v.Elem()
+}
@@ -0,0 +1,395 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// Test helpers
+//
+// ----------------------------------------------------------------
+
+type bindTestDBConfig struct {
+	Host string `env:"HOST" default:"localhost"`
+	Port int    `env:"PORT" default:"5432"`
+}
+
+type bindTestConfig struct {
+	Name     string            `env:"APP_NAME,required"`
+	Debug    bool              `env:"APP_DEBUG"`
+	Ratio    float64           `env:"APP_RATIO"`
+	MaxConns uint16            `env:"APP_MAX_CONNS"`
+	Timeout  time.Duration     `env:"APP_TIMEOUT" default:"30s"`
+	Endpoint url.URL           `env:"APP_ENDPOINT"`
+	Proxy    *url.URL          `env:"APP_PROXY"`
+	Tags     []string          `env:"APP_TAGS"`
+	Ports    []int             `env:"APP_PORTS" sep:":"`
+	Backoff  []time.Duration   `env:"APP_BACKOFF"`
+	Bind     net.IP            `env:"APP_BIND"`
+	Limit    *int              `env:"APP_LIMIT"`
+	DB       bindTestDBConfig  `envPrefix:"DB_"`
+	Replica  *bindTestDBConfig `envPrefix:"REPLICA_"`
+	Ignored  string
+	private  string `env:"APP_PRIVATE"`
+}
+
+type bindTestNode struct {
+	Name string `env:"NAME"`
+	Next *bindTestNode
+	Link *bindTestLink `envPrefix:"LINK_"`
+}
+
+type bindTestLink struct {
+	Weight int `env:"WEIGHT"`
+	To     *bindTestNode
+}
+
+// ================================================================
+//
+// Bind
+//
+// ----------------------------------------------------------------
+
+func TestBindFillsInTaggedFields(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("APP_NAME", "envish")
+	env.Setenv("APP_DEBUG", "yes")
+	env.Setenv("APP_RATIO", "0.75")
+	env.Setenv("APP_MAX_CONNS", "100")
+	env.Setenv("APP_TIMEOUT", "1m30s")
+	env.Setenv("APP_ENDPOINT", "https://example.com/api")
+	env.Setenv("APP_PROXY", "http://proxy:3128")
+	env.Setenv("APP_TAGS", "a, b,c")
+	env.Setenv("APP_PORTS", "80:443")
+	env.Setenv("APP_BACKOFF", "1s,2s")
+	env.Setenv("APP_BIND", "127.0.0.1")
+	env.Setenv("APP_LIMIT", "10")
+	env.Setenv("APP_PRIVATE", "secret")
+	env.Setenv("DB_HOST", "db.example.com")
+	env.Setenv("REPLICA_PORT", "5433")
+
+	var cfg bindTestConfig
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(env, &cfg)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "envish", cfg.Name)
+	assert.True(t, cfg.Debug)
+	assert.Equal(t, 0.75, cfg.Ratio)
+	assert.Equal(t, uint16(100), cfg.MaxConns)
+	assert.Equal(t, 90*time.Second, cfg.Timeout)
+	assert.Equal(t, "https://example.com/api", cfg.Endpoint.String())
+	assert.Equal(t, "http://proxy:3128", cfg.Proxy.String())
+	assert.Equal(t, []string{"a", "b", "c"}, cfg.Tags)
+	assert.Equal(t, []int{80, 443}, cfg.Ports)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, cfg.Backoff)
+	assert.Equal(t, "127.0.0.1", cfg.Bind.String())
+	assert.Equal(t, 10, *cfg.Limit)
+	assert.Equal(t, "db.example.com", cfg.DB.Host)
+	assert.Equal(t, 5432, cfg.DB.Port)
+	assert.Equal(t, "localhost", cfg.Replica.Host)
+	assert.Equal(t, 5433, cfg.Replica.Port)
+	assert.Empty(t, cfg.Ignored)
+	assert.Empty(t, cfg.private)
+}
+
+func TestBindUsesDefaultsForUnsetAndEmptyVariables(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("APP_NAME", "envish")
+	env.Setenv("APP_TIMEOUT", "")
+
+	var cfg bindTestConfig
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(env, &cfg)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, cfg.Timeout)
+	assert.Equal(t, "localhost", cfg.DB.Host)
+	assert.Nil(t, cfg.Limit)
+}
+
+func TestBindLeavesFieldsAloneIfVariableIsNotSet(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("APP_NAME", "envish")
+
+	cfg := bindTestConfig{Ratio: 0.5}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(env, &cfg)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, cfg.Ratio)
+}
+
+func TestBindReturnsEveryProblemAtOnce(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("APP_DEBUG", "maybe")
+	env.Setenv("APP_MAX_CONNS", "-1")
+	env.Setenv("APP_PORTS", "80:http")
+	env.Setenv("DB_PORT", "five")
+
+	expectedError := envish.ErrBind{
+		Errors: []error{
+			envish.ErrRequiredVariable{Key: "APP_NAME"},
+			envish.ErrInvalidValue{Key: "APP_DEBUG", Value: "maybe", Type: "bool"},
+			envish.ErrInvalidValue{Key: "APP_MAX_CONNS", Value: "-1", Type: "uint16"},
+			envish.ErrInvalidValue{Key: "APP_PORTS", Value: "80:http", Type: "[]int"},
+			envish.ErrInvalidValue{Key: "DB_PORT", Value: "five", Type: "int"},
+		},
+	}
+
+	var cfg bindTestConfig
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(env, &cfg)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestBindWorksWithOverlayEnv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	overrides := envish.NewLocalEnv()
+	overrides.Setenv("DB_PORT", "6543")
+	defaults := envish.NewLocalEnv()
+	defaults.Setenv("APP_NAME", "envish")
+	defaults.Setenv("DB_HOST", "db.internal")
+	defaults.Setenv("DB_PORT", "5432")
+	env := envish.NewOverlayEnv([]envish.Expander{overrides, defaults})
+
+	var cfg bindTestConfig
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(env, &cfg)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "envish", cfg.Name)
+	assert.Equal(t, "db.internal", cfg.DB.Host)
+	assert.Equal(t, 6543, cfg.DB.Port)
+}
+
+func TestBindStopsAtSelfReferentialStructs(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("NAME", "head")
+	env.Setenv("LINK_WEIGHT", "5")
+
+	var node bindTestNode
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(env, &node)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "head", node.Name)
+	assert.Nil(t, node.Next)
+	assert.Equal(t, 5, node.Link.Weight)
+	assert.Nil(t, node.Link.To)
+}
+
+func TestBindReturnsErrorForUnsupportedFieldTypes(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	var cfg struct {
+		Param1 map[string]string `env:"PARAM1"`
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(env, &cfg)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	expectedError := envish.ErrBind{
+		Errors: []error{
+			envish.ErrUnsupportedType{Key: "PARAM1", Type: "map[string]string"},
+		},
+	}
+	assert.Equal(t, expectedError, err)
+}
+
+func TestBindReturnsErrorForTypesThatCannotBeRead(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "foo")
+
+	var cfg struct {
+		Param1 textMarshalerOnly   `env:"PARAM1"`
+		Param2 textUnmarshalerOnly `env:"PARAM2"`
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(env, &cfg)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	expectedError := envish.ErrBind{
+		Errors: []error{
+			envish.ErrUnsupportedType{Key: "PARAM1", Type: "envish_test.textMarshalerOnly"},
+		},
+	}
+	assert.Equal(t, expectedError, err)
+}
+
+func TestBindReturnsErrorIfNotGivenAPointerToAStruct(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	var cfg bindTestConfig
+	var nilCfg *bindTestConfig
+	var s string
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := envish.Bind(env, cfg)
+	err2 := envish.Bind(env, nilCfg)
+	err3 := envish.Bind(env, &s)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.IsType(t, envish.ErrInvalidBindTarget{}, err1)
+	assert.IsType(t, envish.ErrInvalidBindTarget{}, err2)
+	assert.IsType(t, envish.ErrInvalidBindTarget{}, err3)
+}
+
+func TestBindCopesWithNilReader(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var cfg bindTestConfig
+	expectedError := envish.ErrNilPointer{"Bind"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Bind(nil, &cfg)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
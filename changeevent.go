@@ -0,0 +1,228 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"fmt"
+)
+
+// ChangeOp says what kind of change a ChangeEvent describes.
+type ChangeOp int
+
+const (
+	// ChangeSet means that a variable has been created or updated
+	ChangeSet ChangeOp = iota + 1
+
+	// ChangeUnset means that a variable has been deleted
+	ChangeUnset
+
+	// ChangeClear means that every variable has been deleted
+	ChangeClear
+)
+
+// String returns the name of the operation, as used in shell scripts
+// and log files.
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeSet:
+		return "set"
+	case ChangeUnset:
+		return "unset"
+	case ChangeClear:
+		return "clear"
+	default:
+		return fmt.Sprintf("ChangeOp(%d)", int(op))
+	}
+}
+
+// ChangeEvent describes a single change to an environment.
+//
+// Register a function with OnChange to receive them.
+type ChangeEvent struct {
+	// Op is what happened
+	Op ChangeOp
+
+	// Key is the variable that has changed. It is empty for ChangeClear.
+	Key string
+
+	// OldValue is the variable's value before the change
+	OldValue string
+
+	// WasSet is true if the variable was set before the change
+	WasSet bool
+
+	// NewValue is the variable's value after the change. It is empty
+	// for ChangeUnset and ChangeClear.
+	NewValue string
+}
+
+// changeHooks is the list of functions to call whenever an environment
+// changes
+type changeHooks struct {
+	// fns are the functions to call, in the order they were added
+	fns []func(ChangeEvent)
+
+	// queue is where events go while they are being held back. It is
+	// nil when events are passed on straight away.
+	queue *heldEvents
+
+	// marks remembers how many events were in the queue at each call
+	// to hold, so that nested holds can be released one at a time
+	marks []int
+}
+
+// heldEvents is a queue of change events that are being held back, in
+// the order that they happened
+//
+// an OverlayEnv shares one queue with each of its environments, so that
+// their events are passed on in the right order
+type heldEvents struct {
+	// events are the events that have been held back
+	events []heldEvent
+
+	// holders is how many changeHooks are still holding events in this
+	// queue. The events are passed on once it drops to zero.
+	holders int
+}
+
+// heldEvent is a single change event that is being held back
+type heldEvent struct {
+	hooks *changeHooks
+	ev    ChangeEvent
+}
+
+// add registers another function to call
+func (h *changeHooks) add(fn func(ChangeEvent)) {
+	// robustness
+	if fn == nil {
+		return
+	}
+
+	h.fns = append(h.fns, fn)
+}
+
+// notify calls every registered function, in the order they were added
+//
+// if events are being held back, the event is queued up instead
+func (h *changeHooks) notify(ev ChangeEvent) {
+	if h.queue != nil {
+		h.queue.events = append(h.queue.events, heldEvent{hooks: h, ev: ev})
+		return
+	}
+
+	h.deliver(ev)
+}
+
+// deliver calls every registered function, in the order they were added
+func (h *changeHooks) deliver(ev ChangeEvent) {
+	for _, fn := range h.fns {
+		fn(ev)
+	}
+}
+
+// hold queues up any events, instead of passing them on straight away
+//
+// the events go into the given queue, unless we are already holding
+// events in another queue (or q is nil). hold returns the queue that
+// is being used.
+//
+// every call to hold must be matched by a call to release
+func (h *changeHooks) hold(q *heldEvents) *heldEvents {
+	if h.queue == nil {
+		if q == nil {
+			q = &heldEvents{}
+		}
+		h.queue = q
+		q.holders++
+	}
+
+	h.marks = append(h.marks, len(h.queue.events))
+	return h.queue
+}
+
+// release undoes the most recent call to hold. If deliver is false, any
+// events that have been held back since then are thrown away.
+//
+// once nothing is holding the queue any more, the events that are left
+// are passed on, in the order that they happened.
+func (h *changeHooks) release(deliver bool) {
+	// robustness
+	if len(h.marks) == 0 {
+		return
+	}
+
+	// which events belong to this hold?
+	mark := h.marks[len(h.marks)-1]
+	h.marks = h.marks[:len(h.marks)-1]
+	q := h.queue
+	if !deliver && mark < len(q.events) {
+		q.events = q.events[:mark]
+	}
+
+	// are we still holding?
+	if len(h.marks) > 0 {
+		return
+	}
+	h.queue = nil
+	q.holders--
+	if q.holders > 0 {
+		return
+	}
+
+	// if we get here, it is time to pass everything on
+	events := q.events
+	q.events = nil
+	for _, held := range events {
+		held.hooks.deliver(held.ev)
+	}
+}
+
+// hookHolder is the interface that wraps environments that can hold back
+// their change events
+//
+// Tx.Commit uses it, so that no-one hears about changes that are undone
+// when a commit fails
+type hookHolder interface {
+	// holdHooks queues up any change events, instead of passing them on
+	//
+	// they go into the given queue (if it isn't nil), so that they can
+	// be passed on in the order they happened
+	holdHooks(q *heldEvents)
+
+	// releaseHooks passes on (if deliver is true) or throws away (if
+	// deliver is false) the queued up change events
+	releaseHooks(deliver bool)
+}
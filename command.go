@@ -0,0 +1,283 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Command works like Golang's exec.Command, except that the command runs
+// in the given environment instead of your program's environment.
+//
+// * cmd.Env is set to env.Environ(). Wrap a LocalEnv in an OverlayEnv if
+// the command should only see the variables that have been exported.
+//
+// * `$VAR` and `${VAR}` in the name and args are expanded using env
+//
+// * name is searched for in env's PATH, not in your program's PATH
+//
+// If name cannot be found, cmd.Path is left empty, and cmd.Start returns
+// an error. Use LookPath first if you need to know why.
+func Command(env Reader, name string, args ...string) *exec.Cmd {
+	return command(nil, env, name, args)
+}
+
+// CommandContext works like Command, except that the command is killed
+// if the context is done before the command finishes.
+func CommandContext(ctx context.Context, env Reader, name string, args ...string) *exec.Cmd {
+	return command(ctx, env, name, args)
+}
+
+// Run builds a command using CommandContext, runs it, and waits for it
+// to finish.
+//
+// The command's stdin, stdout and stderr are connected to the null device.
+// Use CommandContext if you need to change them.
+//
+// Run returns an *exec.Error if name cannot be found in env's PATH.
+func Run(ctx context.Context, env Reader, name string, args ...string) error {
+	cmd := command(ctx, env, name, args)
+
+	// did we find the command?
+	if len(cmd.Path) == 0 {
+		_, err := LookPath(env, cmd.Args[0])
+		return err
+	}
+
+	// yes we did
+	return cmd.Run()
+}
+
+// LookPath works like Golang's exec.LookPath, except that it searches
+// the directories in env's PATH instead of your program's PATH.
+//
+// If file contains a path separator, it is used as-is, and PATH is not
+// searched.
+//
+// Relative directories in PATH (including empty entries, which mean the
+// current directory) are skipped, so that a command can never be found
+// by accident in the current directory.
+//
+// On Windows, env's PATHEXT decides which file extensions count as
+// executables.
+func LookPath(env Reader, file string) (string, error) {
+	// special case - file is already a path
+	if strings.ContainsAny(file, pathSeparators()) {
+		err := checkExecutable(file)
+		if err != nil {
+			return "", &exec.Error{Name: file, Err: err}
+		}
+		return file, nil
+	}
+
+	// general case - search the PATH
+	//
+	// env may be nil
+	var path string
+	if env != nil {
+		path = env.Getenv("PATH")
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		// never search the current directory
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+
+		for _, candidate := range executableNames(env, filepath.Join(dir, file)) {
+			if checkExecutable(candidate) == nil {
+				return candidate, nil
+			}
+		}
+	}
+
+	// if we get here, we didn't find it
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+// command builds an *exec.Cmd that will run in the given environment
+func command(ctx context.Context, env Reader, name string, args []string) *exec.Cmd {
+	expander := asExpander(env)
+
+	// expand everything first
+	name = expand(expander, name)
+	expandedArgs := make([]string, len(args))
+	for i, arg := range args {
+		expandedArgs[i] = expand(expander, arg)
+	}
+
+	// we create the exec.Cmd ourselves, so that exec.Command doesn't
+	// search our program's PATH
+	cmd := &exec.Cmd{
+		Args: append([]string{name}, expandedArgs...),
+		Env:  []string{},
+	}
+	if env != nil {
+		cmd.Env = env.Environ()
+	}
+
+	// we only set cmd.Path if we can find the command
+	path, err := LookPath(env, name)
+	if err == nil {
+		cmd.Path = path
+	}
+
+	// do we need to support cancellation?
+	if ctx == nil {
+		return cmd
+	}
+
+	// yes we do
+	//
+	// exec.CommandContext is the only way to set this up, so we copy
+	// our settings across
+	retval := exec.CommandContext(ctx, cmd.Path)
+	retval.Path = cmd.Path
+	retval.Args = cmd.Args
+	retval.Env = cmd.Env
+
+	return retval
+}
+
+// asExpander returns an Expander that we can use to expand the command's
+// name and args
+func asExpander(env Reader) Expander {
+	// special case - no environment at all
+	if env == nil {
+		return NewLocalEnv()
+	}
+
+	// can it expand strings itself?
+	expander, ok := env.(Expander)
+	if ok {
+		return expander
+	}
+
+	// no, so we need to help
+	return readOnlyExpander{env}
+}
+
+// readOnlyExpander adds string expansion to a Reader
+//
+// it cannot change any variables, so `${var:=word}` fails
+type readOnlyExpander struct {
+	Reader
+}
+
+func (e readOnlyExpander) Clearenv() {
+	// do nothing
+}
+
+func (e readOnlyExpander) Setenv(key, value string) error {
+	return ErrReadOnlyVar{Key: key}
+}
+
+func (e readOnlyExpander) Unsetenv(key string) {
+	// do nothing
+}
+
+func (e readOnlyExpander) Expand(fmt string) string {
+	return expand(e, fmt)
+}
+
+// pathSeparators returns the characters that mean a command name is
+// a path
+func pathSeparators() string {
+	if runtime.GOOS == "windows" {
+		return `/\:`
+	}
+
+	return "/"
+}
+
+// executableNames returns the filenames that we should try, when we
+// look for the given executable
+func executableNames(env Reader, path string) []string {
+	// only Windows cares about file extensions
+	if runtime.GOOS != "windows" {
+		return []string{path}
+	}
+
+	// does it already have an extension?
+	if len(filepath.Ext(path)) > 0 {
+		return []string{path}
+	}
+
+	// no, so what extensions should we try?
+	var pathExt string
+	if env != nil {
+		pathExt = env.Getenv("PATHEXT")
+	}
+	if len(pathExt) == 0 {
+		pathExt = ".com;.exe;.bat;.cmd"
+	}
+
+	retval := []string{}
+	for _, ext := range strings.Split(pathExt, ";") {
+		if len(ext) > 0 {
+			retval = append(retval, path+strings.ToLower(ext))
+		}
+	}
+
+	return retval
+}
+
+// checkExecutable returns an error if the given file is not something
+// that we can run
+func checkExecutable(file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	mode := info.Mode()
+	if mode.IsDir() {
+		return os.ErrPermission
+	}
+
+	// Windows doesn't have an executable bit
+	if runtime.GOOS != "windows" && mode&0111 == 0 {
+		return os.ErrPermission
+	}
+
+	// all done
+	return nil
+}
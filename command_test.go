@@ -0,0 +1,389 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeTestCommand creates a shell script in a temporary folder, and
+// returns the folder
+func makeTestCommand(t *testing.T, name, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("test commands are shell scripts")
+	}
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// ================================================================
+//
+// LookPath
+//
+// ----------------------------------------------------------------
+
+func TestLookPathSearchesTheEnvironmentsPath(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	dir := makeTestCommand(t, "envish-test-cmd", "exit 0")
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", "/does/not/exist"+string(os.PathListSeparator)+dir)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualPath, err := envish.LookPath(env, "envish-test-cmd")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "envish-test-cmd"), actualPath)
+}
+
+func TestLookPathDoesNotSearchTheProgramsPath(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	// "sh" is on our program's PATH, but not on env's PATH
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", t.TempDir())
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := envish.LookPath(env, "sh")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, &exec.Error{Name: "sh", Err: exec.ErrNotFound}, err)
+}
+
+func TestLookPathSkipsRelativeDirectories(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", string(os.PathListSeparator)+".")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := envish.LookPath(env, "command_test.go")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, &exec.Error{Name: "command_test.go", Err: exec.ErrNotFound}, err)
+}
+
+func TestLookPathSkipsFilesThatAreNotExecutable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	dir := makeTestCommand(t, "envish-test-cmd", "exit 0")
+	os.Chmod(filepath.Join(dir, "envish-test-cmd"), 0644)
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", dir)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := envish.LookPath(env, "envish-test-cmd")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, &exec.Error{Name: "envish-test-cmd", Err: exec.ErrNotFound}, err)
+}
+
+func TestLookPathUsesPathsAsIs(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	dir := makeTestCommand(t, "envish-test-cmd", "exit 0")
+	path := filepath.Join(dir, "envish-test-cmd")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualPath, err := envish.LookPath(envish.NewLocalEnv(), path)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, path, actualPath)
+}
+
+// ================================================================
+//
+// Command
+//
+// ----------------------------------------------------------------
+
+func TestCommandRunsInTheGivenEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	dir := makeTestCommand(t, "envish-test-cmd", `echo "$1 $GREETING"`)
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", dir)
+	env.Setenv("GREETING", "hello")
+	env.Setenv("NAME", "world")
+
+	var stdout bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	cmd := envish.Command(env, "envish-test-cmd", "$NAME")
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"envish-test-cmd", "world"}, cmd.Args)
+	assert.Equal(t, []string{"PATH=" + dir, "GREETING=hello", "NAME=world"}, cmd.Env)
+	assert.Equal(t, "world hello\n", stdout.String())
+}
+
+func TestCommandOnlySeesExportedVariablesThroughAnOverlayEnv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	dir := makeTestCommand(t, "envish-test-cmd", `echo "$GREETING $SECRET"`)
+
+	localEnv := envish.NewLocalEnv(envish.SetAsExporter)
+	localEnv.Setenv("PATH", dir)
+	localEnv.Setenv("GREETING", "hello")
+	localEnv.Setenv("SECRET", "do not export")
+	localEnv.Unexport("SECRET")
+	env := envish.NewOverlayEnv([]envish.Expander{localEnv})
+
+	var stdout bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	cmd := envish.Command(env, "envish-test-cmd")
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"GREETING=hello", "PATH=" + dir}, cmd.Env)
+	assert.Equal(t, "hello \n", stdout.String())
+}
+
+func TestCommandLeavesPathEmptyWhenCommandIsNotFound(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	cmd := envish.Command(env, "sh", "-c", "exit 0")
+	err := cmd.Run()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "", cmd.Path)
+	assert.NotNil(t, err)
+}
+
+func TestCommandWorksWithReadOnlyReaders(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("NAME", "world")
+	snapshot := envish.TakeSnapshot(env)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	cmd := envish.Command(snapshot, "echo", "hello $NAME", "${OTHER:=x}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"echo", "hello world", "${OTHER:=x}"}, cmd.Args)
+	assert.Equal(t, []string{"NAME=world"}, cmd.Env)
+}
+
+func TestCommandCopesWithNilEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	cmd := envish.Command(nil, "echo", "$HOME")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"echo", ""}, cmd.Args)
+	assert.Equal(t, []string{}, cmd.Env)
+	assert.Equal(t, "", cmd.Path)
+}
+
+// ================================================================
+//
+// Run
+//
+// ----------------------------------------------------------------
+
+func TestRunRunsTheCommand(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	dir := makeTestCommand(t, "envish-test-cmd", `exit "$1"`)
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("PATH", dir)
+	env.Setenv("STATUS", "3")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Run(context.Background(), env, "envish-test-cmd", "$STATUS")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	var exitErr *exec.ExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 3, exitErr.ExitCode())
+}
+
+func TestRunReturnsErrorWhenCommandIsNotFound(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Run(context.Background(), env, "envish-no-such-cmd")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, &exec.Error{Name: "envish-no-such-cmd", Err: exec.ErrNotFound}, err)
+}
+
+func TestRunStopsWhenTheContextIsDone(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	dir := makeTestCommand(t, "envish-test-cmd", "exec sleep 10")
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("PATH", dir+string(os.PathListSeparator)+"/bin"+string(os.PathListSeparator)+"/usr/bin")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	start := time.Now()
+	err := envish.Run(ctx, env, "envish-test-cmd")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
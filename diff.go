@@ -0,0 +1,198 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnvDiff lists the differences between two environments.
+//
+// Use Diff to create one. Each list is sorted by key.
+type EnvDiff struct {
+	// Added holds the variables that are only in the second environment.
+	// Their OldValue is always empty.
+	Added []VarChange
+
+	// Removed holds the variables that are only in the first environment.
+	// Their NewValue is always empty.
+	Removed []VarChange
+
+	// Changed holds the variables that are in both environments, with
+	// different values.
+	Changed []VarChange
+}
+
+// VarChange describes what has happened to a single variable.
+type VarChange struct {
+	Key      string
+	OldValue string
+	NewValue string
+}
+
+// Diff compares every variable in the two environments, and tells you
+// how to get from a to b.
+//
+// It compares all variables, whether they have been exported or not.
+// A nil Reader is treated as an empty environment.
+//
+// Use TakeSnapshot to keep a copy of an environment, so that you can
+// find out later on what has changed.
+func Diff(a, b Reader) EnvDiff {
+	retval := EnvDiff{}
+
+	oldValues := diffValuesOf(a)
+	newValues := diffValuesOf(b)
+
+	for key, oldValue := range oldValues {
+		newValue, ok := newValues[key]
+		switch {
+		case !ok:
+			retval.Removed = append(retval.Removed, VarChange{Key: key, OldValue: oldValue})
+		case newValue != oldValue:
+			retval.Changed = append(retval.Changed, VarChange{Key: key, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	for key, newValue := range newValues {
+		_, ok := oldValues[key]
+		if !ok {
+			retval.Added = append(retval.Added, VarChange{Key: key, NewValue: newValue})
+		}
+	}
+
+	// maps have no order, so we have to impose one
+	sortVarChanges(retval.Added)
+	sortVarChanges(retval.Removed)
+	sortVarChanges(retval.Changed)
+
+	// all done
+	return retval
+}
+
+// Apply makes the same changes to the given environment.
+//
+// It stops, and returns the error, if any variable cannot be set.
+func (d EnvDiff) Apply(w Writer) error {
+	// do we have an environment to change?
+	if w == nil {
+		return ErrNilPointer{"EnvDiff.Apply"}
+	}
+
+	// yes we do
+	for _, change := range d.Removed {
+		w.Unsetenv(change.Key)
+	}
+
+	for _, changes := range [][]VarChange{d.Changed, d.Added} {
+		for _, change := range changes {
+			err := w.Setenv(change.Key, change.NewValue)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// all done
+	return nil
+}
+
+// IsEmpty returns true if there are no differences.
+func (d EnvDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String returns the differences, one variable per line, sorted by key.
+//
+// Added variables look like `+ KEY="new value"`, removed variables look
+// like `- KEY="old value"`, and changed variables look like
+// `~ KEY="old value" -> "new value"`. Values are quoted using Go syntax,
+// so that each change always fits on a single line.
+func (d EnvDiff) String() string {
+	lines := make([]string, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	keys := make(map[string]string)
+
+	for _, change := range d.Added {
+		keys[change.Key] = fmt.Sprintf("+ %s=%q", change.Key, change.NewValue)
+	}
+	for _, change := range d.Removed {
+		keys[change.Key] = fmt.Sprintf("- %s=%q", change.Key, change.OldValue)
+	}
+	for _, change := range d.Changed {
+		keys[change.Key] = fmt.Sprintf("~ %s=%q -> %q", change.Key, change.OldValue, change.NewValue)
+	}
+
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		lines = append(lines, keys[key]+"\n")
+	}
+
+	return strings.Join(lines, "")
+}
+
+// diffValuesOf returns a lookup table of every variable in the given
+// environment
+func diffValuesOf(r Reader) map[string]string {
+	retval := make(map[string]string)
+
+	// do we have an environment to work with?
+	if r == nil {
+		return retval
+	}
+
+	// yes we do
+	for _, pair := range allVarsOf(r) {
+		key := GetKeyFromPair(pair)
+		retval[key] = GetValueFromPair(pair, key)
+	}
+
+	// all done
+	return retval
+}
+
+// sortVarChanges sorts the given list by key
+func sortVarChanges(changes []VarChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+}
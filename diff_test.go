@@ -0,0 +1,284 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// Diff
+//
+// ----------------------------------------------------------------
+
+func TestDiffFindsAddedRemovedAndChangedVariables(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "foo")
+	env1.Setenv("PARAM2", "bar")
+	env1.Setenv("PARAM3", "baz")
+	env1.Setenv("PARAM5", "same")
+
+	env2 := envish.NewLocalEnv()
+	env2.Setenv("PARAM5", "same")
+	env2.Setenv("PARAM4", "new")
+	env2.Setenv("PARAM3", "changed")
+	env2.Setenv("PARAM0", "")
+
+	expectedResult := envish.EnvDiff{
+		Added: []envish.VarChange{
+			{Key: "PARAM0", NewValue: ""},
+			{Key: "PARAM4", NewValue: "new"},
+		},
+		Removed: []envish.VarChange{
+			{Key: "PARAM1", OldValue: "foo"},
+			{Key: "PARAM2", OldValue: "bar"},
+		},
+		Changed: []envish.VarChange{
+			{Key: "PARAM3", OldValue: "baz", NewValue: "changed"},
+		},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := envish.Diff(env1, env2)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+	assert.False(t, actualResult.IsEmpty())
+}
+
+func TestDiffOfIdenticalEnvironmentsIsEmpty(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "foo")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := envish.Diff(env1, env2)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, actualResult.IsEmpty())
+	assert.Equal(t, "", actualResult.String())
+}
+
+func TestDiffTreatsNilAsAnEmptyEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult1 := envish.Diff(nil, env)
+	actualResult2 := envish.Diff(env, nil)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []envish.VarChange{{Key: "PARAM1", NewValue: "foo"}}, actualResult1.Added)
+	assert.Equal(t, []envish.VarChange{{Key: "PARAM1", OldValue: "foo"}}, actualResult2.Removed)
+}
+
+func TestDiffWorksWithSnapshots(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	snap := envish.TakeSnapshot(env)
+
+	expectedResult := "~ PARAM1=\"foo\" -> \"bar\"\n"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Setenv("PARAM1", "bar")
+	actualResult := envish.Diff(snap, env).String()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+// ================================================================
+//
+// EnvDiff.String
+//
+// ----------------------------------------------------------------
+
+func TestEnvDiffStringIsSortedByKey(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.EnvDiff{
+		Added: []envish.VarChange{
+			{Key: "PARAM4", NewValue: "line 1\nline 2"},
+		},
+		Removed: []envish.VarChange{
+			{Key: "PARAM1", OldValue: "foo"},
+		},
+		Changed: []envish.VarChange{
+			{Key: "PARAM2", OldValue: "", NewValue: "say \"hi\""},
+		},
+	}
+
+	expectedResult := "- PARAM1=\"foo\"\n" +
+		"~ PARAM2=\"\" -> \"say \\\"hi\\\"\"\n" +
+		"+ PARAM4=\"line 1\\nline 2\"\n"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.String()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+// ================================================================
+//
+// EnvDiff.Apply
+//
+// ----------------------------------------------------------------
+
+func TestEnvDiffApplyReplaysTheChanges(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	before := envish.NewLocalEnv()
+	before.Setenv("PARAM1", "foo")
+	before.Setenv("PARAM2", "bar")
+
+	after := envish.NewLocalEnv()
+	after.Setenv("PARAM2", "changed")
+	after.Setenv("PARAM3", "new")
+
+	target := envish.NewLocalEnv()
+	target.Setenv("PARAM1", "foo")
+	target.Setenv("PARAM2", "bar")
+	target.Setenv("OTHER", "untouched")
+
+	expectedResult := []string{"PARAM2=changed", "OTHER=untouched", "PARAM3=new"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Diff(before, after).Apply(target)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, target.AllVars())
+}
+
+func TestEnvDiffApplyReturnsErrorIfSetenvFails(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.EnvDiff{
+		Added: []envish.VarChange{{Key: "PARAM1", NewValue: "foo"}},
+	}
+	target := envish.NewLocalEnv()
+	target.SetReadOnly("PARAM1")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := testData.Apply(target)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestEnvDiffApplyCopesWithNilWriter(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	expectedError := envish.ErrNilPointer{"EnvDiff.Apply"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.EnvDiff{}.Apply(nil)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
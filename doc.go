@@ -46,10 +46,13 @@ It is released under the 3-clause New BSD license. See LICENSE.md for details.
   // add to this temporary environment
   // WITHOUT changing your program's environment
   env.Setenv("EXAMPLE_KEY", "EXAMPLE VALUE")
+  env.Setenv("PATH", "/usr/local/bin:/usr/bin:/bin")
 
-  // pass it into run a child process
-  cmd := exec.Command('example-cmd')
-  cmd.Env = env.Environ()
+  // run a child process in this temporary environment
+  //
+  // the child process only sees the variables that env exports,
+  // and 'example-cmd' is found using env's PATH
+  cmd := envish.Command(env, "example-cmd", "$EXAMPLE_KEY")
   cmd.Start()
 
 
@@ -0,0 +1,489 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// DotEnvOptions changes how ReadDotEnv (and LoadDotEnv and
+// LoadDotEnvFile) load a dotenv file.
+//
+// The zero value expands unquoted and double-quoted values.
+type DotEnvOptions struct {
+	// NoExpand turns off string expansion. Every value is loaded exactly
+	// as it is written in the dotenv file, `$` signs and all.
+	NoExpand bool
+}
+
+// ExpandVariables returns an option that decides whether or not values
+// are expanded as they are loaded. Values are expanded by default.
+func ExpandVariables(expand bool) func(*DotEnvOptions) {
+	return func(o *DotEnvOptions) {
+		o.NoExpand = !expand
+	}
+}
+
+// LoadDotEnv creates a new LocalEnv, and loads the contents of a
+// dotenv (.env) file into it. The new LocalEnv is an exporter.
+//
+// See ReadDotEnv for the file format that we support.
+func LoadDotEnv(r io.Reader, options ...func(*DotEnvOptions)) (*LocalEnv, error) {
+	retval := NewLocalEnv(SetAsExporter)
+
+	err := ReadDotEnv(retval, r, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// all done
+	return retval, nil
+}
+
+// LoadDotEnvFile creates a new LocalEnv, and loads the contents of the
+// given dotenv (.env) file into it.
+//
+// See ReadDotEnv for the file format that we support.
+func LoadDotEnvFile(path string, options ...func(*DotEnvOptions)) (*LocalEnv, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadDotEnv(f, options...)
+}
+
+// ReadDotEnv parses the contents of a dotenv (.env) file, and sets
+// each variable it finds in the given environment.
+//
+// It supports:
+//
+// * blank lines, and comments that start with `#`
+//
+// * an optional `export ` prefix in front of each variable
+//
+// * unquoted values, which end at the end of the line or at a ` #` comment
+//
+// * single-quoted values, which are used as-is. Use `\'` to include a
+// single quote.
+//
+// * double-quoted values, which can span multiple lines, and support the
+// escape sequences `\n`, `\r`, `\t`, `\"`, `\\` and `\$`
+//
+// Unquoted and double-quoted values are expanded using the given
+// environment, so that they can refer to variables that have already
+// been loaded. Use `\$` to include a literal dollar sign, or use the
+// ExpandVariables(false) option to turn expansion off altogether.
+//
+// It returns ErrDotEnvSyntax or ErrDotEnvExpansion if anything goes wrong.
+// Any variables before the problem will already have been set.
+func ReadDotEnv(e Expander, r io.Reader, options ...func(*DotEnvOptions)) error {
+	// do we have an environment to work with?
+	if e == nil {
+		return ErrNilPointer{"ReadDotEnv"}
+	}
+
+	// yes we do
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	opts := DotEnvOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	p := dotEnvParser{
+		input:    strings.ReplaceAll(string(raw), "\r\n", "\n"),
+		line:     1,
+		noExpand: opts.NoExpand,
+	}
+	for {
+		key, value, line, ok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		if !opts.NoExpand {
+			value, err = expandE(e, value)
+			if err != nil {
+				return ErrDotEnvExpansion{Line: line, Err: err}
+			}
+		}
+
+		err = e.Setenv(key, value)
+		if err != nil {
+			return ErrDotEnvExpansion{Line: line, Err: err}
+		}
+	}
+}
+
+// WriteDotEnv writes the contents of the given environment to w, in
+// dotenv (.env) format.
+//
+// Values are quoted and escaped so that ReadDotEnv will load them back
+// in exactly as they are now. Values that only contain safe characters
+// are written without quotes.
+//
+// It returns ErrDotEnvKey if the environment contains a variable name
+// that cannot be written to a dotenv file, such as `$#`.
+func WriteDotEnv(w io.Writer, r Reader) error {
+	// do we have an environment to work with?
+	if r == nil {
+		return ErrNilPointer{"WriteDotEnv"}
+	}
+
+	// yes we do
+	for _, pair := range r.Environ() {
+		key := GetKeyFromPair(pair)
+		if !isValidDotEnvKey(key) {
+			return ErrDotEnvKey{key}
+		}
+
+		_, err := io.WriteString(w, key+"="+quoteDotEnvValue(GetValueFromPair(pair, key))+"\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	// all done
+	return nil
+}
+
+// quoteDotEnvValue returns the given value, quoted if necessary so that
+// ReadDotEnv can load it back in again
+func quoteDotEnvValue(value string) string {
+	// does it need quoting at all?
+	safe := true
+	for i := 0; i < len(value); i++ {
+		if !isNameBodyChar(value[i]) && strings.IndexByte("./:@%+,-=", value[i]) < 0 {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return value
+	}
+
+	// yes it does
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\', '"', '$':
+			buf.WriteByte('\\')
+			buf.WriteByte(value[i])
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			buf.WriteByte(value[i])
+		}
+	}
+	buf.WriteByte('"')
+
+	return buf.String()
+}
+
+// dotEnvParser breaks a dotenv file up into key/value pairs
+type dotEnvParser struct {
+	// input is the whole dotenv file
+	input string
+
+	// pos is where we are in the input
+	pos int
+
+	// line is the line number of input[pos]
+	line int
+
+	// noExpand is true if the values will be used as-is, instead of
+	// being passed into expandE
+	noExpand bool
+}
+
+// next returns the next key/value pair from the dotenv file
+//
+// the value is ready to pass into expandE, unless p.noExpand is set
+func (p *dotEnvParser) next() (key, value string, line int, ok bool, err error) {
+	for p.pos < len(p.input) {
+		line = p.line
+		text := p.readLine()
+
+		// we must not trim the end of the line, in case it is part of
+		// a multi-line quoted value
+		trimmed := strings.TrimLeft(text, " \t")
+
+		// skip blank lines and comments
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+
+		// skip the optional export keyword
+		if strings.HasPrefix(trimmed, "export ") || strings.HasPrefix(trimmed, "export\t") {
+			trimmed = strings.TrimSpace(trimmed[len("export"):])
+		}
+
+		// find the key
+		eq := strings.IndexByte(trimmed, '=')
+		if eq < 0 {
+			return "", "", line, false, ErrDotEnvSyntax{line, "missing '='"}
+		}
+		key = strings.TrimSpace(trimmed[:eq])
+		if !isValidDotEnvKey(key) {
+			return "", "", line, false, ErrDotEnvSyntax{line, "invalid variable name '" + key + "'"}
+		}
+
+		// find the value
+		value, err = p.parseValue(strings.TrimLeft(trimmed[eq+1:], " \t"), line)
+		if err != nil {
+			return "", "", line, false, err
+		}
+
+		return key, value, line, true, nil
+	}
+
+	// if we get here, we've run out of input
+	return "", "", p.line, false, nil
+}
+
+// readLine returns the rest of the current line, and moves us onto
+// the start of the next one
+func (p *dotEnvParser) readLine() string {
+	end := strings.IndexByte(p.input[p.pos:], '\n')
+	if end < 0 {
+		retval := p.input[p.pos:]
+		p.pos = len(p.input)
+		return retval
+	}
+
+	retval := p.input[p.pos : p.pos+end]
+	p.pos += end + 1
+	p.line++
+	return retval
+}
+
+// parseValue works out the value of a variable, starting with the text
+// after the '=' sign
+func (p *dotEnvParser) parseValue(text string, line int) (string, error) {
+	// special case - empty value
+	if len(text) == 0 {
+		return "", nil
+	}
+
+	switch text[0] {
+	case '\'':
+		return p.parseQuotedValue(text, line, p.parseSingleQuotedValue)
+	case '"':
+		return p.parseQuotedValue(text, line, p.parseDoubleQuotedValue)
+	default:
+		return p.parseUnquotedValue(text), nil
+	}
+}
+
+// dotEnvQuoteParser looks for the closing quote in text
+//
+// it returns the value ready for expandE (unless p.noExpand is set),
+// and the position of the closing
+// quote (or -1 if it hasn't found one yet)
+type dotEnvQuoteParser func(text string) (string, int)
+
+// parseQuotedValue deals with values that are wrapped in quotes, and
+// may span several lines
+func (p *dotEnvParser) parseQuotedValue(text string, line int, parser dotEnvQuoteParser) (string, error) {
+	for {
+		value, end := parser(text[1:])
+		if end >= 0 {
+			// make sure there's nothing but a comment after the closing quote
+			rest := strings.TrimSpace(text[end+2:])
+			if len(rest) > 0 && rest[0] != '#' {
+				return "", ErrDotEnvSyntax{line, "unexpected text after closing quote"}
+			}
+
+			return value, nil
+		}
+
+		// the value must continue onto the next line
+		if p.pos >= len(p.input) {
+			return "", ErrDotEnvSyntax{line, "missing closing quote"}
+		}
+		text += "\n" + p.readLine()
+	}
+}
+
+// parseSingleQuotedValue finds the end of a single-quoted value
+func (p *dotEnvParser) parseSingleQuotedValue(text string) (string, int) {
+	var buf strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\'':
+			return buf.String(), i
+		case c == '\\' && i+1 < len(text) && (text[i+1] == '\'' || text[i+1] == '\\'):
+			i++
+			p.writeLiteral(&buf, text[i])
+		default:
+			p.writeLiteral(&buf, c)
+		}
+	}
+
+	// if we get here, the quote has not been closed
+	return "", -1
+}
+
+// parseDoubleQuotedValue finds the end of a double-quoted value
+func (p *dotEnvParser) parseDoubleQuotedValue(text string) (string, int) {
+	var buf strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"':
+			return buf.String(), i
+		case c == '\\' && i+1 < len(text):
+			i++
+			switch text[i] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case '"', '\\', '$':
+				p.writeLiteral(&buf, text[i])
+			default:
+				p.writeLiteral(&buf, '\\')
+				p.writeLiteral(&buf, text[i])
+			}
+		case c == '$' && !p.noExpand:
+			i += p.writeParam(&buf, text[i:]) - 1
+		default:
+			p.writeLiteral(&buf, c)
+		}
+	}
+
+	// if we get here, the quote has not been closed
+	return "", -1
+}
+
+// parseUnquotedValue deals with a value that isn't wrapped in quotes
+func (p *dotEnvParser) parseUnquotedValue(text string) string {
+	var buf strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case (c == ' ' || c == '\t') && strings.HasPrefix(strings.TrimLeft(text[i:], " \t"), "#"):
+			// we've reached an inline comment
+			return strings.TrimRight(buf.String(), " \t")
+		case c == '\\' && i+1 < len(text):
+			i++
+			p.writeLiteral(&buf, text[i])
+		case c == '$' && !p.noExpand:
+			i += p.writeParam(&buf, text[i:]) - 1
+		default:
+			p.writeLiteral(&buf, c)
+		}
+	}
+
+	return strings.TrimRight(buf.String(), " \t")
+}
+
+// writeLiteral adds a character that must not be expanded
+//
+// shellexpand treats more than just `$` as special, so we escape
+// anything that it might try to expand
+func (p *dotEnvParser) writeLiteral(buf *strings.Builder, c byte) {
+	if !p.noExpand && strings.IndexByte("\\${}~", c) >= 0 {
+		buf.WriteByte('\\')
+	}
+	buf.WriteByte(c)
+}
+
+// writeParam adds the parameter at the start of text, so that it
+// will be expanded
+//
+// it returns the number of bytes of text that it has used
+func (p *dotEnvParser) writeParam(buf *strings.Builder, text string) int {
+	// special case - braced params are passed through untouched
+	if len(text) > 1 && text[1] == '{' {
+		end := matchClosingBrace(text, 1)
+		if end < 0 {
+			// let expandE report the problem
+			buf.WriteString("${")
+			return 2
+		}
+		buf.WriteString(text[:end+1])
+		return end + 1
+	}
+
+	// shellexpand only expands `$var` when it is followed by a space
+	// or the end of the string, so we wrap it in braces
+	nameLen := matchParamName(text[1:])
+	if nameLen == 0 {
+		p.writeLiteral(buf, '$')
+		return 1
+	}
+	if isDigit(text[1]) {
+		nameLen = 1
+	}
+	buf.WriteString("${" + text[1:1+nameLen] + "}")
+	return 1 + nameLen
+}
+
+// isValidDotEnvKey returns true if the given key can be used as a
+// variable name in a dotenv file
+func isValidDotEnvKey(key string) bool {
+	if len(key) == 0 || !isNameStartChar(key[0]) {
+		return false
+	}
+
+	for i := 1; i < len(key); i++ {
+		if !isNameBodyChar(key[i]) && key[i] != '.' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"fmt"
+	"strings"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+)
+
+func ExampleLoadDotEnv() {
+	// this would normally come from a file
+	dotenv := `# where our app lives
+export APP_HOME=/opt/app
+APP_BIN=$APP_HOME/bin
+GREETING="hello
+world"
+`
+
+	env, err := envish.LoadDotEnv(strings.NewReader(dotenv))
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+
+	fmt.Println(env.Getenv("APP_BIN"))
+	fmt.Println(env.Getenv("GREETING"))
+	// Output:
+	// /opt/app/bin
+	// hello
+	// world
+}
+
+func ExampleWriteDotEnv() {
+	env := envish.NewLocalEnv()
+	env.Setenv("APP_HOME", "/opt/app")
+	env.Setenv("GREETING", "say \"hello\" to $USER")
+
+	var buf strings.Builder
+	err := envish.WriteDotEnv(&buf, env)
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+
+	fmt.Print(buf.String())
+	// Output:
+	// APP_HOME=/opt/app
+	// GREETING="say \"hello\" to \$USER"
+}
@@ -0,0 +1,536 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDotEnvParsesKeyValuePairs(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := `# this is a comment
+PARAM1=foo
+
+  # an indented comment
+PARAM2 = bar baz  # an inline comment
+export PARAM3=trout
+PARAM4=
+PARAM5=no#comment
+`
+	expectedResult := []string{
+		"PARAM1=foo",
+		"PARAM2=bar baz",
+		"PARAM3=trout",
+		"PARAM4=",
+		"PARAM5=no#comment",
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnv(strings.NewReader(testData))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, env.Environ())
+}
+
+func TestLoadDotEnvSupportsSingleQuotedValues(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := `PARAM1='foo # bar'
+PARAM2='it\'s $HOME and \\ and \n' # comment
+`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnv(strings.NewReader(testData))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo # bar", env.Getenv("PARAM1"))
+	assert.Equal(t, `it's $HOME and \ and \n`, env.Getenv("PARAM2"))
+}
+
+func TestLoadDotEnvSupportsDoubleQuotedValues(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := `PARAM1="foo # bar"
+PARAM2="say \"hello\"\tand \\ \$HOME\n"
+PARAM3="{a,b} ~ \q"
+`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnv(strings.NewReader(testData))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo # bar", env.Getenv("PARAM1"))
+	assert.Equal(t, "say \"hello\"\tand \\ $HOME\n", env.Getenv("PARAM2"))
+	assert.Equal(t, `{a,b} ~ \q`, env.Getenv("PARAM3"))
+}
+
+func TestLoadDotEnvSupportsMultiLineDoubleQuotedValues(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := "PARAM1=\"line 1\r\nline 2\n\nline 4\"\nPARAM2=foo\n"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnv(strings.NewReader(testData))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "line 1\nline 2\n\nline 4", env.Getenv("PARAM1"))
+	assert.Equal(t, "foo", env.Getenv("PARAM2"))
+}
+
+func TestLoadDotEnvKeepsTrailingWhitespaceInsideMultiLineQuotedValues(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := "PARAM1=\"foo   \nbar\"\nPARAM2='foo\t\nbar'   \n"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnv(strings.NewReader(testData))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo   \nbar", env.Getenv("PARAM1"))
+	assert.Equal(t, "foo\t\nbar", env.Getenv("PARAM2"))
+}
+
+func TestLoadDotEnvExpandsPreviouslyLoadedVariables(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := `BASE=/opt/app
+BIN=$BASE/bin
+LIB="${BASE}/lib:$PARAM1"
+RAW='$BASE'
+ESCAPED=\$BASE
+DEFAULT=${UNKNOWN:-fallback}
+`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnv(strings.NewReader(testData))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/opt/app/bin", env.Getenv("BIN"))
+	assert.Equal(t, "/opt/app/lib:", env.Getenv("LIB"))
+	assert.Equal(t, "$BASE", env.Getenv("RAW"))
+	assert.Equal(t, "$BASE", env.Getenv("ESCAPED"))
+	assert.Equal(t, "fallback", env.Getenv("DEFAULT"))
+}
+
+func TestLoadDotEnvReturnsLineNumberedSyntaxErrors(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := map[string]envish.ErrDotEnvSyntax{
+		"# comment\nPARAM1=foo\nPARAM2\n":       {Line: 3, Reason: "missing '='"},
+		"PARAM1=foo\n1PARAM=bar\n":              {Line: 2, Reason: "invalid variable name '1PARAM'"},
+		"PARAM1=foo\n=bar\n":                    {Line: 2, Reason: "invalid variable name ''"},
+		"PARAM1=\"foo\n\nbar\n":                 {Line: 1, Reason: "missing closing quote"},
+		"PARAM1=foo\nPARAM2='bar' baz\n":        {Line: 2, Reason: "unexpected text after closing quote"},
+		"PARAM1=foo\nPARAM2=\"a\nb\"c\nPARAM3=": {Line: 2, Reason: "unexpected text after closing quote"},
+	}
+
+	for input, expectedError := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		_, err := envish.LoadDotEnv(strings.NewReader(input))
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedError, err, input)
+	}
+}
+
+func TestLoadDotEnvReturnsLineNumberedExpansionErrors(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := "PARAM1=foo\n\nPARAM2=${PARAM3:?must be set}\n"
+	expectedError := envish.ErrDotEnvExpansion{
+		Line: 3,
+		Err:  envish.ErrUnsetVariable{Name: "PARAM3", Message: "must be set"},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := envish.LoadDotEnv(strings.NewReader(testData))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+
+	var unsetErr envish.ErrUnsetVariable
+	assert.True(t, errors.As(err, &unsetErr))
+}
+
+func TestLoadDotEnvReportsUnclosedBraces(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := "PARAM1=\"${PARAM2\"\n"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := envish.LoadDotEnv(strings.NewReader(testData))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	var badSub envish.ErrBadSubstitution
+	assert.True(t, errors.As(err, &badSub))
+}
+
+func TestLoadDotEnvFileReadsTheGivenFile(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	path := filepath.Join(t.TempDir(), ".env")
+	err := os.WriteFile(path, []byte("PARAM1=foo\n"), 0600)
+	assert.Nil(t, err)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnvFile(path)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", env.Getenv("PARAM1"))
+}
+
+func TestLoadDotEnvFileReturnsErrorIfFileDoesNotExist(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	path := filepath.Join(t.TempDir(), "does-not-exist")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnvFile(path)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, env)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadDotEnvExpandsAgainstTheGivenEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("HOME", "/home/stuart")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.ReadDotEnv(env, strings.NewReader("CONFIG=$HOME/.config\n"))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/stuart/.config", env.Getenv("CONFIG"))
+}
+
+func TestReadDotEnvExpandsVariablesWhenExpandVariablesIsTrue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("HOME", "/home/stuart")
+	testData := "PARAM1=$HOME/.config\nPARAM2=\"${HOME}/bin\"\nPARAM3='$HOME'\n"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.ReadDotEnv(env, strings.NewReader(testData), envish.ExpandVariables(true))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/stuart/.config", env.Getenv("PARAM1"))
+	assert.Equal(t, "/home/stuart/bin", env.Getenv("PARAM2"))
+	assert.Equal(t, "$HOME", env.Getenv("PARAM3"))
+}
+
+func TestReadDotEnvLoadsValuesAsIsWhenExpandVariablesIsFalse(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("HOME", "/home/stuart")
+	testData := "PARAM1=$HOME/.config\nPARAM2=\"${HOME}/bin ~ \\$\"\nPARAM3='$HOME'\nPARAM4=cost 5$ # comment\n"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.ReadDotEnv(env, strings.NewReader(testData), envish.ExpandVariables(false))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "$HOME/.config", env.Getenv("PARAM1"))
+	assert.Equal(t, "${HOME}/bin ~ $", env.Getenv("PARAM2"))
+	assert.Equal(t, "$HOME", env.Getenv("PARAM3"))
+	assert.Equal(t, "cost 5$", env.Getenv("PARAM4"))
+}
+
+func TestLoadDotEnvFileAcceptsOptions(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	path := filepath.Join(t.TempDir(), ".env")
+	err := os.WriteFile(path, []byte("PARAM1=${PARAM2:-foo}\n"), 0600)
+	assert.Nil(t, err)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadDotEnvFile(path, envish.ExpandVariables(false))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "${PARAM2:-foo}", env.Getenv("PARAM1"))
+}
+
+func TestReadDotEnvCopesWithNilEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	expectedError := envish.ErrNilPointer{"ReadDotEnv"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.ReadDotEnv(nil, strings.NewReader("PARAM1=foo\n"))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestWriteDotEnvQuotesValuesThatNeedIt(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "/usr/local/bin:/usr/bin")
+	env.Setenv("PARAM2", "hello world")
+	env.Setenv("PARAM3", "")
+	env.Setenv("PARAM4", "say \"$HOME\"\nand C:\\")
+
+	expectedResult := `PARAM1=/usr/local/bin:/usr/bin
+PARAM2="hello world"
+PARAM3=
+PARAM4="say \"\$HOME\"\nand C:\\"
+`
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteDotEnv(&buf, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, buf.String())
+}
+
+func TestWriteDotEnvOutputRoundTripsThroughLoadDotEnv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.SetAsExporter)
+	env1.Setenv("PARAM1", "it's a \"test\" of $HOME and ${HOME:-x}")
+	env1.Setenv("PARAM2", "line 1\r\nline 2\n")
+	env1.Setenv("PARAM3", "{a,b} ~user # not a comment\t\\")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM4", "  leading and trailing spaces  ")
+	env2.Setenv("PARAM1", "shadowed")
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteDotEnv(&buf, stack)
+	assert.Nil(t, err)
+	actualResult, err := envish.LoadDotEnv(&buf)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, stack.Environ(), actualResult.Environ())
+}
+
+func TestWriteDotEnvReturnsErrorForKeysThatCannotBeWritten(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("$#", "2")
+	expectedError := envish.ErrDotEnvKey{"$#"}
+
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteDotEnv(&buf, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestWriteDotEnvCopesWithNilReader(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	expectedError := envish.ErrNilPointer{"WriteDotEnv"}
+
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteDotEnv(&buf, nil)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
@@ -35,7 +35,98 @@
 
 package envish
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrBadSubstitution is returned whenever we're asked to expand a string
+// that contains a `$` or `${...}` that we cannot make sense of
+type ErrBadSubstitution struct {
+	Pos int
+}
+
+func (e ErrBadSubstitution) Error() string {
+	return fmt.Sprintf("bad substitution at position %d", e.Pos)
+}
+
+// ErrBind is returned by Bind whenever it cannot fill in one or more
+// fields of a struct
+type ErrBind struct {
+	Errors []error
+}
+
+func (e ErrBind) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return "cannot bind environment to struct: " + strings.Join(msgs, "; ")
+}
+
+// Is returns true if any of the individual errors that Bind found
+// matches the target. It lets errors.Is look inside a ErrBind.
+func (e ErrBind) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of the individual errors that Bind found that
+// matches the target. It lets errors.As look inside a ErrBind.
+func (e ErrBind) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrDotEnvExpansion is returned whenever we cannot expand a value
+// in a dotenv file
+type ErrDotEnvExpansion struct {
+	Line int
+	Err  error
+}
+
+func (e ErrDotEnvExpansion) Error() string {
+	return fmt.Sprintf("dotenv line %d: %s", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying expansion error
+func (e ErrDotEnvExpansion) Unwrap() error {
+	return e.Err
+}
+
+// ErrDotEnvKey is returned whenever we're asked to write a variable
+// to a dotenv file, and its name cannot be used in a dotenv file
+type ErrDotEnvKey struct {
+	Key string
+}
+
+func (e ErrDotEnvKey) Error() string {
+	return fmt.Sprintf("cannot write variable %q to a dotenv file", e.Key)
+}
+
+// ErrDotEnvSyntax is returned whenever a dotenv file contains something
+// that we cannot parse
+type ErrDotEnvSyntax struct {
+	Line   int
+	Reason string
+}
+
+func (e ErrDotEnvSyntax) Error() string {
+	return fmt.Sprintf("dotenv line %d: syntax error: %s", e.Line, e.Reason)
+}
 
 // ErrEmptyKey is returned whenever we're given a key that is zero-length
 // or only contains whitespace
@@ -54,6 +145,111 @@ func (e ErrEmptyOverlayEnv) Error() string {
 	return fmt.Sprintf("overlay env is empty; %s", e.Method)
 }
 
+// ErrEnvIndexOutOfRange is returned whenever you ask an OverlayEnv to
+// work with an environment that it does not have
+type ErrEnvIndexOutOfRange struct {
+	Method string
+	Index  int
+	Len    int
+}
+
+func (e ErrEnvIndexOutOfRange) Error() string {
+	return fmt.Sprintf("%s: index %d out of range; overlay env has %d environments", e.Method, e.Index, e.Len)
+}
+
+// ErrInvalidArrayIndex is returned whenever we're asked to use an index
+// that the array cannot hold, such as a string index for an indexed array
+type ErrInvalidArrayIndex struct {
+	Key   string
+	Index string
+}
+
+func (e ErrInvalidArrayIndex) Error() string {
+	return fmt.Sprintf("%s[%s]: bad array subscript", e.Key, e.Index)
+}
+
+// ErrInvalidBindTarget is returned whenever Bind is given something that
+// is not a pointer to a struct
+type ErrInvalidBindTarget struct {
+	Type reflect.Type
+}
+
+func (e ErrInvalidBindTarget) Error() string {
+	return fmt.Sprintf("cannot bind environment to %v; need a pointer to a struct", e.Type)
+}
+
+// ErrInvalidKey is returned whenever we're asked to create a variable
+// with a name that the environment's KeyPolicy does not accept
+type ErrInvalidKey struct {
+	Key    string
+	Reason string
+}
+
+func (e ErrInvalidKey) Error() string {
+	return fmt.Sprintf("invalid variable name %q: %s", e.Key, e.Reason)
+}
+
+// ErrInvalidMarshalSource is returned whenever Marshal is given something
+// that is not a struct, or a pointer to a struct
+type ErrInvalidMarshalSource struct {
+	Type reflect.Type
+}
+
+func (e ErrInvalidMarshalSource) Error() string {
+	return fmt.Sprintf("cannot marshal %v into environment; need a struct or a pointer to a struct", e.Type)
+}
+
+// ErrInvalidValue is returned whenever a variable's value cannot be
+// converted into the type that we need
+type ErrInvalidValue struct {
+	Key   string
+	Value string
+	Type  string
+}
+
+func (e ErrInvalidValue) Error() string {
+	return fmt.Sprintf("invalid value %q for %s; expected %s", e.Value, e.Key, e.Type)
+}
+
+// ErrMarshal is returned by Marshal whenever it cannot write one or more
+// fields of a struct into the environment
+type ErrMarshal struct {
+	Errors []error
+}
+
+func (e ErrMarshal) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return "cannot marshal struct into environment: " + strings.Join(msgs, "; ")
+}
+
+// Is returns true if any of the individual errors that Marshal found
+// matches the target. It lets errors.Is look inside a ErrMarshal.
+func (e ErrMarshal) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of the individual errors that Marshal found that
+// matches the target. It lets errors.As look inside a ErrMarshal.
+func (e ErrMarshal) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // ErrNilPointer is returned whenever you call a method on the Env struct
 // with a nil pointer
 type ErrNilPointer struct {
@@ -71,3 +267,146 @@ type ErrNoExporterEnv struct {
 func (e ErrNoExporterEnv) Error() string {
 	return fmt.Sprintf("no exporting environment in OverlayEnv passed to %s", e.Method)
 }
+
+// ErrNoLocalScope is returned whenever you call a method on a ScopedEnv
+// that needs a local scope, and only the global scope remains
+type ErrNoLocalScope struct {
+	Method string
+}
+
+func (e ErrNoLocalScope) Error() string {
+	return fmt.Sprintf("no local scope in ScopedEnv passed to %s", e.Method)
+}
+
+// ErrNULInValue is returned whenever we're asked to set a variable to
+// a value that contains a NUL byte, which cannot be passed to external
+// programs
+type ErrNULInValue struct {
+	Key string
+}
+
+func (e ErrNULInValue) Error() string {
+	return fmt.Sprintf("%s: value contains a NUL byte", e.Key)
+}
+
+// ErrReadOnlyVar is returned whenever we're asked to change a variable
+// that has been marked as read-only
+type ErrReadOnlyVar struct {
+	Key string
+}
+
+func (e ErrReadOnlyVar) Error() string {
+	return fmt.Sprintf("%s: readonly variable", e.Key)
+}
+
+// ErrRequiredVariable is returned whenever a variable must be set,
+// and it is not
+type ErrRequiredVariable struct {
+	Key string
+}
+
+func (e ErrRequiredVariable) Error() string {
+	return fmt.Sprintf("required variable %s is not set", e.Key)
+}
+
+// ErrShiftOutOfRange is returned whenever we're asked to shift more
+// positional parameters than there are
+type ErrShiftOutOfRange struct {
+	Count   int
+	NumArgs int
+}
+
+func (e ErrShiftOutOfRange) Error() string {
+	return fmt.Sprintf("shift count %d out of range; there are %d positional parameters", e.Count, e.NumArgs)
+}
+
+// ErrShellKey is returned whenever we're asked to write a variable as
+// a shell export, and its name is not a valid shell variable name
+type ErrShellKey struct {
+	Key string
+}
+
+func (e ErrShellKey) Error() string {
+	return fmt.Sprintf("cannot write variable %q as a shell export", e.Key)
+}
+
+// ErrShellSetenv is returned whenever we cannot set a variable that
+// we have read from the output of `export -p`
+type ErrShellSetenv struct {
+	Line int
+	Err  error
+}
+
+func (e ErrShellSetenv) Error() string {
+	return fmt.Sprintf("shell exports line %d: %s", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying Setenv error
+func (e ErrShellSetenv) Unwrap() error {
+	return e.Err
+}
+
+// ErrShellSyntax is returned whenever the output of `export -p` contains
+// something that we cannot parse
+type ErrShellSyntax struct {
+	Line   int
+	Reason string
+}
+
+func (e ErrShellSyntax) Error() string {
+	return fmt.Sprintf("shell exports line %d: syntax error: %s", e.Line, e.Reason)
+}
+
+// ErrTxDone is returned whenever you try to change a transaction that
+// has already been committed or rolled back
+type ErrTxDone struct{}
+
+func (e ErrTxDone) Error() string {
+	return "transaction has already been committed or rolled back"
+}
+
+// ErrUnboundVariables is returned whenever string expansion with the
+// NoUnset option refers to variables that are not set
+type ErrUnboundVariables struct {
+	Names []string
+}
+
+func (e ErrUnboundVariables) Error() string {
+	return fmt.Sprintf("unbound variable: %s", strings.Join(e.Names, ", "))
+}
+
+// ErrUnsetVariable is returned whenever string expansion uses `${var:?word}`
+// or `${var?word}`, and the variable is not set
+type ErrUnsetVariable struct {
+	Name    string
+	Message string
+}
+
+func (e ErrUnsetVariable) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("%s: parameter null or not set", e.Name)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Name, e.Message)
+}
+
+// ErrUnsupportedShellDialect is returned whenever we're asked to work
+// with a ShellDialect that we do not know about
+type ErrUnsupportedShellDialect struct {
+	Dialect ShellDialect
+}
+
+func (e ErrUnsupportedShellDialect) Error() string {
+	return fmt.Sprintf("unsupported shell dialect %s", e.Dialect)
+}
+
+// ErrUnsupportedType is returned whenever a struct field has a type that
+// we cannot convert to or from a string
+type ErrUnsupportedType struct {
+	Key  string
+	Type string
+}
+
+func (e ErrUnsupportedType) Error() string {
+	return fmt.Sprintf("unsupported type %s for %s", e.Type, e.Key)
+}
@@ -36,6 +36,8 @@
 package envish_test
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	envish "github.com/ganbarodigital/go_envish/v4"
@@ -95,3 +97,539 @@ func TestErrNilPointer(t *testing.T) {
 
 	assert.Equal(t, expectedResult, actualResult)
 }
+
+func TestErrBadSubstitution(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrBadSubstitution{Pos: 6}
+	expectedResult := "bad substitution at position 6"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrUnsetVariable(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrUnsetVariable{Name: "PARAM1", Message: "must be set"}
+	expectedResult := "PARAM1: must be set"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrUnsetVariableWithoutAMessage(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrUnsetVariable{Name: "PARAM1"}
+	expectedResult := "PARAM1: parameter null or not set"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrShiftOutOfRange(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrShiftOutOfRange{Count: 3, NumArgs: 2}
+	expectedResult := "shift count 3 out of range; there are 2 positional parameters"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrTxDone(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrTxDone{}
+	expectedResult := "transaction has already been committed or rolled back"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrUnboundVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrUnboundVariables{Names: []string{"PARAM1", "PARAM2"}}
+	expectedResult := "unbound variable: PARAM1, PARAM2"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrDotEnvExpansion(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrDotEnvExpansion{Line: 3, Err: envish.ErrBadSubstitution{Pos: 1}}
+	expectedResult := "dotenv line 3: bad substitution at position 1"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+	assert.Equal(t, envish.ErrBadSubstitution{Pos: 1}, testData.Unwrap())
+}
+
+func TestErrDotEnvSyntax(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrDotEnvSyntax{Line: 3, Reason: "missing '='"}
+	expectedResult := "dotenv line 3: syntax error: missing '='"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrDotEnvKey(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrDotEnvKey{Key: "$#"}
+	expectedResult := `cannot write variable "$#" to a dotenv file`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrShellKey(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrShellKey{Key: "$#"}
+	expectedResult := `cannot write variable "$#" as a shell export`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrShellSetenv(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrShellSetenv{Line: 2, Err: envish.ErrReadOnlyVar{Key: "PARAM1"}}
+	expectedResult := "shell exports line 2: PARAM1: readonly variable"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "PARAM1"}, testData.Unwrap())
+}
+
+func TestErrShellSyntax(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrShellSyntax{Line: 2, Reason: "missing closing quote"}
+	expectedResult := "shell exports line 2: syntax error: missing closing quote"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrUnsupportedShellDialect(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrUnsupportedShellDialect{Dialect: envish.ShellDialect(99)}
+	expectedResult := "unsupported shell dialect ShellDialect(99)"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrBind(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrBind{
+		Errors: []error{
+			envish.ErrRequiredVariable{Key: "PARAM1"},
+			envish.ErrInvalidValue{Key: "PARAM2", Value: "foo", Type: "int"},
+		},
+	}
+	expectedResult := `cannot bind environment to struct: required variable PARAM1 is not set; invalid value "foo" for PARAM2; expected int`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrBindWorksWithErrorsIsAndAs(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var testData error = envish.ErrBind{
+		Errors: []error{
+			envish.ErrRequiredVariable{Key: "PARAM1"},
+			envish.ErrInvalidValue{Key: "PARAM2", Value: "foo", Type: "int"},
+		},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	var invalidValue envish.ErrInvalidValue
+	isRequired := errors.Is(testData, envish.ErrRequiredVariable{Key: "PARAM1"})
+	isEmptyKey := errors.Is(testData, envish.ErrEmptyKey{})
+	asInvalidValue := errors.As(testData, &invalidValue)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, isRequired)
+	assert.False(t, isEmptyKey)
+	assert.True(t, asInvalidValue)
+	assert.Equal(t, envish.ErrInvalidValue{Key: "PARAM2", Value: "foo", Type: "int"}, invalidValue)
+}
+
+func TestErrEnvIndexOutOfRange(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrEnvIndexOutOfRange{Method: "TestErrEnvIndexOutOfRange", Index: 3, Len: 2}
+	expectedResult := "TestErrEnvIndexOutOfRange: index 3 out of range; overlay env has 2 environments"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrInvalidArrayIndex(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrInvalidArrayIndex{Key: "ARR", Index: "one"}
+	expectedResult := "ARR[one]: bad array subscript"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrInvalidBindTarget(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrInvalidBindTarget{Type: reflect.TypeOf("")}
+	expectedResult := "cannot bind environment to string; need a pointer to a struct"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrInvalidValue(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrInvalidValue{Key: "PARAM1", Value: "foo", Type: "int"}
+	expectedResult := `invalid value "foo" for PARAM1; expected int`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrNoLocalScope(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrNoLocalScope{Method: "ScopedEnv.PopScope"}
+	expectedResult := "no local scope in ScopedEnv passed to ScopedEnv.PopScope"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrNULInValue(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrNULInValue{Key: "PARAM1"}
+	expectedResult := "PARAM1: value contains a NUL byte"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrReadOnlyVar(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrReadOnlyVar{Key: "PARAM1"}
+	expectedResult := "PARAM1: readonly variable"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrRequiredVariable(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrRequiredVariable{Key: "PARAM1"}
+	expectedResult := "required variable PARAM1 is not set"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrInvalidKey(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrInvalidKey{Key: "1PARAM", Reason: "name must start with a letter or an underscore"}
+	expectedResult := `invalid variable name "1PARAM": name must start with a letter or an underscore`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrInvalidMarshalSource(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrInvalidMarshalSource{Type: reflect.TypeOf(0)}
+	expectedResult := "cannot marshal int into environment; need a struct or a pointer to a struct"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrMarshal(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrMarshal{
+		Errors: []error{
+			envish.ErrUnsupportedType{Key: "PARAM1", Type: "chan int"},
+			envish.ErrEmptyKey{},
+		},
+	}
+	expectedResult := "cannot marshal struct into environment: unsupported type chan int for PARAM1; zero-length key, or key only contains whitespace"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestErrMarshalWorksWithErrorsIsAndAs(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var testData error = envish.ErrMarshal{
+		Errors: []error{
+			envish.ErrUnsupportedType{Key: "PARAM1", Type: "chan int"},
+			envish.ErrEmptyKey{},
+		},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	var unsupportedType envish.ErrUnsupportedType
+	isEmptyKey := errors.Is(testData, envish.ErrEmptyKey{})
+	isReadOnly := errors.Is(testData, envish.ErrReadOnlyVar{Key: "PARAM1"})
+	asUnsupportedType := errors.As(testData, &unsupportedType)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, isEmptyKey)
+	assert.False(t, isReadOnly)
+	assert.True(t, asUnsupportedType)
+	assert.Equal(t, envish.ErrUnsupportedType{Key: "PARAM1", Type: "chan int"}, unsupportedType)
+}
+
+func TestErrUnsupportedType(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := envish.ErrUnsupportedType{Key: "PARAM1", Type: "chan int"}
+	expectedResult := "unsupported type chan int for PARAM1"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := testData.Error()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
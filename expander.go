@@ -43,3 +43,17 @@ type Expander interface {
 	// Expand replaces ${var} or $var in the input string.
 	Expand(fmt string) string
 }
+
+// CheckedExpander is the interface that wraps a key/value store that
+// supports string expansion, and tells you when that expansion fails.
+type CheckedExpander interface {
+	Expander
+
+	// ExpandE replaces ${var} or $var in the input string. It returns
+	// an error if the input string cannot be expanded.
+	ExpandE(fmt string) (string, error)
+
+	// ExpandWith works like ExpandE, using the given options to decide
+	// what counts as an error.
+	ExpandWith(fmt string, options ExpandOptions) (string, error)
+}
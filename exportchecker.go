@@ -0,0 +1,80 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+// ExportChecker is the interface that wraps support for exporting
+// individual variables.
+//
+// OverlayEnv uses it to find out which variables to include when it
+// builds its Environ.
+type ExportChecker interface {
+	// AllVars returns a copy of all entries in the form "key=value",
+	// whether they have been exported or not.
+	AllVars() []string
+
+	// IsExported returns true if the variable named by the key should
+	// be passed to external programs.
+	IsExported(key string) bool
+}
+
+// environLister is implemented by environments whose Environ includes
+// variables that they do not export, such as LocalEnv
+type environLister interface {
+	isInEnviron(key string) bool
+}
+
+// isInEnvironOf returns true if the given environment's Environ includes
+// the variable named by the key (once it is set)
+func isInEnvironOf(env Reader, key string) bool {
+	lister, ok := env.(environLister)
+	if ok {
+		return lister.isInEnviron(key)
+	}
+
+	return isExportedBy(env, key)
+}
+
+// varExporter is implemented by environments that can export individual
+// variables
+type varExporter interface {
+	Export(key string) error
+}
+
+// varUnexporter is implemented by environments that can stop exporting
+// individual variables
+type varUnexporter interface {
+	Unexport(key string) error
+}
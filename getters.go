@@ -0,0 +1,225 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// GetBool returns the value of the variable named by the key, as a bool.
+//
+// It accepts 1/0, t/f, true/false, y/n, yes/no and on/off, in any case.
+//
+// It returns ErrRequiredVariable if the variable is not set (or is set to
+// an empty string), and ErrInvalidValue if the value is not a bool.
+func GetBool(r Reader, key string) (bool, error) {
+	var retval bool
+	err := getValue("GetBool", r, key, ",", &retval)
+	return retval, err
+}
+
+// GetOrBool returns the value of the variable named by the key, as a
+// bool.
+//
+// It returns defaultValue if the variable is not set (or is set to an
+// empty string). If the value is not a bool, it returns defaultValue
+// and an ErrInvalidValue.
+func GetOrBool(r Reader, key string, defaultValue bool) (bool, error) {
+	retval, err := GetBool(r, key)
+	return getOrDefault(retval, defaultValue, err).(bool), ignoreUnset(err)
+}
+
+// GetDuration returns the value of the variable named by the key, as a
+// time.Duration.
+//
+// The value must be in a format that time.ParseDuration() understands.
+//
+// It returns ErrRequiredVariable if the variable is not set (or is set to
+// an empty string), and ErrInvalidValue if the value is not a duration.
+func GetDuration(r Reader, key string) (time.Duration, error) {
+	var retval time.Duration
+	err := getValue("GetDuration", r, key, ",", &retval)
+	return retval, err
+}
+
+// GetOrDuration returns the value of the variable named by the key, as a
+// time.Duration.
+//
+// It returns defaultValue if the variable is not set (or is set to an
+// empty string). If the value is not a duration, it returns defaultValue
+// and an ErrInvalidValue.
+func GetOrDuration(r Reader, key string, defaultValue time.Duration) (time.Duration, error) {
+	retval, err := GetDuration(r, key)
+	return getOrDefault(retval, defaultValue, err).(time.Duration), ignoreUnset(err)
+}
+
+// GetFloat returns the value of the variable named by the key, as a
+// float64.
+//
+// It returns ErrRequiredVariable if the variable is not set (or is set to
+// an empty string), and ErrInvalidValue if the value is not a number.
+func GetFloat(r Reader, key string) (float64, error) {
+	var retval float64
+	err := getValue("GetFloat", r, key, ",", &retval)
+	return retval, err
+}
+
+// GetOrFloat returns the value of the variable named by the key, as a
+// float64.
+//
+// It returns defaultValue if the variable is not set (or is set to an
+// empty string). If the value is not a number, it returns defaultValue
+// and an ErrInvalidValue.
+func GetOrFloat(r Reader, key string, defaultValue float64) (float64, error) {
+	retval, err := GetFloat(r, key)
+	return getOrDefault(retval, defaultValue, err).(float64), ignoreUnset(err)
+}
+
+// GetInt returns the value of the variable named by the key, as an int.
+//
+// The value must be a base 10 number. Leading and trailing whitespace
+// is ignored.
+//
+// It returns ErrRequiredVariable if the variable is not set (or is set to
+// an empty string), and ErrInvalidValue if the value is not an int.
+func GetInt(r Reader, key string) (int, error) {
+	var retval int
+	err := getValue("GetInt", r, key, ",", &retval)
+	return retval, err
+}
+
+// GetOrInt returns the value of the variable named by the key, as an int.
+//
+// It returns defaultValue if the variable is not set (or is set to an
+// empty string). If the value is not an int, it returns defaultValue
+// and an ErrInvalidValue.
+func GetOrInt(r Reader, key string, defaultValue int) (int, error) {
+	retval, err := GetInt(r, key)
+	return getOrDefault(retval, defaultValue, err).(int), ignoreUnset(err)
+}
+
+// GetStringSlice returns the value of the variable named by the key,
+// split up into a slice using sep. Leading and trailing whitespace is
+// removed from each entry.
+//
+// It returns ErrRequiredVariable if the variable is not set (or is set to
+// an empty string).
+func GetStringSlice(r Reader, key string, sep string) ([]string, error) {
+	var retval []string
+	err := getValue("GetStringSlice", r, key, sep, &retval)
+	return retval, err
+}
+
+// GetOrStringSlice returns the value of the variable named by the key,
+// split up into a slice using sep.
+//
+// It returns defaultValue if the variable is not set (or is set to an
+// empty string).
+func GetOrStringSlice(r Reader, key string, sep string, defaultValue []string) ([]string, error) {
+	retval, err := GetStringSlice(r, key, sep)
+	return getOrDefault(retval, defaultValue, err).([]string), ignoreUnset(err)
+}
+
+// GetURL returns the value of the variable named by the key, as a
+// parsed URL.
+//
+// It returns ErrRequiredVariable if the variable is not set (or is set to
+// an empty string), and ErrInvalidValue if the value is not a URL.
+func GetURL(r Reader, key string) (*url.URL, error) {
+	var retval *url.URL
+	err := getValue("GetURL", r, key, ",", &retval)
+	return retval, err
+}
+
+// GetOrURL returns the value of the variable named by the key, as a
+// parsed URL.
+//
+// It returns defaultValue if the variable is not set (or is set to an
+// empty string). If the value is not a URL, it returns defaultValue
+// and an ErrInvalidValue.
+func GetOrURL(r Reader, key string, defaultValue *url.URL) (*url.URL, error) {
+	retval, err := GetURL(r, key)
+	return getOrDefault(retval, defaultValue, err).(*url.URL), ignoreUnset(err)
+}
+
+// getValue looks up the given key, and converts its value into
+// whatever type target points to
+//
+// it uses the same rules as Bind()
+func getValue(caller string, r Reader, key string, sep string, target interface{}) error {
+	// do we have an environment to work with?
+	if r == nil {
+		return ErrNilPointer{caller}
+	}
+
+	// do we have a value to convert?
+	value, ok := r.LookupEnv(key)
+	if !ok || len(value) == 0 {
+		return ErrRequiredVariable{Key: key}
+	}
+
+	// yes we do
+	v := reflect.ValueOf(target).Elem()
+	if !parseValue(v, value, sep) {
+		v.Set(reflect.Zero(v.Type()))
+		return ErrInvalidValue{Key: key, Value: value, Type: typeName(v.Type())}
+	}
+
+	// all done
+	return nil
+}
+
+// getOrDefault returns defaultValue if we could not get a value
+// from the environment
+func getOrDefault(value interface{}, defaultValue interface{}, err error) interface{} {
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// ignoreUnset filters out the error that the GetOrXXX() functions
+// replace with a default value
+func ignoreUnset(err error) error {
+	if _, ok := err.(ErrRequiredVariable); ok {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,451 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// GetBool
+//
+// ----------------------------------------------------------------
+
+func TestGetBoolUnderstandsCommonSpellings(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := map[string]bool{
+		"1":     true,
+		"t":     true,
+		"TRUE":  true,
+		"y":     true,
+		"Yes":   true,
+		" on ":  true,
+		"0":     false,
+		"F":     false,
+		"false": false,
+		"n":     false,
+		"NO":    false,
+		"off":   false,
+	}
+
+	for value, expectedResult := range testData {
+		env := envish.NewLocalEnv()
+		env.Setenv("PARAM1", value)
+
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult, err := envish.GetBool(env, "PARAM1")
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Nil(t, err, value)
+		assert.Equal(t, expectedResult, actualResult, value)
+	}
+}
+
+func TestGetBoolReturnsErrInvalidValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "maybe")
+
+	expectedError := envish.ErrInvalidValue{Key: "PARAM1", Value: "maybe", Type: "bool"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetBool(env, "PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.False(t, actualResult)
+}
+
+func TestGetOrBoolReturnsDefaultWhenVariableNotSet(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM2", "")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult1, err1 := envish.GetOrBool(env, "PARAM1", true)
+	actualResult2, err2 := envish.GetOrBool(env, "PARAM2", true)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err1)
+	assert.True(t, actualResult1)
+	assert.Nil(t, err2)
+	assert.True(t, actualResult2)
+}
+
+// ================================================================
+//
+// GetDuration
+//
+// ----------------------------------------------------------------
+
+func TestGetDurationParsesValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "1m30s")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetDuration(env, "PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Second, actualResult)
+}
+
+func TestGetOrDurationReturnsDefaultAndErrorForInvalidValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "90")
+
+	expectedError := envish.ErrInvalidValue{Key: "PARAM1", Value: "90", Type: "time.Duration"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetOrDuration(env, "PARAM1", time.Second)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, time.Second, actualResult)
+}
+
+// ================================================================
+//
+// GetFloat
+//
+// ----------------------------------------------------------------
+
+func TestGetFloatParsesValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "0.75")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetFloat(env, "PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0.75, actualResult)
+}
+
+func TestGetOrFloatReturnsDefaultWhenVariableNotSet(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetOrFloat(env, "PARAM1", 1.5)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, actualResult)
+}
+
+// ================================================================
+//
+// GetInt
+//
+// ----------------------------------------------------------------
+
+func TestGetIntParsesValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", " 0042 ")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetInt(env, "PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, 42, actualResult)
+}
+
+func TestGetIntReturnsErrRequiredVariableWhenVariableNotSet(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	expectedError := envish.ErrRequiredVariable{Key: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetInt(env, "PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, 0, actualResult)
+}
+
+func TestGetOrIntReturnsDefaultAndErrorForInvalidValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "12abc")
+
+	expectedError := envish.ErrInvalidValue{Key: "PARAM1", Value: "12abc", Type: "int"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetOrInt(env, "PARAM1", 100)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, 100, actualResult)
+}
+
+func TestGetIntCopesWithNilReader(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	expectedError := envish.ErrNilPointer{"GetInt"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := envish.GetInt(nil, "PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+// ================================================================
+//
+// GetStringSlice
+//
+// ----------------------------------------------------------------
+
+func TestGetStringSliceSplitsValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "a; b ;c")
+
+	expectedResult := []string{"a", "b", "c"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetStringSlice(env, "PARAM1", ";")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestGetOrStringSliceReturnsDefaultWhenVariableNotSet(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	expectedResult := []string{"x"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetOrStringSlice(env, "PARAM1", ",", expectedResult)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+// ================================================================
+//
+// GetURL
+//
+// ----------------------------------------------------------------
+
+func TestGetURLParsesValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "https://example.com:8443/api")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetURL(env, "PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com:8443", actualResult.Host)
+	assert.Equal(t, "/api", actualResult.Path)
+}
+
+func TestGetOrURLReturnsDefaultAndErrorForInvalidValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "http://[::1")
+
+	defaultValue, _ := url.Parse("http://localhost")
+	expectedError := envish.ErrInvalidValue{Key: "PARAM1", Value: "http://[::1", Type: "url.URL"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetOrURL(env, "PARAM1", defaultValue)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, defaultValue, actualResult)
+}
+
+func TestGetURLWorksWithOverlayEnv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	defaults := envish.NewLocalEnv()
+	defaults.Setenv("PARAM1", "http://defaults")
+	overrides := envish.NewLocalEnv()
+	overrides.Setenv("PARAM1", "http://overrides")
+	env := envish.NewOverlayEnv([]envish.Expander{overrides, defaults})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := envish.GetURL(env, "PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "overrides", actualResult.Host)
+}
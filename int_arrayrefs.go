@@ -0,0 +1,455 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"strconv"
+	"strings"
+
+	shellexpand "github.com/ganbarodigital/go_shellexpand"
+)
+
+// arrayRefs supports array expansion (eg `${arr[1]}`), which shellexpand
+// does not understand.
+//
+// Before we expand a string, we replace each array reference with a
+// placeholder variable. We then answer lookups for the placeholders
+// ourselves, and pass everything else through to the environment.
+//
+// The placeholders' names never appear in the string, and are not
+// used by any variable in the environment, so they cannot hide a real
+// variable.
+type arrayRefs struct {
+	// env is the environment that we are expanding against
+	env Expander
+
+	// prefix is what all of our placeholder names start with
+	prefix string
+
+	// tx holds any changes made while we expand subscripts, such as
+	// `${arr[${i:=1}]}`, until we know that the whole string can be
+	// expanded
+	tx *Tx
+
+	// placeholders holds the array references that we have replaced,
+	// indexed by their placeholder variable name
+	placeholders map[string]*arrayRef
+
+	// edits holds the array references that we have replaced, in the
+	// order they appear in the string
+	edits []*arrayRef
+}
+
+// arrayRef is a single array reference that we have replaced with a
+// placeholder
+type arrayRef struct {
+	// key is the name of the array
+	key string
+
+	// index is the subscript, after it has been expanded
+	index string
+
+	// op is the length ('#') or list of keys ('!') prefix, for
+	// references to the whole array
+	op byte
+
+	// canAssign is false for references to the whole array
+	canAssign bool
+
+	// placeholder is the variable name that we replaced the reference with
+	placeholder string
+
+	// original is the text that we replaced
+	original string
+
+	// oldPos is where original starts in the original string
+	oldPos int
+
+	// newPos is where placeholder starts in the rewritten string
+	newPos int
+}
+
+// rewriteArrayRefs replaces all of the array references in the input
+// string with placeholders
+func rewriteArrayRefs(e Expander, input string) (string, *arrayRefs, error) {
+	retval := &arrayRefs{
+		env:          e,
+		placeholders: map[string]*arrayRef{},
+	}
+
+	// special case - no array references at all
+	if !strings.Contains(input, "[") {
+		return input, retval, nil
+	}
+
+	// we need placeholders that cannot be mistaken for anything else
+	retval.prefix = placeholderPrefix(e, input)
+
+	var buf strings.Builder
+	inEscape := false
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		buf.WriteByte(c)
+
+		// skip over escaped characters
+		if inEscape {
+			inEscape = false
+			continue
+		}
+		if c == '\\' {
+			inEscape = true
+			continue
+		}
+
+		// we only care about `${`
+		if c != '$' || i+1 >= len(input) || input[i+1] != '{' {
+			continue
+		}
+		buf.WriteByte('{')
+		i++
+
+		// is it an array reference?
+		ref, end, err := retval.parseRef(input, i+1)
+		if err != nil {
+			return "", nil, err
+		}
+		if ref == nil {
+			continue
+		}
+
+		// yes it is
+		//
+		// we keep any prefix that the placeholder still needs
+		buf.WriteString(input[i+1 : ref.oldPos])
+		ref.newPos = buf.Len()
+		buf.WriteString(ref.placeholder)
+		i = end - 1
+	}
+
+	// all done
+	return buf.String(), retval, nil
+}
+
+// parseRef looks for an array reference at the given position, which
+// is just after a `${`. It returns nil if there isn't one.
+//
+// end is the position just after the array reference's closing ']'.
+func (r *arrayRefs) parseRef(input string, start int) (*arrayRef, int, error) {
+	// skip over any length or indirection prefix
+	i := start
+	prefix := byte(0)
+	if i < len(input) && (input[i] == '#' || input[i] == '!') {
+		prefix = input[i]
+		i++
+	}
+
+	// we need a name ...
+	nameStart := i
+	if i >= len(input) || !isNameStartChar(input[i]) {
+		return nil, 0, nil
+	}
+	for i < len(input) && isNameBodyChar(input[i]) {
+		i++
+	}
+	key := input[nameStart:i]
+
+	// ... followed by a subscript
+	if i >= len(input) || input[i] != '[' {
+		return nil, 0, nil
+	}
+	subEnd := matchClosingBracket(input, i)
+	if subEnd < 0 {
+		return nil, 0, nil
+	}
+	index := input[i+1 : subEnd]
+	end := subEnd + 1
+
+	ref := &arrayRef{
+		key:         key,
+		index:       index,
+		placeholder: r.prefix + strconv.Itoa(len(r.edits)),
+	}
+
+	// we always replace the name and subscript
+	//
+	// for whole-array references, we replace the prefix too
+	ref.oldPos = nameStart
+	switch {
+	case index == "@" || index == "*":
+		if prefix != 0 {
+			ref.oldPos = start
+			ref.op = prefix
+		}
+	default:
+		// the subscript can contain variables too
+		//
+		// any assignments are kept in a Tx, until we know whether the
+		// rest of the string can be expanded
+		if strings.ContainsRune(index, '$') {
+			if r.tx == nil {
+				r.tx = newTx(r.env)
+			}
+			expanded, err := expandWith(r.tx, index, ExpandOptions{})
+			if err != nil {
+				return nil, 0, err
+			}
+			ref.index = expanded
+		}
+		ref.canAssign = true
+	}
+	ref.original = input[ref.oldPos:end]
+
+	// remember what we have done
+	r.placeholders[ref.placeholder] = ref
+	r.edits = append(r.edits, ref)
+
+	// all done
+	return ref, end, nil
+}
+
+// lookupArray returns the array named by the key
+//
+// ordinary variables are treated as an array with a single element,
+// just like bash does
+func (r *arrayRefs) lookupArray(key string) (arrayVar, bool) {
+	env := r.view()
+
+	// does our environment support arrays?
+	reader, ok := env.(arrayReader)
+	if ok {
+		arr, ok := reader.lookupArray(key)
+		if ok {
+			return arr, true
+		}
+	}
+
+	// no, it does not
+	value, ok := env.LookupEnv(key)
+	if !ok {
+		return newIndexedArray(nil), false
+	}
+
+	return newIndexedArray([]string{value}), true
+}
+
+// view returns the environment to read from
+//
+// it includes any changes made while expanding subscripts, even if they
+// have not been committed yet
+func (r *arrayRefs) view() Expander {
+	if r.tx != nil {
+		return r.tx
+	}
+
+	return r.env
+}
+
+// commit makes any changes that were made while expanding subscripts
+//
+// call it once you know that the whole string can be expanded
+func (r *arrayRefs) commit() error {
+	// special case - nothing to commit
+	if r.tx == nil {
+		return nil
+	}
+
+	// from here on, we read from the environment directly
+	tx := r.tx
+	r.tx = nil
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	return tx.Commit()
+}
+
+// callbacks tells shellexpand how to work with our placeholders
+func (r *arrayRefs) callbacks() shellexpand.ExpansionCallbacks {
+	return shellexpand.ExpansionCallbacks{
+		AssignToVar:   r.assignToVar,
+		LookupHomeDir: LookupHomeDir,
+		LookupVar:     r.lookupVar,
+		MatchVarNames: r.env.MatchVarNames,
+	}
+}
+
+// lookupVar returns the value of the given variable or placeholder
+//
+// placeholders are looked up every time, so that they see any changes
+// made earlier in the same string (eg `${arr[1]:=foo}${arr[1]}`)
+func (r *arrayRefs) lookupVar(key string) (string, bool) {
+	ref, ok := r.placeholders[key]
+	if ok {
+		arr, _ := r.lookupArray(ref.key)
+		return ref.valueIn(arr)
+	}
+
+	return r.view().LookupEnv(key)
+}
+
+// assignToVar sets the value of the given variable or placeholder
+func (r *arrayRefs) assignToVar(key, value string) error {
+	ref, ok := r.placeholders[key]
+	if !ok {
+		return r.env.Setenv(key, value)
+	}
+
+	// can we assign to this placeholder?
+	setter, ok := r.env.(elementSetter)
+	if !ok || !ref.canAssign {
+		return ErrBadSubstitution{Pos: ref.oldPos}
+	}
+
+	// yes we can
+	return setter.SetElement(ref.key, ref.index, value)
+}
+
+// valueIn returns what the placeholder expands to, using the given
+// array
+//
+// the returned boolean is false if the array element does not exist
+func (ref *arrayRef) valueIn(arr arrayVar) (string, bool) {
+	// is it a reference to a single element?
+	if ref.canAssign {
+		return arr.get(ref.index)
+	}
+
+	// no, it is a reference to the whole array
+	switch ref.op {
+	case '#':
+		return strconv.Itoa(len(arr.elems)), true
+	case '!':
+		return strings.Join(arr.keys(), " "), true
+	default:
+		return strings.Join(arr.values(), " "), len(arr.elems) > 0
+	}
+}
+
+// restore puts the original array references back into the given
+// string
+func (r *arrayRefs) restore(input string) string {
+	// we work backwards, so that placeholder 1 doesn't replace the
+	// start of placeholder 10
+	for i := len(r.edits) - 1; i >= 0; i-- {
+		input = strings.Replace(input, r.edits[i].placeholder, r.edits[i].original, -1)
+	}
+
+	return input
+}
+
+// oldPos converts a position in the rewritten string back into a
+// position in the original string
+func (r *arrayRefs) oldPos(pos int) int {
+	retval := pos
+	for _, edit := range r.edits {
+		newEnd := edit.newPos + len(edit.placeholder)
+		switch {
+		case pos >= newEnd:
+			retval = pos + (edit.oldPos + len(edit.original)) - newEnd
+		case pos >= edit.newPos:
+			return edit.oldPos
+		}
+	}
+
+	return retval
+}
+
+// fixError makes sure that any error refers to the original string,
+// and not to our placeholders
+func (r *arrayRefs) fixError(err error) error {
+	// special case - nothing to fix
+	if len(r.edits) == 0 {
+		return err
+	}
+
+	switch typedErr := err.(type) {
+	case ErrBadSubstitution:
+		typedErr.Pos = r.oldPos(typedErr.Pos)
+		return typedErr
+	case ErrUnsetVariable:
+		typedErr.Name = r.restore(typedErr.Name)
+		typedErr.Message = r.restore(typedErr.Message)
+		return typedErr
+	case ErrUnboundVariables:
+		names := make([]string, len(typedErr.Names))
+		for i, name := range typedErr.Names {
+			names[i] = r.restore(name)
+		}
+		typedErr.Names = names
+		return typedErr
+	}
+
+	return err
+}
+
+// placeholderPrefix returns a prefix for placeholder names that does
+// not appear anywhere in the input string, and that no variable in the
+// environment starts with
+func placeholderPrefix(e Reader, input string) string {
+	for i := 0; ; i++ {
+		prefix := "__envish_array_"
+		if i > 0 {
+			prefix = "__envish_array" + strconv.Itoa(i) + "_"
+		}
+
+		if !strings.Contains(input, prefix) && len(e.MatchVarNames(prefix)) == 0 {
+			return prefix
+		}
+	}
+}
+
+// matchClosingBracket returns the position of the ']' that closes the
+// '[' at the given start position, or -1 if there isn't one
+func matchClosingBracket(input string, start int) int {
+	depth := 0
+	for i := start; i < len(input); i++ {
+		switch input[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				if input[i] == ']' {
+					return i
+				}
+				return -1
+			}
+		}
+	}
+
+	// if we get here, the bracket was never closed
+	return -1
+}
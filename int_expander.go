@@ -47,15 +47,19 @@ func expand(e Expander, fmt string) string {
 	}
 
 	// yes we do
-	cb := shellexpand.ExpansionCallbacks{
-		AssignToVar:   e.Setenv,
-		LookupHomeDir: LookupHomeDir,
-		LookupVar:     e.LookupEnv,
-		MatchVarNames: e.MatchVarNames,
+	//
+	// shellexpand does not understand arrays
+	input, arrays, err := rewriteArrayRefs(e, fmt)
+	if err != nil {
+		return fmt
+	}
+	err = arrays.commit()
+	if err != nil {
+		return fmt
 	}
 
 	// attempt full-on shell expansion
-	retval, err := shellexpand.Expand(fmt, cb)
+	retval, err := shellExpand(input, arrays.callbacks())
 
 	// did it work?
 	if err != nil {
@@ -65,3 +69,189 @@ func expand(e Expander, fmt string) string {
 	// yes it did :)
 	return retval
 }
+
+// expandE replaces ${var} or $var in the input string, and tells you
+// if that went wrong.
+func expandE(e Expander, fmt string) (string, error) {
+	return expandWith(e, fmt, ExpandOptions{})
+}
+
+// expandWith replaces ${var} or $var in the input string, using the
+// given options to decide what counts as an error.
+func expandWith(e Expander, fmt string, options ExpandOptions) (string, error) {
+	// do we have an environment to work with?
+	if e == nil {
+		return fmt, nil
+	}
+
+	// yes we do
+	//
+	// shellexpand does not understand arrays
+	input, arrays, err := rewriteArrayRefs(e, fmt)
+	if err != nil {
+		return "", err
+	}
+
+	// shellexpand quietly ignores a lot of problems, so we look for
+	// them first
+	checker := newExpansionChecker(arrays.lookupVar, options)
+	err = checker.check(input, 0)
+	if err != nil {
+		return "", arrays.fixError(err)
+	}
+
+	// we report every unbound variable at once
+	if len(checker.unbound) > 0 {
+		return "", arrays.fixError(ErrUnboundVariables{Names: checker.unbound})
+	}
+
+	// it is now safe to make any assignments in the array subscripts
+	err = arrays.commit()
+	if err != nil {
+		return "", err
+	}
+
+	// attempt full-on shell expansion
+	retval, err := shellExpand(input, arrays.callbacks())
+	if err != nil {
+		return "", arrays.fixError(err)
+	}
+
+	return retval, nil
+}
+
+// shellExpand uses shellexpand to expand the input string
+//
+// shellexpand cannot cope with every input string, so we rewrite the
+// input string into a form that it can cope with first (see
+// paramNormaliser). If it still panics, we return ErrBadSubstitution
+// instead.
+func shellExpand(input string, cb shellexpand.ExpansionCallbacks) (retval string, err error) {
+	defer func() {
+		if recover() != nil {
+			retval, err = "", ErrBadSubstitution{}
+		}
+	}()
+
+	return shellexpand.Expand(normaliseParams(input, cb.LookupVar), cb)
+}
+
+// expansionChecker looks for problems in a string before we expand it
+type expansionChecker struct {
+	// lookupVar is how we find the current value of a variable
+	lookupVar func(string) (string, bool)
+
+	// assigned tracks any `${var:=word}` assignments that the expansion
+	// will make, so that we don't complain about them later on
+	assigned map[string]string
+
+	// options tells us what counts as an error
+	options ExpandOptions
+
+	// unbound is the list of variables that must be set, but aren't
+	unbound []string
+}
+
+func newExpansionChecker(lookupVar func(string) (string, bool), options ExpandOptions) *expansionChecker {
+	return &expansionChecker{
+		lookupVar: lookupVar,
+		assigned:  map[string]string{},
+		options:   options,
+	}
+}
+
+// addUnbound remembers that the given variable must be set, but isn't
+func (c *expansionChecker) addUnbound(name string) {
+	// we only report each variable once
+	for _, unbound := range c.unbound {
+		if unbound == name {
+			return
+		}
+	}
+
+	c.unbound = append(c.unbound, name)
+}
+
+// lookup returns the value that the given variable will have at this
+// point in the expansion
+func (c *expansionChecker) lookup(key string) (string, bool) {
+	value, ok := c.assigned[key]
+	if ok {
+		return value, true
+	}
+
+	return c.lookupVar(key)
+}
+
+// check returns an error if expanding the given input string would
+// go wrong
+//
+// offset is the position of input inside the original string
+func (c *expansionChecker) check(input string, offset int) error {
+	refs, err := scanParams(input, offset)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		// some expansions don't look up a variable at all
+		if !ref.isVar {
+			continue
+		}
+
+		key := ref.lookupKey()
+		value, isSet := c.lookup(key)
+		isNull := !isSet || len(value) == 0
+
+		switch ref.op {
+		case ":-", ":=":
+			if !isNull {
+				continue
+			}
+		case "-", "=":
+			if isSet {
+				continue
+			}
+		case ":+":
+			if isNull {
+				continue
+			}
+		case "+":
+			if !isSet {
+				continue
+			}
+		case ":?":
+			if isNull {
+				return ErrUnsetVariable{Name: ref.name, Message: ref.word}
+			}
+			continue
+		case "?":
+			if !isSet {
+				return ErrUnsetVariable{Name: ref.name, Message: ref.word}
+			}
+			continue
+		default:
+			// the variable's value is used as-is
+			//
+			// just like UNIX shells, `set -u` does not apply to $@ and $*
+			if c.options.NoUnset && !isSet && ref.name != "@" && ref.name != "*" {
+				c.addUnbound(ref.name)
+			}
+			continue
+		}
+
+		// if we get here, the word after the operator will be expanded
+		err = c.check(ref.word, ref.wordPos)
+		if err != nil {
+			return err
+		}
+
+		// remember any assignments
+		if ref.op == ":=" || ref.op == "=" {
+			c.assigned[key] = ref.word
+		}
+	}
+
+	// all done
+	return nil
+}
@@ -0,0 +1,463 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"strings"
+)
+
+// paramRef describes a single `$var` or `${var...}` found in a string
+// that is due to be expanded
+type paramRef struct {
+	// pos is the position of the leading '$' in the original string
+	pos int
+
+	// name is the parameter's name, as the user wrote it
+	name string
+
+	// op is the parameter expansion operator (e.g. ":-"), if there is one
+	op string
+
+	// word is whatever comes after the operator
+	word string
+
+	// wordPos is the position of word in the original string
+	wordPos int
+
+	// isVar is false for expansions that do not look up a variable,
+	// such as `${!prefix*}`
+	isVar bool
+}
+
+// lookupKey returns the key that shellexpand will ask our LookupEnv for
+//
+// shell special params and positional params keep their leading '$'
+func (p paramRef) lookupKey() string {
+	if isShellName(p.name) {
+		return p.name
+	}
+
+	return "$" + p.name
+}
+
+// paramOps are the parameter expansion operators that we need to
+// understand, to work out whether a variable must be set
+//
+// longest operators must come first
+var paramOps = []string{":-", ":=", ":?", ":+", "-", "=", "?", "+"}
+
+// scanParams finds all of the top-level parameter expansions in the
+// given input string.
+//
+// offset is added to every position that we report, so that we can
+// scan the words inside parameter expansions and still report where
+// they are in the original string.
+func scanParams(input string, offset int) ([]paramRef, error) {
+	// our return value
+	retval := []paramRef{}
+
+	inEscape := false
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+
+		// skip over escaped characters
+		if inEscape {
+			inEscape = false
+			continue
+		}
+		if c == '\\' {
+			inEscape = true
+			continue
+		}
+
+		// we only care about parameters
+		if c != '$' {
+			continue
+		}
+
+		// a trailing '$' is just a '$', same as in a UNIX shell
+		if i == len(input)-1 {
+			continue
+		}
+
+		// is it wrapped in braces?
+		if input[i+1] == '{' {
+			end := matchClosingBrace(input, i+1)
+			if end < 0 {
+				return nil, ErrBadSubstitution{Pos: offset + i}
+			}
+
+			ref, ok := parseBracedParam(input[i+2:end], offset+i+2)
+			if !ok {
+				return nil, ErrBadSubstitution{Pos: offset + i}
+			}
+			ref.pos = offset + i
+			retval = append(retval, ref)
+
+			i = end
+			continue
+		}
+
+		// no, it is not
+		nameLen := matchParamName(input[i+1:])
+		if nameLen == 0 {
+			// it's just a '$' sign
+			continue
+		}
+
+		// positional params are only one digit long, unless they are
+		// wrapped in braces
+		if isDigit(input[i+1]) {
+			nameLen = 1
+		}
+
+		retval = append(retval, paramRef{
+			pos:   offset + i,
+			name:  input[i+1 : i+1+nameLen],
+			isVar: true,
+		})
+		i += nameLen
+	}
+
+	// all done
+	return retval, nil
+}
+
+// paramNormaliser rewrites an input string into a form that shellexpand
+// can safely expand.
+//
+// shellexpand hangs or panics when a '$' is not followed by something
+// that it can expand (e.g. `$$`, `a $ b` or a trailing '$'), and it
+// misreads some unbraced params that are next to each other (e.g.
+// `$A$B`). To avoid all of that:
+//
+// * every unbraced param is wrapped in braces, so `$A` becomes `${A}`
+//
+// * every other '$' is escaped, so that it is treated as a literal '$'
+//
+// shellexpand also does not support the operators that only check if a
+// variable is set (e.g. `${A-word}`), so we look up the variable and
+// rewrite them into something that it does support.
+//
+// The words inside braced params are rewritten too.
+type paramNormaliser struct {
+	// lookupVar is how we find out if a variable is set
+	lookupVar func(string) (string, bool)
+
+	// assigned tracks any variables that will have been set by earlier
+	// `${var:=word}` or `${var=word}` expansions
+	assigned map[string]bool
+}
+
+// normaliseParams rewrites the input string into a form that shellexpand
+// can safely expand. See paramNormaliser for details.
+func normaliseParams(input string, lookupVar func(string) (string, bool)) string {
+	n := paramNormaliser{
+		lookupVar: lookupVar,
+		assigned:  map[string]bool{},
+	}
+
+	return n.normalise(input)
+}
+
+// normalise rewrites the given input string
+func (n *paramNormaliser) normalise(input string) string {
+	var sb strings.Builder
+
+	inEscape := false
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+
+		// copy escaped characters as-is
+		if inEscape {
+			inEscape = false
+			sb.WriteByte(c)
+			continue
+		}
+		if c == '\\' {
+			inEscape = true
+			sb.WriteByte(c)
+			continue
+		}
+
+		// we only care about parameters
+		if c != '$' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		// is it wrapped in braces?
+		if i < len(input)-1 && input[i+1] == '{' {
+			end := matchClosingBrace(input, i+1)
+			if end >= 0 {
+				sb.WriteString(n.normaliseBracedParam(input[i+2 : end]))
+				i = end
+				continue
+			}
+		}
+
+		// no, it is not
+		nameLen := matchParamName(input[i+1:])
+		if nameLen == 0 {
+			// it's just a '$' sign
+			sb.WriteString("\\$")
+			continue
+		}
+
+		// positional params are only one digit long, unless they are
+		// wrapped in braces
+		if isDigit(input[i+1]) {
+			nameLen = 1
+		}
+
+		sb.WriteString("${")
+		sb.WriteString(input[i+1 : i+1+nameLen])
+		sb.WriteString("}")
+		i += nameLen
+	}
+
+	return sb.String()
+}
+
+// normaliseBracedParam rewrites a `${...}` expansion, given its body
+//
+// the param name (and any length or indirection prefix) is kept as-is,
+// and everything after it is rewritten by normalise
+func (n *paramNormaliser) normaliseBracedParam(body string) string {
+	// do we need to emulate the operator?
+	ref, ok := parseBracedParam(body, 0)
+	if ok && ref.isVar && isShellSetOp(ref.op) && body[0] != '!' {
+		return n.emulateSetOp(ref)
+	}
+
+	nameLen := matchParamName(body)
+	if len(body) > 1 && (body[0] == '#' || body[0] == '!') {
+		prefixedLen := matchParamName(body[1:])
+		if prefixedLen > 0 {
+			nameLen = 1 + prefixedLen
+		}
+	}
+
+	// remember any assignments
+	if ok && ref.isVar && ref.op == ":=" {
+		value, isSet := n.lookup(ref.lookupKey())
+		if !isSet || len(value) == 0 {
+			n.assigned[ref.lookupKey()] = true
+		}
+	}
+
+	return "${" + body[:nameLen] + n.normalise(body[nameLen:]) + "}"
+}
+
+// emulateSetOp rewrites `${var-word}`, `${var=word}`, `${var+word}` and
+// `${var?word}` into something that shellexpand supports
+func (n *paramNormaliser) emulateSetOp(ref paramRef) string {
+	key := ref.lookupKey()
+	_, isSet := n.lookup(key)
+
+	switch {
+	case isSet && ref.op == "+":
+		return n.normalise(ref.word)
+	case isSet:
+		return "${" + ref.name + "}"
+	case ref.op == "-":
+		return n.normalise(ref.word)
+	case ref.op == "=":
+		n.assigned[key] = true
+		return "${" + ref.name + ":=" + n.normalise(ref.word) + "}"
+	case ref.op == "?":
+		return "${" + ref.name + ":?" + n.normalise(ref.word) + "}"
+	default:
+		return ""
+	}
+}
+
+// lookup returns the value that the given variable will have at this
+// point in the expansion
+func (n *paramNormaliser) lookup(key string) (string, bool) {
+	if n.assigned[key] {
+		return "", true
+	}
+	if n.lookupVar == nil {
+		return "", false
+	}
+
+	return n.lookupVar(key)
+}
+
+// isShellSetOp returns true if the operator only checks whether the
+// variable is set, and not whether it is empty
+func isShellSetOp(op string) bool {
+	return op == "-" || op == "=" || op == "+" || op == "?"
+}
+
+// matchClosingBrace returns the position of the '}' that closes the
+// '{' at the given start position, or -1 if there isn't one
+func matchClosingBrace(input string, start int) int {
+	depth := 0
+	inEscape := false
+	for i := start; i < len(input); i++ {
+		switch {
+		case inEscape:
+			inEscape = false
+		case input[i] == '\\':
+			inEscape = true
+		case input[i] == '{':
+			depth++
+		case input[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	// if we get here, the brace was never closed
+	return -1
+}
+
+// parseBracedParam works out what the body of a `${...}` expansion
+// refers to. It returns false if the body is not a valid parameter
+// expansion.
+func parseBracedParam(body string, offset int) (paramRef, bool) {
+	retval := paramRef{isVar: true}
+
+	// special case - ${#} and ${!} are params in their own right
+	if body == "#" || body == "!" {
+		retval.name = body
+		return retval, true
+	}
+
+	// skip over any length or indirection prefix
+	start := 0
+	isIndirect := false
+	if strings.HasPrefix(body, "#") {
+		start = 1
+	} else if strings.HasPrefix(body, "!") {
+		start = 1
+		isIndirect = true
+	}
+
+	nameLen := matchParamName(body[start:])
+	if nameLen == 0 {
+		return paramRef{}, false
+	}
+	retval.name = body[start : start+nameLen]
+	rest := body[start+nameLen:]
+
+	// is that it?
+	if len(rest) == 0 {
+		return retval, true
+	}
+
+	// the length prefix cannot be combined with anything else
+	if start == 1 && !isIndirect {
+		return paramRef{}, false
+	}
+
+	// special case - ${!prefix*} and ${!prefix@} do not look up a variable
+	if isIndirect && (rest == "*" || rest == "@") {
+		retval.isVar = false
+		return retval, true
+	}
+
+	// do we have an operator that affects whether the variable must be set?
+	for _, op := range paramOps {
+		if strings.HasPrefix(rest, op) {
+			retval.op = op
+			retval.word = rest[len(op):]
+			retval.wordPos = offset + start + nameLen + len(op)
+			return retval, true
+		}
+	}
+
+	// do we have any other operator that we recognise?
+	if strings.ContainsRune(":#%/^,@", rune(rest[0])) {
+		retval.op = rest[:1]
+		return retval, true
+	}
+
+	// if we get here, we have no idea what this is
+	return paramRef{}, false
+}
+
+// matchParamName returns the length of the parameter name at the start
+// of the input string, or 0 if there isn't one
+func matchParamName(input string) int {
+	// special case - empty string
+	if len(input) == 0 {
+		return 0
+	}
+
+	c := input[0]
+	switch {
+	case isDigit(c):
+		i := 1
+		for i < len(input) && isDigit(input[i]) {
+			i++
+		}
+		return i
+	case strings.IndexByte("#*?!$-@", c) >= 0:
+		return 1
+	case isNameStartChar(c):
+		i := 1
+		for i < len(input) && isNameBodyChar(input[i]) {
+			i++
+		}
+		return i
+	}
+
+	// if we get here, there is no param name
+	return 0
+}
+
+// isShellName returns true if the given param name is a normal variable
+// name, and not a shell special param or positional param
+func isShellName(name string) bool {
+	return len(name) > 0 && isNameStartChar(name[0])
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isNameStartChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isNameBodyChar(c byte) bool {
+	return isNameStartChar(c) || isDigit(c)
+}
@@ -0,0 +1,259 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"encoding"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// these are the types that need special handling when we convert
+// to and from strings
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// parseBool understands all the different ways that people write
+// booleans in environment variables
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, true
+	case "0", "f", "false", "n", "no", "off":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// parseValue converts the given string, and stores it in v
+//
+// sep is used to split the string up when v is a slice
+//
+// it returns false if the string cannot be converted into v's type
+func parseValue(v reflect.Value, value string, sep string) bool {
+	// special case - allocate anything that we point to
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return parseValue(v.Elem(), value, sep)
+	}
+
+	// special case - the type knows how to parse itself
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		return err == nil
+	}
+
+	// special case - types that need a helping hand
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return false
+		}
+		v.SetInt(int64(d))
+		return true
+	case urlType:
+		u, err := url.Parse(strings.TrimSpace(value))
+		if err != nil {
+			return false
+		}
+		v.Set(reflect.ValueOf(*u))
+		return true
+	}
+
+	// general case
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, ok := parseBool(value)
+		if !ok {
+			return false
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, v.Type().Bits())
+		if err != nil {
+			return false
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.TrimSpace(value), 10, v.Type().Bits())
+		if err != nil {
+			return false
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), v.Type().Bits())
+		if err != nil {
+			return false
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(value, sep)
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if !parseValue(slice.Index(i), strings.TrimSpace(part), sep) {
+				return false
+			}
+		}
+		v.Set(slice)
+	default:
+		return false
+	}
+
+	// if we get here, all is well
+	return true
+}
+
+// typeName returns a human-friendly name for the given type, for use
+// in error messages
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.String()
+}
+
+// formatValue converts v into a string that parseValue can read back in
+//
+// sep is used to join the values together when v is a slice
+//
+// it returns false if v's type is not supported
+func formatValue(v reflect.Value, sep string) (string, bool) {
+	// special case - follow pointers
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", true
+		}
+		return formatValue(v.Elem(), sep)
+	}
+
+	// special case - the type knows how to format itself
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err == nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		text, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err == nil
+	}
+
+	// special case - types that need a helping hand
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String(), true
+	case urlType:
+		u := v.Interface().(url.URL)
+		return u.String(), true
+	}
+
+	// general case
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), true
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			part, ok := formatValue(v.Index(i), sep)
+			if !ok {
+				return "", false
+			}
+			parts[i] = part
+		}
+		return strings.Join(parts, sep), true
+	default:
+		return "", false
+	}
+}
+
+// isParsableType returns true if parseValue knows how to work with the
+// given type
+func isParsableType(t reflect.Type) bool {
+	return isSupportedType(t, textUnmarshalerType)
+}
+
+// isFormattableType returns true if formatValue knows how to work with
+// the given type
+func isFormattableType(t reflect.Type) bool {
+	return isSupportedType(t, textMarshalerType)
+}
+
+// isSupportedType returns true if the given type is one of our built-in
+// types, or if it implements the given text interface
+func isSupportedType(t reflect.Type, textInterface reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return isSupportedType(t.Elem(), textInterface)
+	}
+
+	if t.Implements(textInterface) || reflect.PtrTo(t).Implements(textInterface) {
+		return true
+	}
+	if t == durationType || t == urlType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return isSupportedType(t.Elem(), textInterface)
+	default:
+		return false
+	}
+}
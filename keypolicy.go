@@ -0,0 +1,96 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"strings"
+)
+
+// KeyPolicy decides which keys can be used as variable names.
+//
+// It returns an empty string if the key can be used. Otherwise, it
+// returns the reason why the key cannot be used, and LocalEnv.Setenv
+// returns that reason in an ErrInvalidKey.
+//
+// Use WithKeyPolicy to add a KeyPolicy to a LocalEnv. You can use one of
+// our built-in policies (PortableKeys or PermissiveKeys), or write your
+// own.
+type KeyPolicy func(key string) string
+
+// PortableKeys is a KeyPolicy that only accepts POSIX portable variable
+// names. They start with a letter or an underscore, and they only contain
+// letters, digits and underscores.
+//
+// Every UNIX shell can work with these names.
+func PortableKeys(key string) string {
+	// special case - empty key
+	if len(key) == 0 {
+		return "name is empty"
+	}
+
+	if !isNameStartChar(key[0]) {
+		return "name must start with a letter or an underscore"
+	}
+
+	for i := 1; i < len(key); i++ {
+		if !isNameBodyChar(key[i]) {
+			return "name must only contain letters, digits and underscores"
+		}
+	}
+
+	// if we get here, all is well
+	return ""
+}
+
+// PermissiveKeys is a KeyPolicy that accepts any name that can be stored
+// in a "key=value" pair. The name cannot contain an '=' sign or a NUL byte.
+func PermissiveKeys(key string) string {
+	// special case - empty key
+	if len(key) == 0 {
+		return "name is empty"
+	}
+
+	if strings.ContainsRune(key, '=') {
+		return "name must not contain '='"
+	}
+
+	if strings.ContainsRune(key, 0) {
+		return "name must not contain a NUL byte"
+	}
+
+	// if we get here, all is well
+	return ""
+}
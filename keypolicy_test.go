@@ -0,0 +1,297 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"strings"
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// Built-in policies
+//
+// ----------------------------------------------------------------
+
+func TestPortableKeys(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := map[string]string{
+		"PARAM1":  "",
+		"_param":  "",
+		"a":       "",
+		"":        "name is empty",
+		"1PARAM":  "name must start with a letter or an underscore",
+		"=C:":     "name must start with a letter or an underscore",
+		"MY VAR":  "name must only contain letters, digits and underscores",
+		"MY-VAR":  "name must only contain letters, digits and underscores",
+		"A=B":     "name must only contain letters, digits and underscores",
+		"A\x00B":  "name must only contain letters, digits and underscores",
+		"CAFÉ":    "name must only contain letters, digits and underscores",
+		"ProgId1": "",
+	}
+
+	for key, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult := envish.PortableKeys(key)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedResult, actualResult, key)
+	}
+}
+
+func TestPermissiveKeys(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := map[string]string{
+		"PARAM1":                   "",
+		"1PARAM":                   "",
+		"MY VAR":                   "",
+		"ProgramFiles(x86)":        "",
+		"":                         "name is empty",
+		"A=B":                      "name must not contain '='",
+		"A\x00B":                   "name must not contain a NUL byte",
+		"CommonProgramFiles(x86)":  "",
+		"CommonProgramFiles=(x86)": "name must not contain '='",
+	}
+
+	for key, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult := envish.PermissiveKeys(key)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedResult, actualResult, key)
+	}
+}
+
+// ================================================================
+//
+// LocalEnv
+//
+// ----------------------------------------------------------------
+
+func TestLocalEnvSetenvAppliesKeyPolicy(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.WithKeyPolicy(envish.PortableKeys))
+
+	expectedError := envish.ErrInvalidKey{
+		Key:    "1PARAM",
+		Reason: "name must start with a letter or an underscore",
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.Setenv("1PARAM", "foo")
+	err2 := env.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err1)
+	assert.Nil(t, err2)
+	assert.Equal(t, []string{"PARAM1=foo"}, env.AllVars())
+}
+
+func TestLocalEnvSetenvAppliesCustomKeyPolicy(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	upperCaseOnly := func(key string) string {
+		if strings.ToUpper(key) != key {
+			return "name must be upper case"
+		}
+		return envish.PortableKeys(key)
+	}
+	env := envish.NewLocalEnv(envish.WithKeyPolicy(upperCaseOnly))
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.Setenv("param1", "foo")
+	err2 := env.SetArray("arr", "foo")
+	err3 := env.SetElement("arr", "0", "foo")
+	err4 := env.SetAssoc("map", map[string]string{})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrInvalidKey{Key: "param1", Reason: "name must be upper case"}, err1)
+	assert.Equal(t, envish.ErrInvalidKey{Key: "arr", Reason: "name must be upper case"}, err2)
+	assert.Equal(t, envish.ErrInvalidKey{Key: "arr", Reason: "name must be upper case"}, err3)
+	assert.Equal(t, envish.ErrInvalidKey{Key: "map", Reason: "name must be upper case"}, err4)
+}
+
+func TestLocalEnvWithoutKeyPolicyAcceptsAnyKey(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Setenv("1PARAM", "foo")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, envish.ErrEmptyKey{}, env.Setenv(" ", "foo"))
+}
+
+func TestLocalEnvRejectsKeysThatCannotBeStoredInPairs(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	acceptEverything := func(key string) string {
+		return ""
+	}
+	envs := []*envish.LocalEnv{
+		envish.NewLocalEnv(),
+		envish.NewLocalEnv(envish.WithKeyPolicy(acceptEverything)),
+	}
+	equalsError := envish.ErrInvalidKey{Key: "A=B", Reason: "name must not contain '='"}
+	nulError := envish.ErrInvalidKey{Key: "A\x00B", Reason: "name must not contain a NUL byte"}
+
+	for _, env := range envs {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		err1 := env.Setenv("A=B", "foo")
+		err2 := env.Setenv("A\x00B", "foo")
+		err3 := env.SetArray("A=B", "foo")
+		err4 := env.SetElement("A=B", "0", "foo")
+		err5 := env.SetAssoc("A=B", map[string]string{})
+		err6 := env.SetReadOnly("A=B")
+		err7 := env.Export("A=B")
+		err8 := env.Unexport("A\x00B")
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, equalsError, err1)
+		assert.Equal(t, nulError, err2)
+		assert.Equal(t, equalsError, err3)
+		assert.Equal(t, equalsError, err4)
+		assert.Equal(t, equalsError, err5)
+		assert.Equal(t, equalsError, err6)
+		assert.Equal(t, equalsError, err7)
+		assert.Equal(t, nulError, err8)
+		assert.Empty(t, env.AllVars())
+	}
+}
+
+func TestLocalEnvSetReadOnlyAndExportApplyKeyPolicy(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.WithKeyPolicy(envish.PortableKeys))
+	expectedError := envish.ErrInvalidKey{
+		Key:    "1PARAM",
+		Reason: "name must start with a letter or an underscore",
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.SetReadOnly("1PARAM")
+	err2 := env.Export("1PARAM")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err1)
+	assert.Equal(t, expectedError, err2)
+	assert.False(t, env.IsReadOnly("1PARAM"))
+}
+
+func TestLocalEnvRejectsNULInValues(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	expectedError := envish.ErrNULInValue{Key: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.Setenv("PARAM1", "foo\x00bar")
+	err2 := env.SetArray("PARAM1", "foo", "\x00")
+	err3 := env.SetElement("PARAM1", "0", "\x00")
+	err4 := env.SetAssoc("PARAM1", map[string]string{"foo": "\x00"})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err1)
+	assert.Equal(t, expectedError, err2)
+	assert.Equal(t, expectedError, err3)
+	assert.Equal(t, expectedError, err4)
+	_, ok := env.LookupEnv("PARAM1")
+	assert.False(t, ok)
+}
@@ -36,6 +36,7 @@
 package envish
 
 import (
+	"sort"
 	"strings"
 )
 
@@ -51,11 +52,44 @@ type LocalEnv struct {
 	// any subsequent lookups of the same variable
 	pairKeys map[string]int
 
+	// upperKeys is a lookup table from each key in upper case to the
+	// keys of the variables (and arrays) that have it
+	//
+	// we only build it when an OverlayEnv that ignores case needs to
+	// find a variable in here, and keep it up to date from then on
+	upperKeys map[string][]string
+
 	// should the variables in here be made available to external programs?
 	//
-	// this helps our EnvStack work out which stacked environments to
-	// export out
+	// this is the default for any variable that hasn't been passed to
+	// Export or Unexport
 	isExporter bool
+
+	// exports holds the keys of any variables that have been passed to
+	// Export (true) or Unexport (false)
+	exports map[string]bool
+
+	// readOnly holds the keys of any variables that cannot be changed
+	// or deleted
+	readOnly map[string]bool
+
+	// keyPolicy decides which keys can be used, if it is set
+	keyPolicy KeyPolicy
+
+	// caseInsensitive is true if keys that only differ by case refer to
+	// the same variable (ie, Windows-style environments)
+	//
+	// when it is set, every lookup table is indexed by foldKey(key)
+	caseInsensitive bool
+
+	// arrays holds any indexed or associative array variables
+	//
+	// they are never included in pairs, because they cannot be
+	// exported to external programs
+	arrays map[string]arrayVar
+
+	// hooks are called whenever a variable is changed
+	hooks changeHooks
 }
 
 // ================================================================
@@ -91,6 +125,9 @@ func NewLocalEnv(options ...func(*LocalEnv)) *LocalEnv {
 
 // Environ returns a copy of all entries in the form "key=value".
 // This is compatible with any Golang standard library, such as `os/exec`.
+//
+// It includes variables that haven't been exported. Use ExportedVars if
+// you only want the exported ones.
 func (e *LocalEnv) Environ() []string {
 	// do we have an environment store to work with?
 	if e == nil {
@@ -105,33 +142,38 @@ func (e *LocalEnv) Environ() []string {
 //
 // If the key is not found, an empty string is returned.
 func (e *LocalEnv) Getenv(key string) string {
-	// do we have an environment store to work with?
-	if e == nil {
-		return ""
-	}
-
-	// yes we do
-	i := e.findPairIndex(key)
-	if i >= 0 {
-		key := GetKeyFromPair(e.pairs[i])
-		return GetValueFromPair(e.pairs[i], key)
-	}
-
-	// not found
-	return ""
+	value, _ := e.LookupEnv(key)
+	return value
 }
 
 // IsExporter returns true if this backing store holds variables that
 // should be exported to external programs.
 //
-// It is used by OverlayEnv.Environ to work out which keys and values
-// the OverlayEnv should include in its output.
+// It is the default for every variable that hasn't been passed to
+// Export or Unexport.
 func (e *LocalEnv) IsExporter() bool {
 	return e.isExporter
 }
 
+// IsCaseInsensitive returns true if keys that only differ by case refer
+// to the same variable.
+//
+// Use the CaseInsensitiveKeys option to turn this on.
+func (e *LocalEnv) IsCaseInsensitive() bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	return e.caseInsensitive
+}
+
 // LookupEnv returns the value of the variable named by the key.
 //
+// If the key names an array, the value of element 0 is returned, just
+// like `$arr` in bash.
+//
 // If the key is not found, an empty string is returned, and the returned
 // boolean is false.
 func (e *LocalEnv) LookupEnv(key string) (string, bool) {
@@ -147,8 +189,8 @@ func (e *LocalEnv) LookupEnv(key string) (string, bool) {
 		return GetValueFromPair(e.pairs[i], key), true
 	}
 
-	// not found
-	return "", false
+	// is it an array?
+	return e.lookupArrayValue(key)
 }
 
 // MatchVarNames returns a list of variable names that start with the
@@ -166,13 +208,13 @@ func (e *LocalEnv) MatchVarNames(prefix string) []string {
 
 	// yes we do
 	for i := range e.pairs {
-		if strings.HasPrefix(e.pairs[i], prefix) {
+		if e.hasKeyPrefix(e.pairs[i], prefix) {
 			retval = append(retval, GetKeyFromPair(e.pairs[i]))
 		}
 	}
 
-	// all done
-	return retval
+	// arrays have names too
+	return append(retval, e.matchArrayNames(prefix)...)
 }
 
 // ================================================================
@@ -183,6 +225,8 @@ func (e *LocalEnv) MatchVarNames(prefix string) []string {
 
 // Clearenv deletes all entries from the given LocalEnv. The program's
 // environment remains unchanged.
+//
+// Read-only variables are not deleted.
 func (e *LocalEnv) Clearenv() {
 	// do we have an environment store to work with?
 	if e == nil {
@@ -190,44 +234,136 @@ func (e *LocalEnv) Clearenv() {
 	}
 
 	// yes, we do
-	e.pairs = []string{}
+	//
+	// read-only variables survive, and so does their export attribute
+	pairs := []string{}
+	exports := make(map[string]bool)
+	for _, pair := range e.pairs {
+		key := e.foldKey(GetKeyFromPair(pair))
+		if !e.readOnly[key] {
+			continue
+		}
+
+		pairs = append(pairs, pair)
+		exported, ok := e.exports[key]
+		if ok {
+			exports[key] = exported
+		}
+	}
+
+	// and so do read-only arrays
+	for key := range e.arrays {
+		if !e.readOnly[key] {
+			delete(e.arrays, key)
+			continue
+		}
+
+		exported, ok := e.exports[key]
+		if ok {
+			exports[key] = exported
+		}
+	}
+
+	e.pairs = pairs
+	e.exports = exports
 	e.makePairIndex()
+
+	// tell anyone who is interested
+	e.hooks.notify(ChangeEvent{Op: ChangeClear})
 }
 
 // Setenv sets the value of the variable named by the key. The program's
 // environment remains unchanged.
+//
+// If the key names an array, it sets the value of element 0, just like
+// `arr=value` in bash.
+//
+// It returns ErrReadOnlyVar if the variable has been marked as read-only,
+// ErrInvalidKey if the key contains '=' or a NUL byte (or is not accepted
+// by the environment's KeyPolicy), and ErrNULInValue if the value
+// contains a NUL byte.
 func (e *LocalEnv) Setenv(key, value string) error {
 	// do we have an environment store to work with
 	if e == nil {
 		return ErrNilPointer{"LocalEnv.Setenv"}
 	}
 
-	// make sure we have a key that we can work with
-	if len(key) == 0 || len(strings.TrimSpace(key)) == 0 {
-		return ErrEmptyKey{}
+	// make sure we have a key and value that we can work with
+	err := e.checkKey(key)
+	if err != nil {
+		return err
+	}
+	err = checkValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	// are we allowed to change it?
+	if e.readOnly[e.foldKey(key)] {
+		return ErrReadOnlyVar{Key: key}
 	}
 
 	// we need to update the Golang-compatible list too
+	ev := ChangeEvent{Op: ChangeSet, Key: key, NewValue: value}
 	i := e.findPairIndex(key)
-	if i >= 0 {
+	arr, isArray := e.arrays[e.foldKey(key)]
+	if isArray {
+		// we're updating element 0 of an array
+		ev.OldValue, ev.WasSet = arr.get("0")
+		arr.elems["0"] = value
+	} else if i >= 0 {
 		// we're updating an existing entry
-		e.pairs[i] = key + "=" + value
+		//
+		// it keeps its original name, even if the key's case is different
+		pairKey := GetKeyFromPair(e.pairs[i])
+		ev.OldValue, ev.WasSet = GetValueFromPair(e.pairs[i], pairKey), true
+		e.pairs[i] = pairKey + "=" + value
 	} else {
 		// we have a new entry!
 		e.appendPairIndex(key, value)
 	}
 
+	// tell anyone who is interested
+	e.hooks.notify(ev)
+
 	// all done
 	return nil
 }
 
-// Unsetenv deletes the variable named by the key.
+// Unsetenv deletes the variable named by the key. Any call to Export or
+// Unexport for this variable is forgotten too.
+//
+// If the key names an array, the whole array is deleted.
+//
+// It does nothing if the variable has been marked as read-only.
 func (e *LocalEnv) Unsetenv(key string) {
 	// do we have an environment store to work with?
 	if e == nil {
 		return
 	}
 
+	// are we allowed to delete it?
+	foldedKey := e.foldKey(key)
+	if e.readOnly[foldedKey] {
+		return
+	}
+
+	// the export attribute goes, whether or not the variable is set
+	delete(e.exports, foldedKey)
+
+	// is it an array?
+	arr, ok := e.arrays[foldedKey]
+	if ok {
+		delete(e.arrays, foldedKey)
+		e.forgetUpperKey(foldedKey)
+
+		// tell anyone who is interested
+		ev := ChangeEvent{Op: ChangeUnset, Key: key}
+		ev.OldValue, ev.WasSet = arr.get("0")
+		e.hooks.notify(ev)
+		return
+	}
+
 	// yes we do
 	//
 	// but do we have this variable?
@@ -236,13 +372,23 @@ func (e *LocalEnv) Unsetenv(key string) {
 		return
 	}
 
+	// remember what we are deleting
+	pairKey := GetKeyFromPair(e.pairs[i])
+	ev := ChangeEvent{
+		Op:       ChangeUnset,
+		Key:      key,
+		OldValue: GetValueFromPair(e.pairs[i], pairKey),
+		WasSet:   true,
+	}
+
 	// we need to shuffle up
 	e.pairs = append(e.pairs[:i], e.pairs[i+1:]...)
+	e.forgetUpperKey(pairKey)
 
 	// and we need to rewrite our fast lookup map too
 	newPairKeys := make(map[string]int, len(e.pairKeys))
 	for cachedKey, cachedIndex := range e.pairKeys {
-		if cachedKey == key {
+		if cachedKey == foldedKey {
 			continue
 		}
 
@@ -251,6 +397,9 @@ func (e *LocalEnv) Unsetenv(key string) {
 		}
 	}
 	e.pairKeys = newPairKeys
+
+	// tell anyone who is interested
+	e.hooks.notify(ev)
 }
 
 // ================================================================
@@ -267,6 +416,427 @@ func (e *LocalEnv) Expand(fmt string) string {
 	return expand(e, fmt)
 }
 
+// ExpandE replaces ${var} or $var in the input string.
+//
+// Unlike Expand, it returns an error if the input string cannot be
+// expanded:
+//
+// * ErrBadSubstitution if the input string contains a malformed `${`
+//
+// * ErrUnsetVariable if `${var:?word}` refers to an unset variable
+func (e *LocalEnv) ExpandE(fmt string) (string, error) {
+	return expandE(e, fmt)
+}
+
+// ExpandWith works like ExpandE. Use the options to choose what
+// counts as an error.
+//
+// With the NoUnset option, it returns ErrUnboundVariables listing every
+// variable in the input string that isn't set.
+func (e *LocalEnv) ExpandWith(fmt string, options ExpandOptions) (string, error) {
+	return expandWith(e, fmt, options)
+}
+
+// ================================================================
+//
+// Change notifications
+//
+// ----------------------------------------------------------------
+
+// OnChange registers a function to call whenever Setenv, Unsetenv,
+// Clearenv or one of the array methods changes the LocalEnv. That
+// includes changes made during string expansion, such as `${var:=word}`
+// and `${arr[1]:=word}`.
+//
+// Functions are called in the order that they were registered, after
+// the change has been made. Unsetenv on a variable that isn't set does
+// not call them. For arrays, the event's values are those of element 0,
+// just like `$arr` in bash.
+func (e *LocalEnv) OnChange(fn func(ev ChangeEvent)) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.add(fn)
+}
+
+// holdHooks queues up any change events, instead of passing them on
+func (e *LocalEnv) holdHooks(q *heldEvents) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.hold(q)
+}
+
+// releaseHooks passes on (if deliver is true) or throws away (if deliver
+// is false) any change events that have been queued up
+func (e *LocalEnv) releaseHooks(deliver bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.release(deliver)
+}
+
+// ================================================================
+//
+// Transactions
+//
+// ----------------------------------------------------------------
+
+// Begin starts a transaction. Changes made through the returned Tx are
+// not made to the LocalEnv until you call Tx.Commit.
+func (e *LocalEnv) Begin() *Tx {
+	// do we have an environment store to work with?
+	if e == nil {
+		return newTx(nil)
+	}
+
+	// yes we do
+	return newTx(e)
+}
+
+// ================================================================
+//
+// Exported variables
+//
+// ----------------------------------------------------------------
+
+// AllVars returns a copy of all entries in the form "key=value", whether
+// they have been exported or not.
+func (e *LocalEnv) AllVars() []string {
+	// do we have an environment store to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	retval := make([]string, len(e.pairs))
+	copy(retval, e.pairs)
+	return retval
+}
+
+// Export emulates UNIX shell `export XXX` behaviour. The variable named
+// by the key will be included in the output of ExportedVars (and of
+// OverlayEnv.Environ).
+//
+// The variable does not need to be set first. If it isn't, it will be
+// exported once it is set.
+//
+// It returns ErrInvalidKey if the key cannot be used as the name of a
+// variable.
+func (e *LocalEnv) Export(key string) error {
+	return e.setExported("LocalEnv.Export", key, true)
+}
+
+// IsExported returns true if the variable named by the key will be
+// included in the output of ExportedVars (once it is set).
+//
+// Variables that haven't been passed to Export or Unexport follow
+// IsExporter.
+func (e *LocalEnv) IsExported(key string) bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// has anyone made a decision about this variable?
+	exported, ok := e.exports[e.foldKey(key)]
+	if ok {
+		return exported
+	}
+
+	// no, so we use the default
+	return e.isExporter
+}
+
+// Unexport emulates UNIX shell `export -n XXX` behaviour. The variable
+// named by the key will be left out of the output of ExportedVars (and
+// of OverlayEnv.Environ).
+func (e *LocalEnv) Unexport(key string) error {
+	return e.setExported("LocalEnv.Unexport", key, false)
+}
+
+// ExportedVars returns a copy of all exported entries in the form
+// "key=value". Use it instead of Environ when you want to emulate the
+// environment that a UNIX shell passes to the programs that it runs.
+func (e *LocalEnv) ExportedVars() []string {
+	// do we have an environment store to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	return e.exportedPairs()
+}
+
+// isInEnviron returns true if Environ includes the variable named by
+// the key (once it is set). It always does, whether the variable has
+// been exported or not.
+func (e *LocalEnv) isInEnviron(key string) bool {
+	return true
+}
+
+// ================================================================
+//
+// Arrays
+//
+// ----------------------------------------------------------------
+
+// GetArray returns a copy of the values held in the array named by the
+// key, just like `${arr[@]}` in bash.
+//
+// Indexed arrays are returned in index order. Associative arrays are
+// returned in key order.
+//
+// It returns false if the key does not name an array.
+func (e *LocalEnv) GetArray(key string) ([]string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return nil, false
+	}
+
+	// yes we do
+	arr, ok := e.arrays[e.foldKey(key)]
+	if !ok {
+		return nil, false
+	}
+
+	return arr.values(), true
+}
+
+// GetAssoc returns a copy of the array named by the key, as a map of
+// index to value.
+//
+// It works for both indexed and associative arrays. The index of each
+// element in an indexed array is converted to a string.
+//
+// It returns false if the key does not name an array.
+func (e *LocalEnv) GetAssoc(key string) (map[string]string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return nil, false
+	}
+
+	// yes we do
+	arr, ok := e.arrays[e.foldKey(key)]
+	if !ok {
+		return nil, false
+	}
+
+	return arr.clone().elems, true
+}
+
+// GetElement returns the value of a single element of the array named
+// by the key, just like `${arr[index]}` in bash.
+//
+// Indexed arrays accept negative indexes, which count back from the end
+// of the array.
+//
+// It returns false if the array, or the element, does not exist.
+func (e *LocalEnv) GetElement(key, index string) (string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return "", false
+	}
+
+	// yes we do
+	arr, ok := e.arrays[e.foldKey(key)]
+	if !ok {
+		return "", false
+	}
+
+	return arr.get(index)
+}
+
+// IsArray returns true if the key names an indexed or associative array.
+func (e *LocalEnv) IsArray(key string) bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	_, ok := e.arrays[e.foldKey(key)]
+	return ok
+}
+
+// SetArray emulates bash's `arr=(value1 value2 ...)` behaviour. It
+// replaces the variable named by the key with an indexed array that
+// holds the given values.
+//
+// Arrays are never included in the output of Environ, AllVars or
+// ExportedVars.
+//
+// It returns ErrReadOnlyVar if the variable has been marked as read-only.
+func (e *LocalEnv) SetArray(key string, values ...string) error {
+	return e.setArray("LocalEnv.SetArray", key, newIndexedArray(values))
+}
+
+// SetAssoc emulates bash's `declare -A map=(...)` behaviour. It replaces
+// the variable named by the key with an associative array that holds a
+// copy of the given values.
+//
+// Arrays are never included in the output of Environ, AllVars or
+// ExportedVars.
+//
+// It returns ErrReadOnlyVar if the variable has been marked as read-only.
+func (e *LocalEnv) SetAssoc(key string, values map[string]string) error {
+	return e.setArray("LocalEnv.SetAssoc", key, newAssocArray(values))
+}
+
+// SetElement emulates bash's `arr[index]=value` behaviour.
+//
+// If the key does not name an array, a new indexed array is created.
+// Any existing variable with that name becomes element 0 of the new
+// array.
+//
+// It returns ErrInvalidArrayIndex if the index is not valid for the
+// array, and ErrReadOnlyVar if the variable has been marked as read-only.
+func (e *LocalEnv) SetElement(key, index, value string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"LocalEnv.SetElement"}
+	}
+
+	// make sure we have a key and value that we can work with
+	err := e.checkKey(key)
+	if err != nil {
+		return err
+	}
+	err = checkValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	// are we allowed to change it?
+	if e.readOnly[e.foldKey(key)] {
+		return ErrReadOnlyVar{Key: key}
+	}
+
+	// do we need to turn it into an array first?
+	//
+	// we work on a copy, so that storeArray can tell what has changed
+	arr, ok := e.arrays[e.foldKey(key)]
+	if ok {
+		arr = arr.clone()
+	} else {
+		arr = newIndexedArray(nil)
+		value, isSet := e.peekEnv(key)
+		if isSet {
+			arr.elems["0"] = value
+		}
+	}
+
+	// is the index valid?
+	normalised, ok := arr.normaliseIndex(index)
+	if !ok {
+		return ErrInvalidArrayIndex{Key: key, Index: index}
+	}
+
+	// yes it is
+	arr.elems[normalised] = value
+	e.storeArray(key, arr)
+
+	// all done
+	return nil
+}
+
+// UnsetElement emulates bash's `unset arr[index]` behaviour. It deletes
+// a single element from the array named by the key. The other elements
+// keep their indexes.
+//
+// It does nothing if the variable has been marked as read-only.
+func (e *LocalEnv) UnsetElement(key, index string) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// are we allowed to change it?
+	if e.readOnly[e.foldKey(key)] {
+		return
+	}
+
+	// do we have this array?
+	arr, ok := e.arrays[e.foldKey(key)]
+	if !ok {
+		return
+	}
+
+	// does it have this element?
+	normalised, ok := arr.normaliseIndex(index)
+	if !ok {
+		return
+	}
+	_, ok = arr.elems[normalised]
+	if !ok {
+		return
+	}
+
+	// yes it does
+	arr = arr.clone()
+	delete(arr.elems, normalised)
+	e.storeArray(key, arr)
+}
+
+// ================================================================
+//
+// Read-only variables
+//
+// ----------------------------------------------------------------
+
+// IsReadOnly returns true if the variable named by the key has been
+// marked as read-only.
+func (e *LocalEnv) IsReadOnly(key string) bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	return e.readOnly[e.foldKey(key)]
+}
+
+// SetReadOnly emulates UNIX shell `readonly XXX` behaviour. Once it has
+// been called, the variable named by the key cannot be changed or deleted.
+//
+// The variable does not need to be set first. If it isn't, it can never
+// be set.
+//
+// It returns ErrInvalidKey if the key cannot be used as the name of a
+// variable.
+func (e *LocalEnv) SetReadOnly(key string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"LocalEnv.SetReadOnly"}
+	}
+
+	// make sure we have a key that we can work with
+	err := e.checkKey(key)
+	if err != nil {
+		return err
+	}
+
+	// do we have a map to write to?
+	if e.readOnly == nil {
+		e.readOnly = make(map[string]bool)
+	}
+
+	// yes we do
+	e.readOnly[e.foldKey(key)] = true
+
+	// all done
+	return nil
+}
+
 // ================================================================
 //
 // Internal helpers
@@ -286,23 +856,57 @@ func (e *LocalEnv) Length() int {
 
 func (e *LocalEnv) findPairIndex(key string) int {
 	// special case - we've already got this cached
-	i, ok := e.pairKeys[key]
+	foldedKey := e.foldKey(key)
+	i, ok := e.pairKeys[foldedKey]
 	if ok {
 		return i
 	}
 
 	// general case - we have to search the full list of pairs
-	//
+	i = e.searchPairIndex(key)
+	if i >= 0 {
+		// cache it
+		e.pairKeys[foldedKey] = i
+	}
+
+	// all done
+	return i
+}
+
+// searchPairIndex finds the given key without updating our fast lookup
+// table. It is safe to call from several goroutines at once, as long as
+// nothing is writing to the LocalEnv at the same time.
+func (e *LocalEnv) searchPairIndex(key string) int {
+	// special case - we've already got this cached
+	i, ok := e.pairKeys[e.foldKey(key)]
+	if ok {
+		return i
+	}
+
+	// general case - we have to search the full list of pairs
+	if e.caseInsensitive {
+		return e.searchFoldedPairIndex(key)
+	}
+
 	// this is what we are looking for
 	prefix := key + "="
 
 	// yes, this is horrible
 	for i := range e.pairs {
 		if strings.HasPrefix(e.pairs[i], prefix) {
-			// cache it
-			e.pairKeys[key] = i
+			return i
+		}
+	}
+
+	// if we get here, the key doesn't exist in the pairs
+	return -1
+}
 
-			// all done
+// searchFoldedPairIndex finds the given key, ignoring its case
+func (e *LocalEnv) searchFoldedPairIndex(key string) int {
+	foldedKey := e.foldKey(key)
+	for i := range e.pairs {
+		if e.foldKey(GetKeyFromPair(e.pairs[i])) == foldedKey {
 			return i
 		}
 	}
@@ -311,6 +915,133 @@ func (e *LocalEnv) findPairIndex(key string) int {
 	return -1
 }
 
+// foldKey returns the form of the key that we use to index our
+// lookup tables
+func (e *LocalEnv) foldKey(key string) string {
+	if e.caseInsensitive {
+		return strings.ToUpper(key)
+	}
+
+	return key
+}
+
+// hasKeyPrefix returns true if the given name (or "key=value" pair)
+// starts with the given prefix
+func (e *LocalEnv) hasKeyPrefix(name, prefix string) bool {
+	if e.caseInsensitive {
+		return strings.HasPrefix(e.foldKey(name), e.foldKey(prefix))
+	}
+
+	return strings.HasPrefix(name, prefix)
+}
+
+// foldKeys rebuilds our lookup tables after keys have become
+// case-insensitive
+//
+// if several variables have the same name when case is ignored, the
+// first one is kept
+func (e *LocalEnv) foldKeys() {
+	// deal with the variables first
+	pairs := make([]string, 0, len(e.pairs))
+	seen := make(map[string]bool, len(e.pairs))
+	for _, pair := range e.pairs {
+		foldedKey := e.foldKey(GetKeyFromPair(pair))
+		if seen[foldedKey] {
+			continue
+		}
+
+		seen[foldedKey] = true
+		pairs = append(pairs, pair)
+	}
+	e.pairs = pairs
+	e.makePairIndex()
+
+	// the rest of our lookup tables have the same shape
+	e.exports = foldKeysOf(e.exports, e.foldKey)
+	e.readOnly = foldKeysOf(e.readOnly, e.foldKey)
+
+	if len(e.arrays) > 0 {
+		arrays := make(map[string]arrayVar, len(e.arrays))
+		for key, arr := range e.arrays {
+			arrays[e.foldKey(key)] = arr
+		}
+		e.arrays = arrays
+	}
+}
+
+// foldKeysOf returns a copy of the given lookup table, with every key
+// passed through foldKey
+func foldKeysOf(table map[string]bool, foldKey func(string) string) map[string]bool {
+	// special case - nothing to fold
+	if table == nil {
+		return nil
+	}
+
+	retval := make(map[string]bool, len(table))
+	for key, value := range table {
+		retval[foldKey(key)] = value
+	}
+
+	return retval
+}
+
+// peekEnv returns the value of the variable named by the key, without
+// updating our fast lookup table.
+func (e *LocalEnv) peekEnv(key string) (string, bool) {
+	i := e.searchPairIndex(key)
+	if i < 0 {
+		return e.lookupArrayValue(key)
+	}
+
+	key = GetKeyFromPair(e.pairs[i])
+	return GetValueFromPair(e.pairs[i], key), true
+}
+
+// exportedPairs returns the entries that ExportedVars should return
+func (e *LocalEnv) exportedPairs() []string {
+	// special case - everything is exported
+	if len(e.pairs) == 0 || (e.isExporter && len(e.exports) == 0) {
+		return e.pairs
+	}
+
+	// general case - we have to check each variable
+	retval := []string{}
+	for _, pair := range e.pairs {
+		if e.IsExported(GetKeyFromPair(pair)) {
+			retval = append(retval, pair)
+		}
+	}
+
+	// all done
+	return retval
+}
+
+// setExported remembers whether the variable named by the key should
+// be exported or not
+func (e *LocalEnv) setExported(method string, key string, exported bool) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{method}
+	}
+
+	// make sure we have a key that we can work with
+	err := e.checkKey(key)
+	if err != nil {
+		return err
+	}
+
+	// do we have a map to write to?
+	if e.exports == nil {
+		e.exports = make(map[string]bool)
+	}
+
+	// yes we do
+	e.exports[e.foldKey(key)] = exported
+
+	// all done
+	return nil
+}
+
 func (e *LocalEnv) appendPairIndex(key, value string) {
 	// do we have a map to write to?
 	if e.pairKeys == nil {
@@ -319,10 +1050,290 @@ func (e *LocalEnv) appendPairIndex(key, value string) {
 
 	// add the new keys to the end of the map
 	e.pairs = append(e.pairs, key+"="+value)
-	e.pairKeys[key] = len(e.pairs) - 1
+	e.pairKeys[e.foldKey(key)] = len(e.pairs) - 1
+	e.rememberUpperKey(key)
 }
 
 func (e *LocalEnv) makePairIndex() {
 	// set aside some space to store our faster lookups
 	e.pairKeys = make(map[string]int, 10)
+
+	// we will rebuild this one if we need it again
+	e.upperKeys = nil
+}
+
+// findUpperKey returns the name of a variable whose key is the same as
+// the given key, once they are both in upper case
+//
+// it is how an OverlayEnv that ignores case finds variables in here,
+// when we do not ignore case ourselves
+func (e *LocalEnv) findUpperKey(key string) (string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return key, false
+	}
+
+	// do we need to build our lookup table?
+	if e.upperKeys == nil {
+		e.upperKeys = make(map[string][]string, len(e.pairs)+len(e.arrays))
+		for _, name := range e.MatchVarNames("") {
+			e.rememberUpperKey(name)
+		}
+	}
+
+	// do we have it?
+	keys := e.upperKeys[strings.ToUpper(key)]
+	if len(keys) == 0 {
+		return key, false
+	}
+
+	return keys[0], true
+}
+
+// rememberUpperKey adds a new variable to our upper-case lookup table,
+// if we have one
+func (e *LocalEnv) rememberUpperKey(key string) {
+	if e.upperKeys == nil {
+		return
+	}
+
+	upperKey := strings.ToUpper(key)
+	e.upperKeys[upperKey] = append(e.upperKeys[upperKey], key)
+}
+
+// forgetUpperKey removes a deleted variable from our upper-case lookup
+// table, if we have one
+func (e *LocalEnv) forgetUpperKey(key string) {
+	if e.upperKeys == nil {
+		return
+	}
+
+	upperKey := strings.ToUpper(key)
+	keys := []string{}
+	for _, name := range e.upperKeys[upperKey] {
+		if name != key {
+			keys = append(keys, name)
+		}
+	}
+
+	if len(keys) == 0 {
+		delete(e.upperKeys, upperKey)
+		return
+	}
+	e.upperKeys[upperKey] = keys
+}
+
+// lookupArray returns the array named by the key
+func (e *LocalEnv) lookupArray(key string) (arrayVar, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return arrayVar{}, false
+	}
+
+	// yes we do
+	arr, ok := e.arrays[e.foldKey(key)]
+	return arr, ok
+}
+
+// lookupArrayValue returns the value of element 0 of the array named
+// by the key
+func (e *LocalEnv) lookupArrayValue(key string) (string, bool) {
+	arr, ok := e.arrays[e.foldKey(key)]
+	if !ok {
+		return "", false
+	}
+
+	return arr.get("0")
+}
+
+// matchArrayNames returns the names of all arrays that start with the
+// given prefix, in alphabetical order
+func (e *LocalEnv) matchArrayNames(prefix string) []string {
+	retval := []string{}
+	for key := range e.arrays {
+		if e.hasKeyPrefix(key, prefix) {
+			retval = append(retval, key)
+		}
+	}
+	sort.Strings(retval)
+
+	return retval
+}
+
+// setArray replaces the variable named by the key with the given array
+func (e *LocalEnv) setArray(method string, key string, arr arrayVar) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{method}
+	}
+
+	// make sure we have a key and values that we can work with
+	err := e.checkKey(key)
+	if err != nil {
+		return err
+	}
+	for _, value := range arr.elems {
+		err = checkValue(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	// are we allowed to change it?
+	if e.readOnly[e.foldKey(key)] {
+		return ErrReadOnlyVar{Key: key}
+	}
+
+	// yes we are
+	e.storeArray(key, arr)
+
+	// all done
+	return nil
+}
+
+// storeArray saves the given array, replacing any ordinary variable
+// that has the same name, and tells any OnChange hooks about it
+func (e *LocalEnv) storeArray(key string, arr arrayVar) {
+	ev := ChangeEvent{Op: ChangeSet, Key: key}
+	ev.OldValue, ev.WasSet = e.peekEnv(key)
+	ev.NewValue, _ = arr.get("0")
+
+	// do we have an ordinary variable to get rid of?
+	i := e.findPairIndex(key)
+	if i >= 0 {
+		e.pairs = append(e.pairs[:i], e.pairs[i+1:]...)
+		e.makePairIndex()
+	}
+
+	// do we have a map to write to?
+	if e.arrays == nil {
+		e.arrays = make(map[string]arrayVar)
+	}
+
+	// yes we do
+	_, isArray := e.arrays[e.foldKey(key)]
+	if !isArray {
+		e.rememberUpperKey(e.foldKey(key))
+	}
+	e.arrays[e.foldKey(key)] = arr
+
+	// tell anyone who is interested
+	e.hooks.notify(ev)
+}
+
+// copyArrays returns a copy of every array variable, for Snapshot to use
+func (e *LocalEnv) copyArrays() map[string]arrayVar {
+	// do we have an environment store to work with?
+	if e == nil {
+		return nil
+	}
+
+	// yes we do
+	retval := make(map[string]arrayVar, len(e.arrays))
+	for key, arr := range e.arrays {
+		retval[key] = arr.clone()
+	}
+
+	return retval
+}
+
+// restoreArrays replaces every array variable with a copy of the given
+// arrays, for Snapshot to use
+//
+// read-only arrays are left alone
+func (e *LocalEnv) restoreArrays(arrays map[string]arrayVar) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// get rid of anything that has been added since
+	for key := range e.arrays {
+		_, ok := arrays[key]
+		if !ok {
+			e.Unsetenv(key)
+		}
+	}
+
+	// put back anything that has been changed or deleted
+	for key, arr := range arrays {
+		if e.readOnly[e.foldKey(key)] {
+			continue
+		}
+
+		current, ok := e.arrays[e.foldKey(key)]
+		if ok && current.equals(arr) {
+			continue
+		}
+
+		e.storeArray(key, arr.clone())
+	}
+}
+
+// checkKey returns an error if the key cannot be used as the name of
+// a new variable
+func (e *LocalEnv) checkKey(key string) error {
+	// every key has to fit in a "key=value" pair, whatever our policy
+	// says
+	err := checkPairKey(key)
+	if err != nil {
+		return err
+	}
+
+	// do we have a policy to apply?
+	if e.keyPolicy == nil {
+		return nil
+	}
+
+	// yes we do
+	reason := e.keyPolicy(key)
+	if len(reason) > 0 {
+		return ErrInvalidKey{Key: key, Reason: reason}
+	}
+
+	// all done
+	return nil
+}
+
+// keyChecker is the interface that wraps environments that can tell us
+// whether a key can be used, without changing anything
+type keyChecker interface {
+	checkKey(key string) error
+}
+
+// checkKeyOf returns an error if the given environment cannot use the
+// key as the name of a new variable
+func checkKeyOf(r Reader, key string) error {
+	checker, ok := r.(keyChecker)
+	if ok {
+		return checker.checkKey(key)
+	}
+
+	// if we get here, all we can do is the bare minimum
+	return checkPairKey(key)
+}
+
+// checkPairKey returns an error if the key cannot be stored in a
+// "key=value" pair
+func checkPairKey(key string) error {
+	if len(key) == 0 || len(strings.TrimSpace(key)) == 0 {
+		return ErrEmptyKey{}
+	}
+
+	reason := PermissiveKeys(key)
+	if len(reason) > 0 {
+		return ErrInvalidKey{Key: key, Reason: reason}
+	}
+
+	return nil
+}
+
+// checkValue returns an error if the value cannot be stored in a
+// "key=value" pair
+func checkValue(key, value string) error {
+	if strings.ContainsRune(value, 0) {
+		return ErrNULInValue{Key: key}
+	}
+
+	return nil
 }
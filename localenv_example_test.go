@@ -214,6 +214,17 @@ func ExampleLocalEnv_Expand() {
 	fmt.Print(localEnv.Expand("USER is ${USER}\n"))
 }
 
+func ExampleLocalEnv_ExpandE() {
+	// create an environment store
+	localEnv := envish.NewLocalEnv()
+
+	// find out if anything went wrong
+	_, err := localEnv.ExpandE("${DB_HOST:?must be set}")
+	fmt.Print(err)
+	// Output:
+	// DB_HOST: must be set
+}
+
 // ================================================================
 //
 // Unique methods examples
@@ -231,3 +242,53 @@ func ExampleLocalEnv_Length() {
 	// Output:
 	// environment has 0 entries
 }
+
+func ExampleLocalEnv_OnChange() {
+	// create an environment store
+	localEnv := envish.NewLocalEnv()
+
+	// find out whenever anything changes
+	localEnv.OnChange(func(ev envish.ChangeEvent) {
+		fmt.Printf("%s %s=%s\n", ev.Op, ev.Key, ev.NewValue)
+	})
+
+	// string expansion can change the environment too
+	localEnv.Setenv("DB_HOST", "localhost")
+	localEnv.Expand("${DB_PORT:=5432}")
+	// Output:
+	// set DB_HOST=localhost
+	// set DB_PORT=5432
+}
+
+func ExampleLocalEnv_SetArray() {
+	// create an environment store
+	localEnv := envish.NewLocalEnv()
+
+	// arrays work just like they do in bash
+	localEnv.SetArray("FILES", "a.txt", "b.txt", "c.txt")
+	localEnv.SetAssoc("PORTS", map[string]string{"http": "80", "https": "443"})
+
+	fmt.Println(localEnv.Expand("${FILES[1]}"))
+	fmt.Println(localEnv.Expand("${#FILES[@]} files: ${FILES[@]}"))
+	fmt.Println(localEnv.Expand("${!PORTS[@]}"))
+	fmt.Println(localEnv.Expand("https is on port ${PORTS[https]}"))
+	// Output:
+	// b.txt
+	// 3 files: a.txt b.txt c.txt
+	// http https
+	// https is on port 443
+}
+
+func ExampleCaseInsensitiveKeys() {
+	// create a Windows-style environment store
+	localEnv := envish.NewLocalEnv(envish.SetAsExporter, envish.CaseInsensitiveKeys)
+
+	// Path and PATH are the same variable
+	localEnv.Setenv("Path", `C:\Windows`)
+	localEnv.Setenv("PATH", `C:\Windows;C:\Tools`)
+
+	// the variable keeps its original name
+	fmt.Println(localEnv.Environ())
+	// Output:
+	// [Path=C:\Windows;C:\Tools]
+}
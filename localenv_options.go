@@ -35,8 +35,39 @@
 
 package envish
 
-// SetAsExporter sets a flag so that the EnvStack will include its contents
-// when building an environ to export to Golang's exec package.
+// SetAsExporter sets a flag so that every variable is exported by default.
+// ExportedVars (and OverlayEnv.Environ) will include them when building an
+// environ to export to Golang's exec package.
+//
+// Use LocalEnv.Unexport to leave out individual variables.
 func SetAsExporter(e *LocalEnv) {
 	e.isExporter = true
 }
+
+// CaseInsensitiveKeys sets a flag so that keys which only differ by case
+// refer to the same variable, just like they do on Windows. `Path` and
+// `PATH` are the same variable.
+//
+// Each variable keeps the case of the name it was first set with, and
+// that is the name that Environ returns.
+//
+// If the environment store already holds several variables that only
+// differ by case (for example, because CopyProgramEnv was applied first),
+// only the first one is kept.
+func CaseInsensitiveKeys(e *LocalEnv) {
+	e.caseInsensitive = true
+	e.foldKeys()
+}
+
+// WithKeyPolicy returns an option that makes the environment store check
+// every new key with the given KeyPolicy. Setenv (and the array methods)
+// return ErrInvalidKey if the policy rejects the key.
+//
+// Whatever the policy says, the environment store always rejects keys
+// that PermissiveKeys rejects, because they cannot be stored in a
+// "key=value" pair. Without a KeyPolicy, that is all it rejects.
+func WithKeyPolicy(policy KeyPolicy) func(*LocalEnv) {
+	return func(e *LocalEnv) {
+		e.keyPolicy = policy
+	}
+}
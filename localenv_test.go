@@ -629,7 +629,7 @@ func TestLocalEnvGetenvSetenvLookupEnvSupportDollarVars(t *testing.T) {
 	env.Setenv(testKey, expectedResult)
 	actualResult1 := env.Getenv(testKey)
 	actualResult2, ok := env.LookupEnv(testKey)
-	actualEnviron := env.Environ()
+	actualEnviron := env.AllVars()
 
 	// ----------------------------------------------------------------
 	// test the results
@@ -1033,39 +1033,1582 @@ func TestLocalEnvExpandReturnsOriginalStringIfExpansionFails(t *testing.T) {
 	assert.Equal(t, expectedResult, actualResult)
 }
 
-func TestLocalEnvLengthCopesWithEmptyStruct(t *testing.T) {
+func TestLocalEnvExpandCopesWithInputThatShellExpandCannotParse(t *testing.T) {
+	t.Parallel()
+
 	// ----------------------------------------------------------------
 	// setup your test
 
-	env := envish.LocalEnv{}
-	expectedResult := 0
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+
+	// these inputs used to make shellexpand panic, hang, or get the
+	// answer wrong
+	testData := map[string]string{
+		"$":                  "$",
+		"cost 5$":            "cost 5$",
+		"a $ b":              "a $ b",
+		"$$":                 "",
+		"pid $$ end":         "pid  end",
+		"$PARAM1$":           "foo$",
+		"$PARAM1$PARAM2":     "foobar",
+		"$PARAM1,":           "foo,",
+		"${PARAM3:-a $ b}":   "a $ b",
+		"${PARAM3:-$}":       "$",
+		"${PARAM3:-$PARAM1}": "foo",
+		"$(date)":            "$(date)",
+	}
+
+	for input, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult := env.Expand(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedResult, actualResult, input)
+	}
+}
+
+func TestLocalEnvExpandSupportsOperatorsThatOnlyCheckIfAVariableIsSet(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("EMPTY", "")
+
+	testData := map[string]string{
+		"${PARAM1-default}":          "foo",
+		"${EMPTY-default}":           "",
+		"${UNSET-default}":           "default",
+		"${UNSET-$PARAM1}":           "foo",
+		"${PARAM1+alt}":              "alt",
+		"${EMPTY+alt}":               "alt",
+		"${UNSET+alt}":               "",
+		"${EMPTY=assigned}":          "",
+		"${PARAM1?must be set}":      "foo",
+		"${EMPTY?must be set}":       "",
+		"${UNSET2=foo} ${UNSET2-x}":  "foo foo",
+		"${UNSET3:=foo} ${UNSET3+x}": "foo x",
+	}
+
+	for input, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult := env.Expand(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedResult, actualResult, input)
+	}
+	assert.Equal(t, "", env.Getenv("EMPTY"))
+	assert.Equal(t, "foo", env.Getenv("UNSET2"))
+	assert.Equal(t, "foo", env.Getenv("UNSET3"))
+	_, ok := env.LookupEnv("UNSET")
+	assert.False(t, ok)
+}
+
+func TestLocalEnvExpandEReportsUnsetVariableForQuestionMarkOperator(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	expectedError := envish.ErrUnsetVariable{Name: "PARAM1", Message: "must be set"}
 
 	// ----------------------------------------------------------------
 	// perform the change
 
-	actualResult := env.Length()
+	_, err := env.ExpandE("${PARAM1?must be set}")
 
 	// ----------------------------------------------------------------
 	// test the results
 
-	assert.Equal(t, expectedResult, actualResult)
+	assert.Equal(t, expectedError, err)
 }
 
-func TestLocalEnvLengthCopesWithNilPointer(t *testing.T) {
+func TestLocalEnvImplementsCheckedExpander(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewLocalEnv()
+	var i interface{} = unit
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, ok := i.(envish.CheckedExpander)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, ok)
+}
+
+func TestLocalEnvExpandECopesWithNilPointer(t *testing.T) {
 	// ----------------------------------------------------------------
 	// setup your test
 
 	var env *envish.LocalEnv = nil
-	expectedResult := 0
 
 	// ----------------------------------------------------------------
 	// perform the change
 
-	actualResult := env.Length()
+	actualResult, err := env.ExpandE("hello ${HOME}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hello ", actualResult)
+}
+
+func TestLocalEnvExpandEUsesEntriesInTheTemporaryEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("$1", "bar")
+	expectedResult := "hello foo, bar and baz"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandE("hello ${PARAM1}, $1 and ${PARAM2:-baz}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestLocalEnvExpandEReturnsErrUnsetVariableForUnsetVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	expectedError := envish.ErrUnsetVariable{Name: "PARAM1", Message: "must be set"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandE("hello ${PARAM1:?must be set}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, "", actualResult)
+}
+
+func TestLocalEnvExpandEReturnsErrUnsetVariableForEmptyVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "")
+	expectedError := envish.ErrUnsetVariable{Name: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := env.ExpandE("hello ${PARAM1:?}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestLocalEnvExpandEAcceptsSetVariableWithErrorOperator(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	expectedResult := "hello foo"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandE("hello ${PARAM1:?must be set}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestLocalEnvExpandEChecksWordsThatWillBeExpanded(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err1 := env.ExpandE("${PARAM1:-${PARAM2:?}}")
+	_, err2 := env.ExpandE("${PARAM2:-${PARAM3:?}}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err1)
+	assert.Equal(t, envish.ErrUnsetVariable{Name: "PARAM3"}, err2)
+}
+
+func TestLocalEnvExpandEKnowsAboutEarlierAssignments(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	expectedResult := "foo foo"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandE("${PARAM1:=foo} ${PARAM1:?}")
 
 	// ----------------------------------------------------------------
 	// test the results
 
+	assert.Nil(t, err)
 	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestLocalEnvExpandEReturnsErrBadSubstitutionForMalformedInput(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	testData := map[string]int{
+		"hello ${PARAM1":         6,
+		"hello ${PARAM1} ${":     16,
+		"hello ${}":              6,
+		"hello ${PARAM1 PARAM2}": 6,
+		"hello ${#PARAM1:-foo}":  6,
+		"${PARAM2:-${}}":         10,
+	}
+
+	for input, pos := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		_, err := env.ExpandE(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, envish.ErrBadSubstitution{Pos: pos}, err, input)
+	}
+}
+
+func TestLocalEnvExpandECopesWithInputThatShellExpandCannotParse(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+
+	// these inputs used to make shellexpand panic, hang, or get the
+	// answer wrong
+	testData := map[string]string{
+		"a $ b":            "a $ b",
+		"pid $$ end":       "pid  end",
+		"$PARAM1$PARAM2":   "foobar",
+		"${PARAM3:-a $ b}": "a $ b",
+		"cost 5$":          "cost 5$",
+		"$PARAM1$":         "foo$",
+		"hello $":          "hello $",
+	}
+
+	for input, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult, err := env.ExpandE(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Nil(t, err, input)
+		assert.Equal(t, expectedResult, actualResult, input)
+
+		// Expand must agree
+		assert.Equal(t, expectedResult, env.Expand(input), input)
+	}
+}
+
+func TestLocalEnvExpandEIgnoresEscapedDollarSigns(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := env.ExpandE("hello \\${PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+}
+
+func TestLocalEnvExpandEReturnsErrorIfExpansionFails(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// the search pattern is invalid, and this will trigger an error
+	testData := "hello ${TestSequenceKey#abc[}"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := env.ExpandE(testData)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Error(t, err)
+}
+
+func TestLocalEnvExpandWithNoUnsetReportsEveryUnboundVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	expectedError := envish.ErrUnboundVariables{
+		Names: []string{"PARAM2", "PARAM3", "1"},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := env.ExpandWith(
+		"$PARAM1 $PARAM2 ${PARAM3} ${#PARAM2} $1",
+		envish.ExpandOptions{NoUnset: true},
+	)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestLocalEnvExpandWithNoUnsetAcceptsExpansionsThatSupplyAValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	expectedResult := "foo bar  "
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandWith(
+		"${PARAM1:-foo} ${PARAM2:=bar} ${PARAM3:+baz} $@",
+		envish.ExpandOptions{NoUnset: true},
+	)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestLocalEnvExpandWithNoUnsetChecksWordsThatWillBeExpanded(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	expectedError := envish.ErrUnboundVariables{Names: []string{"PARAM2"}}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := env.ExpandWith(
+		"${PARAM1:-$PARAM2} ${PARAM3:+$PARAM4}",
+		envish.ExpandOptions{NoUnset: true},
+	)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestLocalEnvExpandWithoutOptionsBehavesLikeExpandE(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	expectedResult := "hello "
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandWith("hello $PARAM1", envish.ExpandOptions{})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestLocalEnvLengthCopesWithEmptyStruct(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.LocalEnv{}
+	expectedResult := 0
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := env.Length()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestLocalEnvLengthCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var env *envish.LocalEnv = nil
+	expectedResult := 0
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := env.Length()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+
+}
+
+// ================================================================
+//
+// Change notifications
+//
+// ----------------------------------------------------------------
+
+func TestLocalEnvOnChangeReportsEveryChange(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	var actualResult []envish.ChangeEvent
+	env.OnChange(func(ev envish.ChangeEvent) {
+		actualResult = append(actualResult, ev)
+	})
+
+	expectedResult := []envish.ChangeEvent{
+		{Op: envish.ChangeSet, Key: "PARAM1", OldValue: "foo", WasSet: true, NewValue: "bar"},
+		{Op: envish.ChangeSet, Key: "PARAM2", NewValue: "baz"},
+		{Op: envish.ChangeUnset, Key: "PARAM1", OldValue: "bar", WasSet: true},
+		{Op: envish.ChangeSet, Key: "PARAM3", NewValue: "default"},
+		{Op: envish.ChangeClear},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Setenv("PARAM1", "bar")
+	env.Setenv("PARAM2", "baz")
+	env.Unsetenv("PARAM1")
+	env.Unsetenv("PARAM1")
+	env.Expand("${PARAM3:=default}")
+	env.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestLocalEnvOnChangeDoesNotReportFailedChanges(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.SetReadOnly("PARAM1")
+
+	called := false
+	env.OnChange(func(ev envish.ChangeEvent) {
+		called = true
+	})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Setenv("", "foo")
+	env.Setenv("PARAM1", "bar")
+	env.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, called)
+}
+
+func TestLocalEnvOnChangeCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var env *envish.LocalEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.OnChange(func(ev envish.ChangeEvent) {})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	// as long as it didn't panic, we're good
+}
+
+// ================================================================
+//
+// Exported variables
+//
+// ----------------------------------------------------------------
+
+func TestLocalEnvExportedVarsOnlyReturnsExportedVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+	env.Setenv("PARAM3", "baz")
+
+	expectedExportedVars := []string{"PARAM2=bar"}
+	expectedAllVars := []string{"PARAM1=foo", "PARAM2=bar", "PARAM3=baz"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Export("PARAM2")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedExportedVars, env.ExportedVars())
+	assert.Equal(t, expectedAllVars, env.AllVars())
+
+	// Environ still returns everything, just like it always has
+	assert.Equal(t, expectedAllVars, env.Environ())
+	assert.False(t, env.IsExported("PARAM1"))
+	assert.True(t, env.IsExported("PARAM2"))
+}
+
+func TestLocalEnvExportersExportEveryVariableByDefault(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+
+	expectedResult := []string{"PARAM2=bar"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Unexport("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, env.ExportedVars())
+	assert.False(t, env.IsExported("PARAM1"))
+	assert.True(t, env.IsExported("PARAM2"))
+}
+
+func TestLocalEnvExportWorksBeforeTheVariableIsSet(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	expectedResult := []string{"PARAM1=foo"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Export("PARAM1")
+	env.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, env.Environ())
+}
+
+func TestLocalEnvUnsetenvForgetsTheExportAttribute(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Export("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Unsetenv("PARAM1")
+	env.Setenv("PARAM1", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, env.IsExported("PARAM1"))
+	assert.Empty(t, env.ExportedVars())
+}
+
+func TestLocalEnvCopyProgramEnvExportsEveryVariable(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env := envish.NewLocalEnv(envish.CopyProgramEnv)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, os.Environ(), env.Environ())
+}
+
+func TestLocalEnvExportReturnsErrEmptyKey(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	expectedError := envish.ErrEmptyKey{}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.Export("")
+	err2 := env.Unexport(" ")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err1)
+	assert.Equal(t, expectedError, err2)
+}
+
+func TestLocalEnvExportCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var env *envish.LocalEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.Export("PARAM1")
+	err2 := env.Unexport("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrNilPointer{"LocalEnv.Export"}, err1)
+	assert.Equal(t, envish.ErrNilPointer{"LocalEnv.Unexport"}, err2)
+	assert.False(t, env.IsExported("PARAM1"))
+	assert.Equal(t, []string{}, env.AllVars())
+}
+
+// ================================================================
+//
+// Read-only variables
+//
+// ----------------------------------------------------------------
+
+func TestLocalEnvSetReadOnlyStopsSetenv(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.SetReadOnly("PARAM1")
+	err2 := env.Setenv("PARAM1", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err1)
+	assert.Equal(t, expectedError, err2)
+	assert.Equal(t, "foo", env.Getenv("PARAM1"))
+	assert.True(t, env.IsReadOnly("PARAM1"))
+}
+
+func TestLocalEnvSetReadOnlyWorksForUnsetVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.SetReadOnly("PARAM1")
+	err := env.Setenv("PARAM1", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	_, ok := env.LookupEnv("PARAM1")
+	assert.False(t, ok)
+}
+
+func TestLocalEnvUnsetenvSkipsReadOnlyVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.SetReadOnly("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "foo", env.Getenv("PARAM1"))
+}
+
+func TestLocalEnvClearenvKeepsReadOnlyVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+	env.Setenv("PARAM3", "baz")
+	env.SetReadOnly("PARAM2")
+
+	expectedResult := []string{"PARAM2=bar"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, env.AllVars())
+	assert.Equal(t, "bar", env.Getenv("PARAM2"))
+}
+
+func TestLocalEnvExpandERespectsReadOnlyVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetReadOnly("PARAM1")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := env.ExpandE("${PARAM1:=foo}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	_, ok := env.LookupEnv("PARAM1")
+	assert.False(t, ok)
+}
+
+func TestLocalEnvSetReadOnlyReturnsErrEmptyKey(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	expectedError := envish.ErrEmptyKey{}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.SetReadOnly(" ")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestLocalEnvSetReadOnlyCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var env *envish.LocalEnv = nil
+
+	expectedError := envish.ErrNilPointer{"LocalEnv.SetReadOnly"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.SetReadOnly("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.False(t, env.IsReadOnly("PARAM1"))
+}
+
+// ================================================================
+//
+// Arrays
+//
+// ----------------------------------------------------------------
+
+func TestLocalEnvSetArrayCreatesIndexedArray(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.SetArray("ARR", "foo", "bar", "baz")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.True(t, env.IsArray("ARR"))
+
+	actualValues, ok := env.GetArray("ARR")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"foo", "bar", "baz"}, actualValues)
+
+	actualValue, ok := env.GetElement("ARR", "1")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", actualValue)
+
+	actualValue, ok = env.GetElement("ARR", "-1")
+	assert.True(t, ok)
+	assert.Equal(t, "baz", actualValue)
+
+	_, ok = env.GetElement("ARR", "3")
+	assert.False(t, ok)
+}
+
+func TestLocalEnvSetAssocCreatesAssociativeArray(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	testData := map[string]string{"one": "foo", "two": "bar"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.SetAssoc("MAP", testData)
+	testData["three"] = "baz"
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.True(t, env.IsArray("MAP"))
+
+	actualValues, ok := env.GetAssoc("MAP")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"one": "foo", "two": "bar"}, actualValues)
+
+	actualValue, ok := env.GetElement("MAP", "two")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", actualValue)
+}
+
+func TestLocalEnvArraysAreNotIncludedInEnviron(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.SetArray("ARR", "foo", "bar")
+	env.Export("ARR")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM1=foo"}, env.Environ())
+	assert.Equal(t, []string{"PARAM1=foo"}, env.AllVars())
+	assert.Equal(t, 1, env.Length())
+}
+
+func TestLocalEnvSetArrayReplacesOrdinaryVariable(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("ARR", "old")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.SetArray("ARR", "foo", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{}, env.AllVars())
+	assert.True(t, env.IsArray("ARR"))
+}
+
+func TestLocalEnvArrayNameWorksLikeElementZero(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo", "bar")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Setenv("ARR", "changed")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	actualValue, ok := env.LookupEnv("ARR")
+	assert.True(t, ok)
+	assert.Equal(t, "changed", actualValue)
+	assert.Equal(t, "changed", env.Getenv("ARR"))
+
+	actualValues, _ := env.GetArray("ARR")
+	assert.Equal(t, []string{"changed", "bar"}, actualValues)
+	assert.Equal(t, []string{"ARR"}, env.MatchVarNames("AR"))
+}
+
+func TestLocalEnvSetElementConvertsOrdinaryVariable(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("ARR", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.SetElement("ARR", "2", "baz")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	actualValues, ok := env.GetAssoc("ARR")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"0": "foo", "2": "baz"}, actualValues)
+	assert.Equal(t, []string{}, env.AllVars())
+}
+
+func TestLocalEnvSetElementReturnsErrInvalidArrayIndex(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo")
+
+	expectedError := envish.ErrInvalidArrayIndex{Key: "ARR", Index: "one"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.SetElement("ARR", "one", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestLocalEnvUnsetElementKeepsOtherIndexes(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo", "bar", "baz")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.UnsetElement("ARR", "1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	actualValues, _ := env.GetArray("ARR")
+	assert.Equal(t, []string{"foo", "baz"}, actualValues)
+	actualValue, _ := env.GetElement("ARR", "2")
+	assert.Equal(t, "baz", actualValue)
+}
+
+func TestLocalEnvUnsetenvDeletesWholeArray(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo", "bar")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Unsetenv("ARR")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, env.IsArray("ARR"))
+	_, ok := env.LookupEnv("ARR")
+	assert.False(t, ok)
+}
+
+func TestLocalEnvClearenvKeepsReadOnlyArrays(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR1", "foo")
+	env.SetArray("ARR2", "bar")
+	env.SetReadOnly("ARR2")
+	env.Export("ARR1")
+	env.Export("ARR2")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, env.IsArray("ARR1"))
+	assert.True(t, env.IsArray("ARR2"))
+
+	// just like read-only scalars, they keep their export attribute
+	assert.False(t, env.IsExported("ARR1"))
+	assert.True(t, env.IsExported("ARR2"))
+}
+
+func TestLocalEnvArraysRespectReadOnly(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo")
+	env.SetReadOnly("ARR")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "ARR"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.SetArray("ARR", "bar")
+	err2 := env.SetAssoc("ARR", map[string]string{})
+	err3 := env.SetElement("ARR", "0", "bar")
+	env.UnsetElement("ARR", "0")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err1)
+	assert.Equal(t, expectedError, err2)
+	assert.Equal(t, expectedError, err3)
+	actualValues, _ := env.GetArray("ARR")
+	assert.Equal(t, []string{"foo"}, actualValues)
+}
+
+func TestLocalEnvArraysCopeWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var env *envish.LocalEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.SetArray("ARR", "foo")
+	err2 := env.SetAssoc("ARR", map[string]string{})
+	err3 := env.SetElement("ARR", "0", "foo")
+	env.UnsetElement("ARR", "0")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrNilPointer{"LocalEnv.SetArray"}, err1)
+	assert.Equal(t, envish.ErrNilPointer{"LocalEnv.SetAssoc"}, err2)
+	assert.Equal(t, envish.ErrNilPointer{"LocalEnv.SetElement"}, err3)
+	assert.False(t, env.IsArray("ARR"))
+	_, ok := env.GetArray("ARR")
+	assert.False(t, ok)
+	_, ok = env.GetAssoc("ARR")
+	assert.False(t, ok)
+	_, ok = env.GetElement("ARR", "0")
+	assert.False(t, ok)
+}
+
+func TestLocalEnvExpandSupportsArrays(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo", "bar", "baz")
+	env.SetAssoc("MAP", map[string]string{"one": "1", "two": "2"})
+	env.Setenv("I", "2")
+
+	testData := map[string]string{
+		"${ARR[1]}":          "bar",
+		"${ARR[$I]}":         "baz",
+		"${ARR[-1]}":         "baz",
+		"${ARR[@]}":          "foo bar baz",
+		"${ARR[*]}":          "foo bar baz",
+		"${#ARR[@]}":         "3",
+		"${#ARR[1]}":         "3",
+		"$ARR":               "foo",
+		"${MAP[two]}":        "2",
+		"${!MAP[@]}":         "one two",
+		"${MAP[@]}":          "1 2",
+		"${ARR[5]:-default}": "default",
+		"${ARR[1]^^}":        "BAR",
+		"${ARR[1]#b}":        "ar",
+		"x${ARR[0]}y":        "xfooy",
+		"${NOPE:-${ARR[2]}}": "baz",
+		"${#NOPE[@]}":        "0",
+	}
+
+	for input, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult, err := env.ExpandE(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Nil(t, err, input)
+		assert.Equal(t, expectedResult, actualResult, input)
+	}
+}
+
+func TestLocalEnvExpandCanAssignToArrayElements(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := env.Expand("${ARR[1]:=bar}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "bar", actualResult)
+	actualValues, _ := env.GetArray("ARR")
+	assert.Equal(t, []string{"foo", "bar"}, actualValues)
+}
+
+func TestLocalEnvExpandSeesArrayElementsAssignedEarlierInTheString(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := map[string]string{
+		"${arr[7]:=new}${arr[7]}":        "newnew",
+		"${arr[7]:=new} ${#arr[@]}":      "new 2",
+		"${arr[7]:=new} ${!arr[@]}":      "new 0 7",
+		"${arr[7]:=new} ${arr[@]}":       "new foo new",
+		"${arr[7]:=new} ${arr[7]:=next}": "new new",
+	}
+
+	for input, expectedResult := range testData {
+		env := envish.NewLocalEnv()
+		env.SetArray("arr", "foo")
+
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult := env.Expand(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedResult, actualResult, input)
+	}
+}
+
+func TestLocalEnvExpandReportsArrayElementsByName(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err1 := env.ExpandWith("${ARR[0]} ${ARR[1]}", envish.ExpandOptions{NoUnset: true})
+	_, err2 := env.ExpandE("${ARR[1]:?not set}")
+	_, err3 := env.ExpandE("${ARR[0]} ${")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrUnboundVariables{Names: []string{"ARR[1]"}}, err1)
+	assert.Equal(t, envish.ErrUnsetVariable{Name: "ARR[1]", Message: "not set"}, err2)
+	assert.Equal(t, envish.ErrBadSubstitution{Pos: 10}, err3)
+}
+
+func TestLocalEnvOnChangeReportsArrayChanges(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	var actualResult []envish.ChangeEvent
+	env.OnChange(func(ev envish.ChangeEvent) {
+		actualResult = append(actualResult, ev)
+	})
+
+	expectedResult := []envish.ChangeEvent{
+		{Op: envish.ChangeSet, Key: "ARR", NewValue: "foo"},
+		{Op: envish.ChangeSet, Key: "ARR", OldValue: "foo", WasSet: true, NewValue: "FOO"},
+		{Op: envish.ChangeSet, Key: "ARR", OldValue: "FOO", WasSet: true, NewValue: "FOO"},
+		{Op: envish.ChangeSet, Key: "ARR", OldValue: "FOO", WasSet: true, NewValue: "FOO"},
+		{Op: envish.ChangeSet, Key: "MAP", NewValue: ""},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.SetArray("ARR", "foo", "bar")
+	env.SetElement("ARR", "0", "FOO")
+	env.Expand("${ARR[2]:=baz}")
+	env.UnsetElement("ARR", "1")
+	env.UnsetElement("ARR", "5")
+	env.SetAssoc("MAP", map[string]string{"one": "1"})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+	actualValues, _ := env.GetArray("ARR")
+	assert.Equal(t, []string{"FOO", "baz"}, actualValues)
+}
+
+func TestLocalEnvExpandDoesNotConfuseArraysWithOtherVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo", "bar")
+	env.Setenv("__envish_array_0", "real")
+
+	testData := map[string]string{
+		"${ARR[1]} ${__envish_array_0}":  "bar real",
+		"${ARR[1]} $__envish_array_0":    "bar real",
+		"${ARR[1]} ${__envish_array_1}x": "bar x",
+		"${ARR[1]} ${!__envish_array*}":  "bar __envish_array_0",
+	}
+
+	for input, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult, err := env.ExpandE(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Nil(t, err, input)
+		assert.Equal(t, expectedResult, actualResult, input)
+	}
+}
+
+func TestLocalEnvExpandAssignsInArraySubscripts(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo", "bar")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandE("${ARR[${I:=1}]} $I")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "bar 1", actualResult)
+	assert.Equal(t, "1", env.Getenv("I"))
+}
+
+func TestLocalEnvExpandEDoesNotAssignInArraySubscriptsIfExpansionFails(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR", "foo", "bar")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err1 := env.ExpandE("${ARR[${I:=1}]} ${NOPE:?not set}")
+	_, err2 := env.ExpandWith("${ARR[${J:=1}]} $NOPE", envish.ExpandOptions{NoUnset: true})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrUnsetVariable{Name: "NOPE", Message: "not set"}, err1)
+	assert.Equal(t, envish.ErrUnboundVariables{Names: []string{"NOPE"}}, err2)
+	_, ok := env.LookupEnv("I")
+	assert.False(t, ok)
+	_, ok = env.LookupEnv("J")
+	assert.False(t, ok)
+}
+
+// ================================================================
+//
+// Case-insensitive keys
+//
+// ----------------------------------------------------------------
+
+func TestLocalEnvCaseInsensitiveKeysIgnoresCaseOnLookup(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter, envish.CaseInsensitiveKeys)
+	env.Setenv("Path", "C:\\Windows")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualValue, ok := env.LookupEnv("PATH")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, env.IsCaseInsensitive())
+	assert.True(t, ok)
+	assert.Equal(t, "C:\\Windows", actualValue)
+	assert.Equal(t, "C:\\Windows", env.Getenv("path"))
+	assert.Equal(t, []string{"Path"}, env.MatchVarNames("PA"))
+}
+
+func TestLocalEnvCaseInsensitiveKeysKeepsOriginalCaseOnUpdate(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter, envish.CaseInsensitiveKeys)
+	env.Setenv("Path", "C:\\Windows")
+
+	var events []envish.ChangeEvent
+	env.OnChange(func(ev envish.ChangeEvent) {
+		events = append(events, ev)
+	})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Setenv("PATH", "C:\\Tools")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Path=C:\\Tools"}, env.Environ())
+	assert.Equal(t, 1, env.Length())
+	assert.Equal(t, "C:\\Windows", events[0].OldValue)
+	assert.True(t, events[0].WasSet)
+}
+
+func TestLocalEnvCaseInsensitiveKeysIgnoresCaseOnUnset(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.CaseInsensitiveKeys)
+	env.Setenv("Path", "C:\\Windows")
+	env.Setenv("Temp", "C:\\Temp")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Unsetenv("PATH")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	_, ok := env.LookupEnv("Path")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"Temp=C:\\Temp"}, env.AllVars())
+}
+
+func TestLocalEnvCaseInsensitiveKeysAppliesToAttributes(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.CaseInsensitiveKeys)
+	env.Setenv("Path", "C:\\Windows")
+	env.SetArray("Args", "one", "two")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Export("PATH")
+	env.SetReadOnly("path")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, env.IsExported("pAtH"))
+	assert.True(t, env.IsReadOnly("PATH"))
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "PATH"}, env.Setenv("PATH", "changed"))
+	assert.Equal(t, []string{"Path=C:\\Windows"}, env.Environ())
+
+	assert.True(t, env.IsArray("ARGS"))
+	assert.Equal(t, "two", env.Expand("${ARGS[1]}"))
+}
+
+func TestLocalEnvCaseInsensitiveKeysFoldsExistingKeys(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	// an option that runs before CaseInsensitiveKeys
+	addVars := func(e *envish.LocalEnv) {
+		e.Setenv("PATH", "/usr/bin")
+		e.Setenv("Path", "C:\\Windows")
+		e.Setenv("HOME", "/home/me")
+		e.Export("home")
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env := envish.NewLocalEnv(addVars, envish.CaseInsensitiveKeys)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PATH=/usr/bin", "HOME=/home/me"}, env.AllVars())
+	assert.Equal(t, []string{"HOME=/home/me"}, env.ExportedVars())
+}
+
+func TestLocalEnvIsCaseSensitiveByDefault(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Setenv("Path", "C:\\Windows")
+	env.Setenv("PATH", "/usr/bin")
+
+	// ----------------------------------------------------------------
+	// test the results
 
+	assert.False(t, env.IsCaseInsensitive())
+	assert.Equal(t, []string{"Path=C:\\Windows", "PATH=/usr/bin"}, env.AllVars())
 }
@@ -0,0 +1,157 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"reflect"
+)
+
+// Marshal writes the fields of the given struct (or pointer to a struct)
+// into the given environment, by calling Setenv for each field.
+//
+// It uses the same `env`, `sep` and `envPrefix` struct tags as Bind, and
+// it formats each value so that Bind can read it back in:
+//
+// * bools are written as `true` or `false`
+//
+// * time.Duration values are written using time.Duration.String()
+//
+// * slices are joined together using the `sep` struct tag (default ",")
+//
+// * any type that implements encoding.TextMarshaler is written using
+// its MarshalText method
+//
+// Nil pointers are skipped. Marshal keeps going when it finds a problem.
+// It returns an ErrMarshal that lists every problem that it found.
+func Marshal(v interface{}, w Writer) error {
+	// do we have an environment to work with?
+	if w == nil {
+		return ErrNilPointer{"Marshal"}
+	}
+
+	// do we have a struct to work with?
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return ErrInvalidMarshalSource{reflect.TypeOf(v)}
+	}
+
+	// yes we do
+	m := marshaler{w: w, visiting: map[reflect.Type]bool{}}
+	m.marshalStruct(rv, "")
+	if len(m.errs) > 0 {
+		return ErrMarshal{m.errs}
+	}
+
+	// all done
+	return nil
+}
+
+// marshaler keeps track of any errors while we write out a struct
+type marshaler struct {
+	w    Writer
+	errs []error
+
+	// visiting holds the struct types that we are already writing out,
+	// so that self-referential types (eg linked lists) don't send us
+	// round in circles forever
+	visiting map[reflect.Type]bool
+}
+
+func (m *marshaler) marshalStruct(v reflect.Value, prefix string) {
+	t := v.Type()
+	m.visiting[t] = true
+	defer delete(m.visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// we can't read unexported fields
+		if field.PkgPath != "" {
+			continue
+		}
+
+		// is this a nested struct?
+		name, _ := parseEnvTag(field.Tag)
+		if len(name) == 0 {
+			if isNestedStruct(field.Type, textMarshalerType) && !m.visiting[structType(field.Type)] {
+				fv := v.Field(i)
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						continue
+					}
+					fv = fv.Elem()
+				}
+				m.marshalStruct(fv, prefix+field.Tag.Get("envPrefix"))
+			}
+			continue
+		}
+
+		// no, it's a field we need to write out
+		m.marshalField(v.Field(i), field, prefix+name)
+	}
+}
+
+func (m *marshaler) marshalField(v reflect.Value, field reflect.StructField, key string) {
+	// can we write out this field?
+	if !isFormattableType(field.Type) {
+		m.errs = append(m.errs, ErrUnsupportedType{Key: key, Type: typeName(field.Type)})
+		return
+	}
+
+	// skip nil pointers
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return
+	}
+
+	sep, ok := field.Tag.Lookup("sep")
+	if !ok {
+		sep = ","
+	}
+
+	value, ok := formatValue(v, sep)
+	if !ok {
+		m.errs = append(m.errs, ErrInvalidValue{Key: key, Value: value, Type: typeName(field.Type)})
+		return
+	}
+
+	err := m.w.Setenv(key, value)
+	if err != nil {
+		m.errs = append(m.errs, err)
+	}
+}
@@ -0,0 +1,370 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// Test helpers
+//
+// ----------------------------------------------------------------
+
+type brokenTextMarshaler struct{}
+
+func (b brokenTextMarshaler) MarshalText() ([]byte, error) {
+	return nil, errors.New("brokenTextMarshaler.MarshalText")
+}
+
+func (b *brokenTextMarshaler) UnmarshalText([]byte) error {
+	return nil
+}
+
+// textMarshalerOnly can be written to the environment, but cannot be
+// read back in
+type textMarshalerOnly struct{}
+
+func (t textMarshalerOnly) MarshalText() ([]byte, error) {
+	return []byte("textMarshalerOnly"), nil
+}
+
+// textUnmarshalerOnly can be read from the environment, but cannot be
+// written to it
+type textUnmarshalerOnly struct{}
+
+func (t *textUnmarshalerOnly) UnmarshalText([]byte) error {
+	return nil
+}
+
+// textMarshalerWithFields is written to the environment as a single
+// value, even though it has tagged fields of its own
+type textMarshalerWithFields struct {
+	Inner string `env:"INNER"`
+}
+
+func (t textMarshalerWithFields) MarshalText() ([]byte, error) {
+	return []byte(t.Inner), nil
+}
+
+// ================================================================
+//
+// Marshal
+//
+// ----------------------------------------------------------------
+
+func TestMarshalWritesTaggedFields(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	endpoint, _ := url.Parse("https://example.com/api")
+	limit := 10
+	cfg := bindTestConfig{
+		Name:     "envish",
+		Debug:    true,
+		Ratio:    0.75,
+		MaxConns: 100,
+		Timeout:  90 * time.Second,
+		Endpoint: *endpoint,
+		Tags:     []string{"a", "b"},
+		Ports:    []int{80, 443},
+		Backoff:  []time.Duration{time.Second, 2 * time.Second},
+		Bind:     net.ParseIP("127.0.0.1"),
+		Limit:    &limit,
+		DB:       bindTestDBConfig{Host: "db.example.com", Port: 5432},
+		Ignored:  "ignored",
+	}
+	env := envish.NewLocalEnv()
+
+	expectedResult := []string{
+		"APP_NAME=envish",
+		"APP_DEBUG=true",
+		"APP_RATIO=0.75",
+		"APP_MAX_CONNS=100",
+		"APP_TIMEOUT=1m30s",
+		"APP_ENDPOINT=https://example.com/api",
+		"APP_TAGS=a,b",
+		"APP_PORTS=80:443",
+		"APP_BACKOFF=1s,2s",
+		"APP_BIND=127.0.0.1",
+		"APP_LIMIT=10",
+		"DB_HOST=db.example.com",
+		"DB_PORT=5432",
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Marshal(&cfg, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, env.Environ())
+}
+
+func TestMarshalOutputRoundTripsThroughBind(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	proxy, _ := url.Parse("http://proxy:3128")
+	expectedResult := bindTestConfig{
+		Name:    "envish",
+		Ratio:   1e-9,
+		Timeout: time.Millisecond,
+		Proxy:   proxy,
+		Tags:    []string{"a"},
+		Ports:   []int{},
+		Bind:    net.ParseIP("::1"),
+		DB:      bindTestDBConfig{Host: "localhost", Port: 5432},
+		Replica: &bindTestDBConfig{Host: "replica", Port: 5433},
+	}
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Marshal(expectedResult, env)
+	assert.Nil(t, err)
+
+	var actualResult bindTestConfig
+	err = envish.Bind(env, &actualResult)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult.Ratio, actualResult.Ratio)
+	assert.Equal(t, expectedResult.Timeout, actualResult.Timeout)
+	assert.Equal(t, expectedResult.Proxy, actualResult.Proxy)
+	assert.Equal(t, expectedResult.Tags, actualResult.Tags)
+	assert.Equal(t, expectedResult.Bind, actualResult.Bind)
+	assert.Equal(t, expectedResult.DB, actualResult.DB)
+	assert.Equal(t, expectedResult.Replica, actualResult.Replica)
+}
+
+func TestMarshalSkipsNilPointers(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	cfg := bindTestConfig{}
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Marshal(cfg, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Empty(t, env.MatchVarNames("APP_PROXY"))
+	assert.Empty(t, env.MatchVarNames("APP_LIMIT"))
+	assert.Empty(t, env.MatchVarNames("REPLICA_"))
+}
+
+func TestMarshalDoesNotLookInsideUntaggedTextMarshalers(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	cfg := struct {
+		Untagged textMarshalerWithFields
+		Tagged   textMarshalerWithFields `env:"TAGGED"`
+	}{
+		Untagged: textMarshalerWithFields{Inner: "foo"},
+		Tagged:   textMarshalerWithFields{Inner: "bar"},
+	}
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Marshal(cfg, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"TAGGED=bar"}, env.AllVars())
+}
+
+func TestMarshalStopsAtSelfReferentialStructs(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	node := &bindTestNode{Name: "head"}
+	node.Next = node
+	node.Link = &bindTestLink{Weight: 5, To: node}
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Marshal(node, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"NAME=head", "LINK_WEIGHT=5"}, env.AllVars())
+}
+
+func TestMarshalReturnsEveryProblemAtOnce(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	cfg := struct {
+		Param1 map[string]string   `env:"PARAM1"`
+		Param2 brokenTextMarshaler `env:"PARAM2"`
+		Param3 string              `env:"PARAM3"`
+	}{
+		Param3: "foo",
+	}
+	env := envish.NewLocalEnv()
+
+	expectedError := envish.ErrMarshal{
+		Errors: []error{
+			envish.ErrUnsupportedType{Key: "PARAM1", Type: "map[string]string"},
+			envish.ErrInvalidValue{Key: "PARAM2", Type: "envish_test.brokenTextMarshaler"},
+		},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Marshal(cfg, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, "foo", env.Getenv("PARAM3"))
+}
+
+func TestMarshalReturnsErrorForTypesThatCannotBeWritten(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	cfg := struct {
+		Param1 textUnmarshalerOnly `env:"PARAM1"`
+		Param2 textMarshalerOnly   `env:"PARAM2"`
+	}{}
+	env := envish.NewLocalEnv()
+
+	expectedError := envish.ErrMarshal{
+		Errors: []error{
+			envish.ErrUnsupportedType{Key: "PARAM1", Type: "envish_test.textUnmarshalerOnly"},
+		},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Marshal(cfg, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, "textMarshalerOnly", env.Getenv("PARAM2"))
+}
+
+func TestMarshalReturnsErrorIfNotGivenAStruct(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	var nilCfg *bindTestConfig
+	s := "foo"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := envish.Marshal(nilCfg, env)
+	err2 := envish.Marshal(&s, env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.IsType(t, envish.ErrInvalidMarshalSource{}, err1)
+	assert.IsType(t, envish.ErrInvalidMarshalSource{}, err2)
+}
+
+func TestMarshalCopesWithNilWriter(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	expectedError := envish.ErrNilPointer{"Marshal"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.Marshal(bindTestConfig{}, nil)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
@@ -40,7 +40,20 @@ import "os"
 // CopyProgramEnv copies your program's environment into the given
 // environment store.
 //
-// It replaces any existing variables in the environment store.
+// It replaces any existing variables in the environment store. The
+// copied variables are all exported.
 func CopyProgramEnv(e *LocalEnv) {
 	e.pairs = os.Environ()
+	e.makePairIndex()
+
+	// they were exported to us, so they remain exported
+	e.exports = make(map[string]bool, len(e.pairs))
+	for _, pair := range e.pairs {
+		e.exports[GetKeyFromPair(pair)] = true
+	}
+
+	// our program's environment may have keys that only differ by case
+	if e.caseInsensitive {
+		e.foldKeys()
+	}
 }
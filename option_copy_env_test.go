@@ -66,3 +66,28 @@ func TestCopyProgramEnvCopiesTheProgramsEnvironmentIntoTheStore(t *testing.T) {
 
 	assert.Equal(t, expectedResult, actualResult)
 }
+
+func TestCopyProgramEnvWorksWithCaseInsensitiveKeys(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testKey := "TestNewEnvCase"
+	expectedResult := "this is my value"
+
+	os.Setenv(testKey, expectedResult)
+
+	// clean up after ourselves
+	defer os.Unsetenv(testKey)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env := envish.NewLocalEnv(envish.CaseInsensitiveKeys, envish.CopyProgramEnv)
+	actualResult := env.Getenv("TESTNEWENVCASE")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+	assert.True(t, env.IsExported("testnewenvcase"))
+}
@@ -37,6 +37,7 @@ package envish
 
 import (
 	"sort"
+	"strings"
 )
 
 // OverlayEnv works on a collection of variable backing stores.
@@ -48,6 +49,16 @@ import (
 // emulate local variable support.
 type OverlayEnv struct {
 	envs []Expander
+
+	// meta holds what we know about each environment in envs
+	meta []layerMeta
+
+	// unsetMode decides what Unsetenv does
+	unsetMode UnsetMode
+
+	// hooks are called whenever a variable is changed through this
+	// OverlayEnv
+	hooks changeHooks
 }
 
 // ================================================================
@@ -69,6 +80,35 @@ type OverlayEnv struct {
 func NewOverlayEnv(envs []Expander) *OverlayEnv {
 	retval := OverlayEnv{
 		envs: envs,
+		meta: make([]layerMeta, len(envs)),
+	}
+
+	// all done
+	return &retval
+}
+
+// Layer is an environment with a name, for use with NewNamedOverlayEnv.
+type Layer struct {
+	// Name tells you where the environment's variables come from,
+	// e.g. "defaults" or "program". It does not have to be unique.
+	Name string
+
+	// Env is the environment itself.
+	Env Expander
+}
+
+// NewNamedOverlayEnv works just like NewOverlayEnv, except that each
+// environment has a name. LookupEnvWithSource and Explain include the
+// name in their results, to tell you where a variable's value came from.
+func NewNamedOverlayEnv(layers []Layer) *OverlayEnv {
+	retval := OverlayEnv{
+		envs: make([]Expander, len(layers)),
+		meta: make([]layerMeta, len(layers)),
+	}
+
+	for i, layer := range layers {
+		retval.envs[i] = layer.Env
+		retval.meta[i].name = layer.Name
 	}
 
 	// all done
@@ -90,11 +130,16 @@ func NewOverlayEnv(envs []Expander) *OverlayEnv {
 // * it searches the environments in the order you provided them to
 // NewOverlayEnv
 //
-// * it only includes variables from environments where the IsExporter
-// method returns `true`
+// * it includes exported variables from environments that implement the
+// ExportChecker interface (such as LocalEnv)
+//
+// * it includes all variables from any other environment where the
+// IsExporter method returns `true`
 //
 // * if the same variable is set in multiple environments, it uses the first
-// value it finds
+// value it finds; the variable is left out if that value is not exported
+//
+// * it leaves out any variable that Unsetenv has hidden with a whiteout
 func (e *OverlayEnv) Environ() []string {
 	// our return value
 	retval := []string{}
@@ -106,18 +151,24 @@ func (e *OverlayEnv) Environ() []string {
 
 	// we need somewhere to keep track of the variables we are exporting
 	foundPairs := make(map[string]string)
+	foldKey := e.keyFolder()
 
-	for _, env := range e.envs {
-		if !env.IsExporter() {
+	for i, env := range e.envs {
+		_, isChecker := env.(ExportChecker)
+		if !isChecker && !env.IsExporter() {
 			continue
 		}
 
 		pairs := env.Environ()
 		for _, pair := range pairs {
 			key := GetKeyFromPair(pair)
-			_, ok := foundPairs[key]
-			if !ok {
-				foundPairs[key] = pair
+			if !isExportedBy(env, key) {
+				continue
+			}
+
+			_, ok := foundPairs[foldKey(key)]
+			if !ok && !e.isShadowed(key, i) {
+				foundPairs[foldKey(key)] = pair
 			}
 		}
 	}
@@ -143,21 +194,8 @@ func (e *OverlayEnv) Environ() []string {
 // * if the same variable is set in multiple environments, it uses the first
 // value it finds
 func (e *OverlayEnv) Getenv(key string) string {
-	// do we have a variable backing store to work with?
-	if e == nil {
-		return ""
-	}
-
-	// search for this variable
-	for _, env := range e.envs {
-		value, ok := env.LookupEnv(key)
-		if ok {
-			return value
-		}
-	}
-
-	// if we get here, then it doesn't exist
-	return ""
+	value, _ := e.LookupEnv(key)
+	return value
 }
 
 // IsExporter returns `true` if (and only if) any of the environments in
@@ -180,28 +218,40 @@ func (e *OverlayEnv) IsExporter() bool {
 	return false
 }
 
-// LookupEnv returns the value of the given variable. If the variable does
-// not exist, it returns `"", false`.
+// IsCaseInsensitive returns true if any of the environments in the
+// OverlayEnv treat keys that only differ by case as the same variable.
 //
-// * it searches the environments in the order you provided them to NewOverlayEnv
-//
-// * if the same variable is set in multiple environments, it uses the first
-// value it finds
-func (e *OverlayEnv) LookupEnv(key string) (string, bool) {
+// When it returns true, Environ, AllVars and MatchVarNames ignore case
+// when they merge the variables from each environment.
+func (e *OverlayEnv) IsCaseInsensitive() bool {
 	// do we have a stack?
 	if e == nil {
-		return "", false
+		return false
 	}
 
 	for _, env := range e.envs {
-		value, ok := env.LookupEnv(key)
-		if ok {
-			return value, true
+		checker, ok := env.(caseInsensitiveChecker)
+		if ok && checker.IsCaseInsensitive() {
+			return true
 		}
 	}
 
 	// no joy
-	return "", false
+	return false
+}
+
+// LookupEnv returns the value of the given variable. If the variable does
+// not exist, it returns `"", false`.
+//
+// * it searches the environments in the order you provided them to NewOverlayEnv
+//
+// * if the same variable is set in multiple environments, it uses the first
+// value it finds
+//
+// * a variable that Unsetenv has hidden with a whiteout is not set
+func (e *OverlayEnv) LookupEnv(key string) (string, bool) {
+	value, _, ok := e.LookupEnvWithSource(key)
+	return value, ok
 }
 
 // MatchVarNames returns a list of variable names that start with the
@@ -213,6 +263,8 @@ func (e *OverlayEnv) LookupEnv(key string) (string, bool) {
 //
 // * if the same key is found in multiple environments, it only returns
 // the key once (ie, results are deduped before they are returned)
+//
+// * it leaves out any key that Unsetenv has hidden with a whiteout
 func (e *OverlayEnv) MatchVarNames(prefix string) []string {
 	// our return value
 	retval := []string{}
@@ -223,15 +275,19 @@ func (e *OverlayEnv) MatchVarNames(prefix string) []string {
 	}
 
 	// let's go and find things
-	foundKeys := make(map[string]bool)
-	for _, env := range e.envs {
+	foundKeys := make(map[string]string)
+	foldKey := e.keyFolder()
+	for i, env := range e.envs {
 		keys := env.MatchVarNames(prefix)
 		for _, key := range keys {
-			foundKeys[key] = true
+			_, ok := foundKeys[foldKey(key)]
+			if !ok && !e.isWhitedOut(key, i) {
+				foundKeys[foldKey(key)] = key
+			}
 		}
 	}
 
-	for key := range foundKeys {
+	for _, key := range foundKeys {
 		retval = append(retval, key)
 	}
 
@@ -252,6 +308,9 @@ func (e *OverlayEnv) MatchVarNames(prefix string) []string {
 // If your overlay env includes a ProgramEnv, this *WILL* delete all of
 // your program's environment variables.
 //
+// Each environment decides for itself whether to keep any read-only
+// variables.
+//
 // Use with extreme caution!
 func (e *OverlayEnv) Clearenv() {
 	// do we have a stack to work with?
@@ -263,6 +322,10 @@ func (e *OverlayEnv) Clearenv() {
 	for i := range e.envs {
 		e.envs[i].Clearenv()
 	}
+	e.clearWhiteouts()
+
+	// tell anyone who is interested
+	e.hooks.notify(ChangeEvent{Op: ChangeClear})
 
 	// all done
 }
@@ -277,6 +340,9 @@ func (e *OverlayEnv) Clearenv() {
 //
 // * if the variable does not exist, it is always created in the first
 // environment you provided to NewOverlayEnv
+//
+// * if the variable is read-only in any environment, it returns
+// ErrReadOnlyVar and changes nothing
 func (e *OverlayEnv) Setenv(key, value string) error {
 	// do we have a stack?
 	if e == nil {
@@ -288,29 +354,81 @@ func (e *OverlayEnv) Setenv(key, value string) error {
 		return ErrEmptyOverlayEnv{"OverlayEnv.Setenv"}
 	}
 
+	// are we allowed to change it?
+	if e.IsReadOnly(key) {
+		return ErrReadOnlyVar{Key: key}
+	}
+
 	// are we updating an existing variable?
-	for _, env := range e.envs {
-		_, ok := env.LookupEnv(key)
-		if ok {
-			return env.Setenv(key, value)
-		}
+	//
+	// if not, it's a brand new variable, and goes in the first environment
+	//
+	// an existing variable keeps its original name, even if the key's
+	// case is different
+	ev := ChangeEvent{Op: ChangeSet, Key: key, NewValue: value}
+	targetIndex, layerKey, ok := e.findEnv(key)
+	if ok {
+		ev.OldValue, ev.WasSet = e.envs[targetIndex].LookupEnv(layerKey)
+	} else {
+		targetIndex = 0
+	}
+
+	err := e.envs[targetIndex].Setenv(layerKey, value)
+	if err != nil {
+		return err
 	}
 
-	// nope, it's a brand new variable
-	return e.envs[0].Setenv(key, value)
+	// the variable is no longer hidden
+	e.removeWhiteouts(key, targetIndex)
+
+	// tell anyone who is interested
+	e.hooks.notify(ev)
+
+	// all done
+	return nil
 }
 
 // Unsetenv deletes the variable named by the key.
 //
-// It will be deleted from all the environments in the stack.
+// By default, it will be deleted from all the environments in the stack.
+// Use SetUnsetMode with UnsetWithWhiteout to only delete it from the
+// first environment, and hide it in the others.
+//
+// If the variable is read-only in any environment, it is not deleted
+// from any of them.
 func (e *OverlayEnv) Unsetenv(key string) {
 	// do we have a stack?
 	if e == nil {
 		return
 	}
 
-	for _, env := range e.envs {
-		env.Unsetenv(key)
+	// are we allowed to delete it?
+	if e.IsReadOnly(key) {
+		return
+	}
+
+	// is there anything to delete?
+	oldValue, wasSet := e.LookupEnv(key)
+
+	switch e.unsetMode {
+	case UnsetWithWhiteout:
+		e.unsetWithWhiteout(key)
+	default:
+		ignoreCase := e.IsCaseInsensitive()
+		for _, env := range e.envs {
+			layerKey, _ := findKeyIn(env, key, ignoreCase)
+			env.Unsetenv(layerKey)
+		}
+	}
+
+	// tell anyone who is interested
+	if wasSet {
+		e.hooks.notify(ChangeEvent{
+			Op:       ChangeUnset,
+			Key:      key,
+			OldValue: oldValue,
+			WasSet:   true,
+		})
 	}
 }
 
@@ -341,12 +459,84 @@ func (e *OverlayEnv) Expand(fmt string) string {
 	return expand(e, fmt)
 }
 
+// ExpandE works just like Expand, except that it returns an error if
+// the format string cannot be expanded:
+//
+// * ErrBadSubstitution if the format string contains a malformed `${`
+//
+// * ErrUnsetVariable if `${var:?word}` refers to a variable that is not
+// set in any of the environments contained within the OverlayEnv
+func (e *OverlayEnv) ExpandE(fmt string) (string, error) {
+	return expandE(e, fmt)
+}
+
+// ExpandWith works like ExpandE. Use the options to choose what
+// counts as an error.
+//
+// With the NoUnset option, a variable is unbound only if it is not set
+// in any of the environments contained within the OverlayEnv.
+func (e *OverlayEnv) ExpandWith(fmt string, options ExpandOptions) (string, error) {
+	return expandWith(e, fmt, options)
+}
+
 // ================================================================
 //
 // Struct-unique functions
 //
 // ----------------------------------------------------------------
 
+// OnChange registers a function to call whenever Setenv, Unsetenv,
+// Clearenv or Export changes the OverlayEnv. That includes changes made
+// during string expansion, such as `${var:=word}`.
+//
+// Changes made directly to the environments inside the OverlayEnv are
+// not reported. Register with those environments too if you need them.
+func (e *OverlayEnv) OnChange(fn func(ev ChangeEvent)) {
+	// do we have a stack to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.add(fn)
+}
+
+// Begin starts a transaction. Changes made through the returned Tx are
+// not made to any of the environments in the OverlayEnv until you call
+// Tx.Commit.
+//
+// If Tx.Commit fails, it puts every environment in the OverlayEnv back
+// the way it was.
+func (e *OverlayEnv) Begin() *Tx {
+	// do we have a stack to work with?
+	if e == nil {
+		return newTx(nil)
+	}
+
+	// yes we do
+	return newTx(e)
+}
+
+// LookupEnvWithSource works just like LookupEnv, and also tells you
+// which environment the value came from.
+//
+// If the variable does not exist, it returns `"", VarSource{Index: -1},
+// false`.
+func (e *OverlayEnv) LookupEnvWithSource(key string) (string, VarSource, bool) {
+	// do we have a stack?
+	if e == nil {
+		return "", VarSource{Index: -1}, false
+	}
+
+	i, layerKey, ok := e.findEnv(key)
+	if !ok {
+		return "", VarSource{Index: -1}, false
+	}
+
+	value, _ := e.envs[i].LookupEnv(layerKey)
+	return value, e.sourceOf(i), true
+}
+
 // GetEnvByID returns the requested environment from the given OverlayEnv.
 // ID `0` is the first environment you passed into NewOverlayEnv, ID `1`
 // is the second environment, and so on.
@@ -404,8 +594,14 @@ func (e *OverlayEnv) GetTopMostEnv() (Expander, error) {
 // should ensure consistent results whenever you call Getenv on the given
 // OverlayEnv.
 //
+// * It marks the variable as exported in every environment that it changes,
+// if that environment supports exporting individual variables.
+//
 // * It stops once it has set the environment variable inside an environment
 // that is an exporter.
+//
+// * It returns ErrReadOnlyVar, and changes nothing, if the variable is
+// read-only in any environment.
 func (e *OverlayEnv) Export(key, value string) error {
 	// do we have an OverlayEnv to work with?
 	if e == nil {
@@ -417,6 +613,11 @@ func (e *OverlayEnv) Export(key, value string) error {
 		return ErrEmptyOverlayEnv{}
 	}
 
+	// are we allowed to change it?
+	if e.IsReadOnly(key) {
+		return ErrReadOnlyVar{Key: key}
+	}
+
 	// do we have any exporters in the stack?
 	hasExporter := false
 	for _, env := range e.envs {
@@ -428,26 +629,361 @@ func (e *OverlayEnv) Export(key, value string) error {
 		return ErrNoExporterEnv{"OverlayEnv.Export"}
 	}
 
+	// remember what we are replacing
+	ev := ChangeEvent{Op: ChangeSet, Key: key}
+	ev.OldValue, ev.WasSet = e.LookupEnv(key)
+
 	// work through the stack
-	for _, env := range e.envs {
+	ignoreCase := e.IsCaseInsensitive()
+	for i, env := range e.envs {
 		// shorthand
 		isExporter := env.IsExporter()
-		_, hasKey := env.LookupEnv(key)
+		layerKey, hasKey := findKeyIn(env, key, ignoreCase)
 
 		if isExporter || hasKey {
-			err := env.Setenv(key, value)
+			err := env.Setenv(layerKey, value)
 			if err != nil {
 				// we have to bail
 				return err
 			}
+
+			// make sure the new value is exported from here too
+			exporter, ok := env.(varExporter)
+			if ok {
+				err = exporter.Export(layerKey)
+				if err != nil {
+					return err
+				}
+			}
 		}
 
+		// the variable is no longer hidden from here
+		e.removeWhiteouts(key, i)
+
 		// are we done?
 		if isExporter {
 			break
 		}
 	}
 
+	// tell anyone who is interested
+	ev.NewValue = value
+	e.hooks.notify(ev)
+
 	// all done
 	return nil
 }
+
+// AllVars returns a copy of all of the variables in your `OverlayEnv`
+// in the form `key=value`, whether they have been exported or not.
+//
+// If the same variable is set in multiple environments, it uses the first
+// value it finds.
+func (e *OverlayEnv) AllVars() []string {
+	// our return value
+	retval := []string{}
+
+	// do we have a stack to work with?
+	if e == nil {
+		return retval
+	}
+
+	// we need somewhere to keep track of the variables we have seen
+	foundPairs := make(map[string]string)
+	foldKey := e.keyFolder()
+
+	for i, env := range e.envs {
+		for _, pair := range allVarsOf(env) {
+			key := GetKeyFromPair(pair)
+			_, ok := foundPairs[foldKey(key)]
+			if !ok && !e.isWhitedOut(key, i) {
+				foundPairs[foldKey(key)] = pair
+			}
+		}
+	}
+
+	// at this point, foundPairs needs to be flattened
+	for _, pair := range foundPairs {
+		retval = append(retval, pair)
+	}
+
+	// sort the results, to match Environ
+	sort.Strings(retval)
+
+	// all done
+	return retval
+}
+
+// IsExported returns true if the variable named by the key will be
+// included in the output of Environ.
+//
+// The answer comes from the first environment that has the variable
+// set. If the variable isn't set anywhere, the answer comes from the
+// first environment, because that is where Setenv will create it.
+func (e *OverlayEnv) IsExported(key string) bool {
+	// do we have a stack to work with?
+	if e == nil || len(e.envs) == 0 {
+		return false
+	}
+
+	// who has this variable?
+	i, layerKey, ok := e.findEnv(key)
+	if ok {
+		return isExportedBy(e.envs[i], layerKey)
+	}
+
+	// nobody
+	return isExportedBy(e.envs[0], key)
+}
+
+// IsReadOnly returns true if the variable named by the key is read-only
+// in any of the environments in the OverlayEnv.
+//
+// Only environments that implement the ReadOnlyChecker interface can
+// have read-only variables.
+func (e *OverlayEnv) IsReadOnly(key string) bool {
+	// do we have a stack to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	ignoreCase := e.IsCaseInsensitive()
+	for _, env := range e.envs {
+		checker, ok := env.(ReadOnlyChecker)
+		if !ok {
+			continue
+		}
+
+		layerKey, _ := findKeyIn(env, key, ignoreCase)
+		if checker.IsReadOnly(layerKey) {
+			return true
+		}
+	}
+
+	// no-one is stopping us
+	return false
+}
+
+// caseInsensitiveChecker is the interface that wraps environments where keys
+// that only differ by case refer to the same variable
+type caseInsensitiveChecker interface {
+	IsCaseInsensitive() bool
+}
+
+// keyFolder returns the function that we use to decide whether two keys
+// refer to the same variable
+//
+// if any of our environments ignore case, we ignore case too
+func (e *OverlayEnv) keyFolder() func(string) string {
+	return keyFolderOf(e)
+}
+
+// keyFolderOf returns the function that we use to decide whether two
+// keys refer to the same variable in the given environment
+func keyFolderOf(r Reader) func(string) string {
+	checker, ok := r.(caseInsensitiveChecker)
+	if ok && checker.IsCaseInsensitive() {
+		return strings.ToUpper
+	}
+
+	return func(key string) string {
+		return key
+	}
+}
+
+// checkKey returns an error if the environment that Setenv would write
+// to cannot use the key
+func (e *OverlayEnv) checkKey(key string) error {
+	// do we have any environments to check?
+	if e == nil || len(e.envs) == 0 {
+		return checkKeyOf(nil, key)
+	}
+
+	// yes we do
+	targetIndex, _, ok := e.findEnv(key)
+	if !ok {
+		targetIndex = 0
+	}
+
+	return checkKeyOf(e.envs[targetIndex], key)
+}
+
+// unsetWithWhiteout deletes the variable named by the key from our
+// first environment, and hides it in all the others
+func (e *OverlayEnv) unsetWithWhiteout(key string) {
+	// do we have any environments?
+	if len(e.envs) == 0 {
+		return
+	}
+
+	ignoreCase := e.IsCaseInsensitive()
+	layerKey, _ := findKeyIn(e.envs[0], key, ignoreCase)
+	e.envs[0].Unsetenv(layerKey)
+
+	// do we need to hide it?
+	for _, env := range e.envs[1:] {
+		_, ok := findKeyIn(env, key, ignoreCase)
+		if ok {
+			e.addWhiteout(0, key)
+			return
+		}
+	}
+}
+
+// sourceOf describes the environment at the given index
+func (e *OverlayEnv) sourceOf(index int) VarSource {
+	retval := VarSource{Index: index}
+	if index < len(e.meta) {
+		retval.Name = e.meta[index].name
+	}
+
+	return retval
+}
+
+// isShadowed returns true if the variable named by the key is set (or
+// hidden by a whiteout) in any of the environments above the given index
+func (e *OverlayEnv) isShadowed(key string, index int) bool {
+	ignoreCase := e.IsCaseInsensitive()
+	for _, env := range e.envs[:index] {
+		_, ok := findKeyIn(env, key, ignoreCase)
+		if ok {
+			return true
+		}
+	}
+
+	return e.isWhitedOut(key, index)
+}
+
+// upperKeyFinder is the interface that wraps environments that can find
+// a variable by its upper-case key, without looking at every variable
+type upperKeyFinder interface {
+	findUpperKey(key string) (string, bool)
+}
+
+// findKeyIn returns the name that the given environment uses for the
+// variable named by the key, and whether that environment has it set
+//
+// if the OverlayEnv ignores case, but the given environment does not,
+// the variable may be stored with different case (eg `PATH` instead of
+// `Path`), so we have to go looking for it
+//
+// environments that keep an upper-case lookup table (eg LocalEnv) can
+// tell us straight away; anything else has to be searched
+func findKeyIn(env Expander, key string, ignoreCase bool) (string, bool) {
+	// the easy case
+	_, ok := env.LookupEnv(key)
+	if ok {
+		return key, true
+	}
+
+	// do we need to look any harder?
+	if !ignoreCase {
+		return key, false
+	}
+	checker, ok := env.(caseInsensitiveChecker)
+	if ok && checker.IsCaseInsensitive() {
+		return key, false
+	}
+
+	// yes we do
+	finder, ok := env.(upperKeyFinder)
+	if ok {
+		return finder.findUpperKey(key)
+	}
+
+	foldedKey := strings.ToUpper(key)
+	for _, name := range env.MatchVarNames("") {
+		if strings.ToUpper(name) == foldedKey {
+			return name, true
+		}
+	}
+
+	// no joy
+	return key, false
+}
+
+// isExportedBy returns true if the given environment exports the
+// variable named by the key
+func isExportedBy(env Reader, key string) bool {
+	checker, ok := env.(ExportChecker)
+	if ok {
+		return checker.IsExported(key)
+	}
+
+	return env.IsExporter()
+}
+
+// lookupArray returns the array named by the key, from the first
+// environment that has the key
+func (e *OverlayEnv) lookupArray(key string) (arrayVar, bool) {
+	// do we have a stack?
+	if e == nil {
+		return arrayVar{}, false
+	}
+
+	ignoreCase := e.IsCaseInsensitive()
+	foldKey := e.keyFolder()
+	for i, env := range e.envs {
+		layerKey, isSet := findKeyIn(env, key, ignoreCase)
+		reader, ok := env.(arrayReader)
+		if ok {
+			arr, ok := reader.lookupArray(layerKey)
+			if ok {
+				return arr, true
+			}
+		}
+
+		// an ordinary variable (or a whiteout) hides any arrays
+		// further down
+		if isSet || e.hasWhiteout(i, foldKey(key)) {
+			return arrayVar{}, false
+		}
+	}
+
+	// no joy
+	return arrayVar{}, false
+}
+
+// holdHooks queues up any change events, from the OverlayEnv and from
+// each of its environments, instead of passing them on
+//
+// they all share the same queue, so that the events are passed on in
+// the order that they happened
+func (e *OverlayEnv) holdHooks(q *heldEvents) {
+	// do we have a stack?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	q = e.hooks.hold(q)
+	for _, env := range e.envs {
+		holder, ok := env.(hookHolder)
+		if ok {
+			holder.holdHooks(q)
+		}
+	}
+}
+
+// releaseHooks passes on (if deliver is true) or throws away (if deliver
+// is false) any change events that have been queued up by the OverlayEnv
+// and by each of its environments
+//
+// the events are passed on in the order that they happened, once the
+// last of them has been released
+func (e *OverlayEnv) releaseHooks(deliver bool) {
+	// do we have a stack?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.release(deliver)
+	for _, env := range e.envs {
+		holder, ok := env.(hookHolder)
+		if ok {
+			holder.releaseHooks(deliver)
+		}
+	}
+}
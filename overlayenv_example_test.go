@@ -356,3 +356,74 @@ func ExampleOverlayEnv_Export() {
 	// into NewOverlayEnv above
 	env.Export("DEBIAN_FRONTEND", "noninteractive")
 }
+
+func ExampleOverlayEnv_Explain() {
+	// create our independent environments
+	overrides := envish.NewLocalEnv()
+	overrides.Setenv("LOG_LEVEL", "debug")
+	defaults := envish.NewLocalEnv()
+	defaults.Setenv("LOG_LEVEL", "info")
+	defaults.Setenv("LISTEN_ADDR", ":8080")
+
+	// combine them, and give them names
+	env := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "overrides", Env: overrides},
+			{Name: "defaults", Env: defaults},
+		},
+	)
+
+	// where did our config come from?
+	fmt.Print(env.Explain())
+	// Output:
+	// LISTEN_ADDR=":8080" from layer 1 (defaults)
+	// LOG_LEVEL="debug" from layer 0 (overrides)
+	//   shadows "info" from layer 1 (defaults)
+}
+
+func ExampleOverlayEnv_PushEnv() {
+	// create our long-lived environment
+	baseEnv := envish.NewLocalEnv()
+	baseEnv.Setenv("STEP", "none")
+
+	env := envish.NewOverlayEnv([]envish.Expander{baseEnv})
+
+	// add a temporary environment for this step
+	stepEnv := envish.NewLocalEnv()
+	stepEnv.Setenv("STEP", "build")
+	env.PushEnv(stepEnv)
+	fmt.Println(env.Getenv("STEP"))
+
+	// and throw it away again afterwards
+	env.PopEnv()
+	fmt.Println(env.Getenv("STEP"))
+	// Output:
+	// build
+	// none
+}
+
+func ExampleOverlayEnv_SetUnsetMode() {
+	// create our independent environments
+	localVars := envish.NewLocalEnv()
+	sharedVars := envish.NewLocalEnv()
+	sharedVars.Setenv("DEBUG", "1")
+
+	// combine them
+	env := envish.NewOverlayEnv(
+		[]envish.Expander{
+			localVars,
+			sharedVars,
+		},
+	)
+
+	// don't delete anything from the shared environment
+	env.SetUnsetMode(envish.UnsetWithWhiteout)
+	env.Unsetenv("DEBUG")
+
+	_, ok := env.LookupEnv("DEBUG")
+	fmt.Println(ok)
+	fmt.Println(sharedVars.Getenv("DEBUG"))
+	// Output:
+	// false
+	// 1
+}
@@ -0,0 +1,166 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VarSource tells you which environment inside an OverlayEnv a variable
+// came from.
+type VarSource struct {
+	// Index is the environment's position in the OverlayEnv. `0` is the
+	// first environment, `1` is the second, and so on.
+	//
+	// It is `-1` if the variable was not found.
+	Index int
+
+	// Name is the environment's name, if it has one. Use
+	// NewNamedOverlayEnv to give your environments names.
+	Name string
+}
+
+// String returns a description of the environment, suitable for
+// including in log messages.
+func (s VarSource) String() string {
+	if s.Index < 0 {
+		return "not set"
+	}
+
+	if len(s.Name) == 0 {
+		return fmt.Sprintf("layer %d", s.Index)
+	}
+
+	return fmt.Sprintf("layer %d (%s)", s.Index, s.Name)
+}
+
+// VarOrigin is a value that a variable has in a single environment.
+type VarOrigin struct {
+	Value  string
+	Source VarSource
+}
+
+// VarExplanation describes every value that a single variable has in
+// an OverlayEnv.
+type VarExplanation struct {
+	Key string
+
+	// Winner is the value that the OverlayEnv uses. It comes from the
+	// first environment that has the variable.
+	Winner VarOrigin
+
+	// Shadowed holds the values from the other environments that have
+	// the variable. They are hidden by the Winner, and are listed in
+	// the order that the OverlayEnv searches its environments.
+	Shadowed []VarOrigin
+}
+
+// OverlayExplanation lists every variable in an OverlayEnv, and tells
+// you where each value came from.
+//
+// Use OverlayEnv.Explain to create one. It is sorted by key.
+type OverlayExplanation []VarExplanation
+
+// String returns a human-readable report, one line per value. Each
+// shadowed value is indented underneath the value that hides it.
+func (x OverlayExplanation) String() string {
+	var sb strings.Builder
+
+	for _, v := range x {
+		fmt.Fprintf(&sb, "%s=%q from %s\n", v.Key, v.Winner.Value, v.Winner.Source)
+		for _, shadowed := range v.Shadowed {
+			fmt.Fprintf(&sb, "  shadows %q from %s\n", shadowed.Value, shadowed.Source)
+		}
+	}
+
+	return sb.String()
+}
+
+// Explain lists every variable in your OverlayEnv, whether it has been
+// exported or not. For each variable, it tells you which environment
+// supplies the value, and which values in later environments are
+// hidden by it.
+//
+// Values that Unsetenv has hidden with a whiteout are left out.
+//
+// It is handy for working out why a variable does not have the value
+// that you expected.
+func (e *OverlayEnv) Explain() OverlayExplanation {
+	// our return value
+	retval := OverlayExplanation{}
+
+	// do we have a stack to work with?
+	if e == nil {
+		return retval
+	}
+
+	// we need somewhere to keep track of the variables we have seen
+	found := make(map[string]int)
+	foldKey := e.keyFolder()
+
+	for i, env := range e.envs {
+		for _, pair := range allVarsOf(env) {
+			key := GetKeyFromPair(pair)
+			if e.isWhitedOut(key, i) {
+				continue
+			}
+
+			origin := VarOrigin{
+				Value:  GetValueFromPair(pair, key),
+				Source: e.sourceOf(i),
+			}
+
+			j, ok := found[foldKey(key)]
+			if ok {
+				retval[j].Shadowed = append(retval[j].Shadowed, origin)
+				continue
+			}
+
+			found[foldKey(key)] = len(retval)
+			retval = append(retval, VarExplanation{Key: key, Winner: origin})
+		}
+	}
+
+	// sort the results, to match AllVars
+	sort.Slice(retval, func(a, b int) bool {
+		return retval[a].Key < retval[b].Key
+	})
+
+	// all done
+	return retval
+}
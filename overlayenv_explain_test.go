@@ -0,0 +1,187 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayEnvExplainListsEveryValueOfEveryVariable(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	overrides := envish.NewLocalEnv()
+	overrides.Setenv("PARAM2", "override value 2")
+	config := envish.NewLocalEnv()
+	config.Setenv("PARAM2", "config value 2")
+	config.Setenv("PARAM3", "config value 3")
+	defaults := envish.NewLocalEnv()
+	defaults.Setenv("PARAM1", "default value 1")
+	defaults.Setenv("PARAM2", "default value 2")
+
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "overrides", Env: overrides},
+			{Name: "config", Env: config},
+			{Name: "defaults", Env: defaults},
+		},
+	)
+
+	expectedResult := envish.OverlayExplanation{
+		{
+			Key:    "PARAM1",
+			Winner: envish.VarOrigin{Value: "default value 1", Source: envish.VarSource{Index: 2, Name: "defaults"}},
+		},
+		{
+			Key:    "PARAM2",
+			Winner: envish.VarOrigin{Value: "override value 2", Source: envish.VarSource{Index: 0, Name: "overrides"}},
+			Shadowed: []envish.VarOrigin{
+				{Value: "config value 2", Source: envish.VarSource{Index: 1, Name: "config"}},
+				{Value: "default value 2", Source: envish.VarSource{Index: 2, Name: "defaults"}},
+			},
+		},
+		{
+			Key:    "PARAM3",
+			Winner: envish.VarOrigin{Value: "config value 3", Source: envish.VarSource{Index: 1, Name: "config"}},
+		},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := stack.Explain()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestOverlayEnvExplainIncludesVariablesThatAreNotExported(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	localEnv := envish.NewLocalEnv()
+	localEnv.Setenv("PARAM1", "foo")
+	stack := envish.NewOverlayEnv([]envish.Expander{localEnv})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := stack.Explain()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, 1, len(actualResult))
+	assert.Equal(t, "PARAM1", actualResult[0].Key)
+}
+
+func TestOverlayEnvExplainIgnoresCaseIfAnyEnvironmentDoes(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	localEnv := envish.NewLocalEnv(envish.CaseInsensitiveKeys)
+	localEnv.Setenv("Path", "local path")
+	otherEnv := envish.NewLocalEnv()
+	otherEnv.Setenv("PATH", "other path")
+	stack := envish.NewOverlayEnv([]envish.Expander{localEnv, otherEnv})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := stack.Explain()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, 1, len(actualResult))
+	assert.Equal(t, "Path", actualResult[0].Key)
+	assert.Equal(t, []envish.VarOrigin{{Value: "other path", Source: envish.VarSource{Index: 1}}}, actualResult[0].Shadowed)
+}
+
+func TestOverlayEnvExplainCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := stack.Explain()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Empty(t, actualResult)
+}
+
+func TestOverlayExplanationStringReturnsReport(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	overrides := envish.NewLocalEnv()
+	overrides.Setenv("PARAM2", "override value 2")
+	defaults := envish.NewLocalEnv()
+	defaults.Setenv("PARAM1", "default value 1")
+	defaults.Setenv("PARAM2", "default value 2")
+
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "overrides", Env: overrides},
+			{Env: defaults},
+		},
+	)
+
+	expectedResult := `PARAM1="default value 1" from layer 1
+PARAM2="override value 2" from layer 0 (overrides)
+  shadows "default value 2" from layer 1
+`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := stack.Explain().String()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
@@ -0,0 +1,243 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+// ================================================================
+//
+// Layer management
+//
+// ----------------------------------------------------------------
+
+// GetEnvByName returns the first environment in the OverlayEnv with the
+// given name. Use NewNamedOverlayEnv, PushLayer or InsertLayer to give
+// your environments names.
+//
+// If none of the environments have that name, it returns `nil, false`.
+func (e *OverlayEnv) GetEnvByName(name string) (Expander, bool) {
+	// do we have a stack to work with?
+	if e == nil {
+		return nil, false
+	}
+
+	// who has this name?
+	for i, meta := range e.meta {
+		if meta.name == name {
+			return e.envs[i], true
+		}
+	}
+
+	// nobody
+	return nil, false
+}
+
+// Len returns the number of environments in the OverlayEnv.
+func (e *OverlayEnv) Len() int {
+	// do we have a stack to work with?
+	if e == nil {
+		return 0
+	}
+
+	// yes we do
+	return len(e.envs)
+}
+
+// PushEnv adds the given environment to the top of the OverlayEnv. It
+// becomes ID `0`, and every other environment moves down one place.
+//
+// The OverlayEnv searches the new environment first, and Setenv creates
+// new variables in it.
+func (e *OverlayEnv) PushEnv(env Expander) {
+	e.PushLayer(Layer{Env: env})
+}
+
+// PushLayer works just like PushEnv, and also gives the environment a
+// name.
+func (e *OverlayEnv) PushLayer(layer Layer) {
+	// do we have a stack to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.insertLayer(0, layer)
+}
+
+// PopEnv removes the environment at the top of the OverlayEnv, and
+// returns it. Every other environment moves up one place.
+//
+// It returns ErrEmptyOverlayEnv if the OverlayEnv has no environments.
+func (e *OverlayEnv) PopEnv() (Expander, error) {
+	// do we have a stack to work with?
+	if e == nil {
+		return nil, ErrNilPointer{"OverlayEnv.PopEnv"}
+	}
+
+	// do we have anything to pop?
+	if len(e.envs) == 0 {
+		return nil, ErrEmptyOverlayEnv{"OverlayEnv.PopEnv"}
+	}
+
+	// yes we do
+	return e.removeEnv(0), nil
+}
+
+// InsertEnv adds the given environment to the OverlayEnv, so that it
+// becomes the environment with the given ID. The environment that had
+// that ID (and every environment after it) moves down one place.
+//
+// Use an index of Len() to add the environment to the bottom of the
+// OverlayEnv. Any other index must be the ID of an existing environment,
+// or InsertEnv returns ErrEnvIndexOutOfRange.
+func (e *OverlayEnv) InsertEnv(index int, env Expander) error {
+	return e.insertLayerAt("OverlayEnv.InsertEnv", index, Layer{Env: env})
+}
+
+// InsertLayer works just like InsertEnv, and also gives the environment
+// a name.
+func (e *OverlayEnv) InsertLayer(index int, layer Layer) error {
+	return e.insertLayerAt("OverlayEnv.InsertLayer", index, layer)
+}
+
+// RemoveEnv removes the environment with the given ID from the
+// OverlayEnv, and returns it. Every environment after it moves up one
+// place.
+//
+// It returns ErrEnvIndexOutOfRange if the OverlayEnv does not have an
+// environment with that ID.
+func (e *OverlayEnv) RemoveEnv(index int) (Expander, error) {
+	// do we have a stack to work with?
+	if e == nil {
+		return nil, ErrNilPointer{"OverlayEnv.RemoveEnv"}
+	}
+
+	// do we have the environment that has been requested?
+	if index < 0 || index >= len(e.envs) {
+		return nil, ErrEnvIndexOutOfRange{"OverlayEnv.RemoveEnv", index, len(e.envs)}
+	}
+
+	// yes we do
+	return e.removeEnv(index), nil
+}
+
+// ReplaceEnv puts the given environment in place of the environment
+// with the given ID, and returns the environment that it replaced. The
+// name of the replaced environment is kept, along with any whiteouts
+// that Unsetenv has recorded there.
+//
+// It returns ErrEnvIndexOutOfRange if the OverlayEnv does not have an
+// environment with that ID.
+func (e *OverlayEnv) ReplaceEnv(index int, env Expander) (Expander, error) {
+	// do we have a stack to work with?
+	if e == nil {
+		return nil, ErrNilPointer{"OverlayEnv.ReplaceEnv"}
+	}
+
+	// do we have the environment that has been requested?
+	if index < 0 || index >= len(e.envs) {
+		return nil, ErrEnvIndexOutOfRange{"OverlayEnv.ReplaceEnv", index, len(e.envs)}
+	}
+
+	// yes we do
+	//
+	// we don't change the slice in place, because the caller of
+	// NewOverlayEnv may still be using it
+	retval := e.envs[index]
+	envs := make([]Expander, len(e.envs))
+	copy(envs, e.envs)
+	envs[index] = env
+	e.envs = envs
+
+	// all done
+	return retval, nil
+}
+
+// ================================================================
+//
+// Internal helpers
+//
+// ----------------------------------------------------------------
+
+// insertLayerAt checks the index, and then adds the layer to our
+// stack
+func (e *OverlayEnv) insertLayerAt(method string, index int, layer Layer) error {
+	// do we have a stack to work with?
+	if e == nil {
+		return ErrNilPointer{method}
+	}
+
+	// is the index one that we can use?
+	if index < 0 || index > len(e.envs) {
+		return ErrEnvIndexOutOfRange{method, index, len(e.envs)}
+	}
+
+	// yes it is
+	e.insertLayer(index, layer)
+
+	// all done
+	return nil
+}
+
+// insertLayer adds the layer to our stack, at the given index
+//
+// we always build new slices, because the caller of NewOverlayEnv may
+// still be using the slice that they gave us
+func (e *OverlayEnv) insertLayer(index int, layer Layer) {
+	envs := make([]Expander, 0, len(e.envs)+1)
+	envs = append(envs, e.envs[:index]...)
+	envs = append(envs, layer.Env)
+	e.envs = append(envs, e.envs[index:]...)
+
+	meta := make([]layerMeta, 0, len(e.meta)+1)
+	meta = append(meta, e.meta[:index]...)
+	meta = append(meta, layerMeta{name: layer.Name})
+	e.meta = append(meta, e.meta[index:]...)
+}
+
+// removeEnv removes the environment at the given index from our stack,
+// and returns it
+func (e *OverlayEnv) removeEnv(index int) Expander {
+	retval := e.envs[index]
+
+	envs := make([]Expander, 0, len(e.envs)-1)
+	envs = append(envs, e.envs[:index]...)
+	e.envs = append(envs, e.envs[index+1:]...)
+
+	meta := make([]layerMeta, 0, len(e.meta)-1)
+	meta = append(meta, e.meta[:index]...)
+	e.meta = append(meta, e.meta[index+1:]...)
+
+	return retval
+}
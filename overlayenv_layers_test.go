@@ -0,0 +1,560 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// envsOf returns every environment in the given OverlayEnv, in order
+func envsOf(stack *envish.OverlayEnv) []envish.Expander {
+	retval := []envish.Expander{}
+	for i := 0; i < stack.Len(); i++ {
+		env, _ := stack.GetEnvByID(i)
+		retval = append(retval, env)
+	}
+
+	return retval
+}
+
+// ================================================================
+//
+// GetEnvByName
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvGetEnvByNameReturnsFirstMatch(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	env3 := envish.NewLocalEnv()
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "step", Env: env1},
+			{Name: "config", Env: env2},
+			{Name: "config", Env: env3},
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, ok := stack.GetEnvByName("config")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, ok)
+	assert.Same(t, env2, actualResult)
+}
+
+func TestOverlayEnvGetEnvByNameReturnsFalseIfNameNotFound(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack := envish.NewOverlayEnv([]envish.Expander{envish.NewLocalEnv()})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, ok := stack.GetEnvByName("config")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, ok)
+	assert.Nil(t, actualResult)
+}
+
+func TestOverlayEnvGetEnvByNameCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, ok := stack.GetEnvByName("config")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, ok)
+	assert.Nil(t, actualResult)
+}
+
+// ================================================================
+//
+// Len
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvLenReturnsNumberOfEnvironments(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack := envish.NewOverlayEnv([]envish.Expander{envish.NewLocalEnv(), envish.NewLocalEnv()})
+	var nilStack *envish.OverlayEnv
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, 2, stack.Len())
+	assert.Equal(t, 0, envish.NewOverlayEnv(nil).Len())
+	assert.Equal(t, 0, nilStack.Len())
+}
+
+// ================================================================
+//
+// PushEnv / PopEnv
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvPushEnvAddsToTheTopOfTheStack(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	baseEnv := envish.NewLocalEnv()
+	baseEnv.Setenv("PARAM1", "base value")
+	stepEnv := envish.NewLocalEnv()
+	stepEnv.Setenv("PARAM1", "step value")
+	stack := envish.NewOverlayEnv([]envish.Expander{baseEnv})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.PushEnv(stepEnv)
+	stack.Setenv("PARAM2", "new value")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []envish.Expander{stepEnv, baseEnv}, envsOf(stack))
+	assert.Equal(t, "step value", stack.Getenv("PARAM1"))
+	assert.Equal(t, "new value", stepEnv.Getenv("PARAM2"))
+}
+
+func TestOverlayEnvPushLayerNamesTheEnvironment(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stepEnv := envish.NewLocalEnv()
+	stepEnv.Setenv("PARAM1", "step value")
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "base", Env: envish.NewLocalEnv()},
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.PushLayer(envish.Layer{Name: "step", Env: stepEnv})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	actualEnv, ok := stack.GetEnvByName("step")
+	assert.True(t, ok)
+	assert.Same(t, stepEnv, actualEnv)
+
+	_, source, _ := stack.LookupEnvWithSource("PARAM1")
+	assert.Equal(t, envish.VarSource{Index: 0, Name: "step"}, source)
+}
+
+func TestOverlayEnvPushEnvDoesNotChangeTheOriginalSlice(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	envs := make([]envish.Expander, 1, 4)
+	envs[0] = env1
+	stack := envish.NewOverlayEnv(envs)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.PushEnv(env2)
+	stack.PopEnv()
+	stack.ReplaceEnv(0, env2)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Same(t, env1, envs[0])
+	assert.Equal(t, []envish.Expander{env2}, envsOf(stack))
+}
+
+func TestOverlayEnvPopEnvRemovesTheTopOfTheStack(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	baseEnv := envish.NewLocalEnv()
+	baseEnv.Setenv("PARAM1", "base value")
+	stepEnv := envish.NewLocalEnv()
+	stepEnv.Setenv("PARAM1", "step value")
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "step", Env: stepEnv},
+			{Name: "base", Env: baseEnv},
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.PopEnv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Same(t, stepEnv, actualResult)
+	assert.Equal(t, []envish.Expander{baseEnv}, envsOf(stack))
+	assert.Equal(t, "base value", stack.Getenv("PARAM1"))
+
+	_, source, _ := stack.LookupEnvWithSource("PARAM1")
+	assert.Equal(t, envish.VarSource{Index: 0, Name: "base"}, source)
+}
+
+func TestOverlayEnvPopEnvReturnsErrorIfStackIsEmpty(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack := envish.NewOverlayEnv([]envish.Expander{})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.PopEnv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, actualResult)
+	assert.Equal(t, envish.ErrEmptyOverlayEnv{"OverlayEnv.PopEnv"}, err)
+}
+
+func TestOverlayEnvPopEnvCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.PopEnv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, actualResult)
+	assert.Equal(t, envish.ErrNilPointer{"OverlayEnv.PopEnv"}, err)
+}
+
+// ================================================================
+//
+// InsertEnv
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvInsertEnvAddsToTheMiddleOfTheStack(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	env3 := envish.NewLocalEnv()
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env3})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.InsertEnv(1, env2)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, []envish.Expander{env1, env2, env3}, envsOf(stack))
+}
+
+func TestOverlayEnvInsertEnvCanAddToTheBottomOfTheStack(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	stack := envish.NewOverlayEnv([]envish.Expander{env1})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.InsertEnv(1, env2)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, []envish.Expander{env1, env2}, envsOf(stack))
+}
+
+func TestOverlayEnvInsertLayerNamesTheEnvironment(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	stack := envish.NewOverlayEnv([]envish.Expander{env1})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.InsertLayer(1, envish.Layer{Name: "defaults", Env: env2})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	actualEnv, ok := stack.GetEnvByName("defaults")
+	assert.True(t, ok)
+	assert.Same(t, env2, actualEnv)
+}
+
+func TestOverlayEnvInsertEnvReturnsErrorIfIndexOutOfRange(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	stack := envish.NewOverlayEnv([]envish.Expander{env1})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := stack.InsertEnv(2, envish.NewLocalEnv())
+	err2 := stack.InsertEnv(-1, envish.NewLocalEnv())
+	err3 := stack.InsertLayer(2, envish.Layer{Env: envish.NewLocalEnv()})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrEnvIndexOutOfRange{Method: "OverlayEnv.InsertEnv", Index: 2, Len: 1}, err1)
+	assert.Equal(t, envish.ErrEnvIndexOutOfRange{Method: "OverlayEnv.InsertEnv", Index: -1, Len: 1}, err2)
+	assert.Equal(t, envish.ErrEnvIndexOutOfRange{Method: "OverlayEnv.InsertLayer", Index: 2, Len: 1}, err3)
+	assert.Equal(t, []envish.Expander{env1}, envsOf(stack))
+}
+
+func TestOverlayEnvInsertEnvCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.InsertEnv(0, envish.NewLocalEnv())
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrNilPointer{"OverlayEnv.InsertEnv"}, err)
+}
+
+// ================================================================
+//
+// RemoveEnv
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvRemoveEnvRemovesFromTheMiddleOfTheStack(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	env3 := envish.NewLocalEnv()
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "env1", Env: env1},
+			{Name: "env2", Env: env2},
+			{Name: "env3", Env: env3},
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.RemoveEnv(1)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Same(t, env2, actualResult)
+	assert.Equal(t, []envish.Expander{env1, env3}, envsOf(stack))
+
+	_, ok := stack.GetEnvByName("env2")
+	assert.False(t, ok)
+	actualEnv, _ := stack.GetEnvByName("env3")
+	assert.Same(t, env3, actualEnv)
+}
+
+func TestOverlayEnvRemoveEnvReturnsErrorIfIndexOutOfRange(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	stack := envish.NewOverlayEnv([]envish.Expander{env1})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult1, err1 := stack.RemoveEnv(1)
+	actualResult2, err2 := stack.RemoveEnv(-1)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, actualResult1)
+	assert.Nil(t, actualResult2)
+	assert.Equal(t, envish.ErrEnvIndexOutOfRange{Method: "OverlayEnv.RemoveEnv", Index: 1, Len: 1}, err1)
+	assert.Equal(t, envish.ErrEnvIndexOutOfRange{Method: "OverlayEnv.RemoveEnv", Index: -1, Len: 1}, err2)
+	assert.Equal(t, []envish.Expander{env1}, envsOf(stack))
+}
+
+func TestOverlayEnvRemoveEnvCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.RemoveEnv(0)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, actualResult)
+	assert.Equal(t, envish.ErrNilPointer{"OverlayEnv.RemoveEnv"}, err)
+}
+
+// ================================================================
+//
+// ReplaceEnv
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvReplaceEnvKeepsTheName(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	newEnv := envish.NewLocalEnv()
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "step", Env: env1},
+			{Name: "base", Env: env2},
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.ReplaceEnv(0, newEnv)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Same(t, env1, actualResult)
+	assert.Equal(t, []envish.Expander{newEnv, env2}, envsOf(stack))
+
+	actualEnv, _ := stack.GetEnvByName("step")
+	assert.Same(t, newEnv, actualEnv)
+}
+
+func TestOverlayEnvReplaceEnvReturnsErrorIfIndexOutOfRange(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	stack := envish.NewOverlayEnv([]envish.Expander{env1})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.ReplaceEnv(1, envish.NewLocalEnv())
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, actualResult)
+	assert.Equal(t, envish.ErrEnvIndexOutOfRange{Method: "OverlayEnv.ReplaceEnv", Index: 1, Len: 1}, err)
+	assert.Equal(t, []envish.Expander{env1}, envsOf(stack))
+}
+
+func TestOverlayEnvReplaceEnvCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.ReplaceEnv(0, envish.NewLocalEnv())
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, actualResult)
+	assert.Equal(t, envish.ErrNilPointer{"OverlayEnv.ReplaceEnv"}, err)
+}
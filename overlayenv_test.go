@@ -70,6 +70,24 @@ func (e *brokenExporter) Setenv(key, value string) error {
 	return errBrokenExporter{"brokenExporter.Setenv"}
 }
 
+// mirroringEnv copies every change made to it into another environment,
+// using a transaction
+type mirroringEnv struct {
+	envish.LocalEnv
+	mirror *envish.LocalEnv
+}
+
+func (e *mirroringEnv) Setenv(key, value string) error {
+	err := e.LocalEnv.Setenv(key, value)
+	if err != nil {
+		return err
+	}
+
+	tx := e.mirror.Begin()
+	tx.Setenv("MIRRORED_"+key, value)
+	return tx.Commit()
+}
+
 // ================================================================
 //
 // Constructors
@@ -102,6 +120,32 @@ func TestNewOverlayEnvReturnsStackOfEnvironments(t *testing.T) {
 	assert.Same(t, progEnv, stack1)
 }
 
+func TestNewNamedOverlayEnvReturnsStackOfEnvironments(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	localEnv := envish.NewLocalEnv()
+	progEnv := envish.NewProgramEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "local", Env: localEnv},
+			{Name: "program", Env: progEnv},
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	stack0, _ := stack.GetEnvByID(0)
+	stack1, _ := stack.GetEnvByID(1)
+	assert.Same(t, localEnv, stack0)
+	assert.Same(t, progEnv, stack1)
+}
+
 // ================================================================
 //
 // Interface compatibility
@@ -1097,6 +1141,111 @@ func TestOverlayEnvLookupEnvReturnsEmptyStringIfEmptyStruct(t *testing.T) {
 	assert.Equal(t, expectedResult, actualResult)
 }
 
+func TestOverlayEnvLookupEnvWithSourceReturnsWhereTheValueCameFrom(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	defaults := envish.NewLocalEnv()
+	defaults.Setenv("PARAM1", "default value 1")
+	defaults.Setenv("PARAM2", "default value 2")
+	overrides := envish.NewLocalEnv()
+	overrides.Setenv("PARAM2", "override value 2")
+
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "overrides", Env: overrides},
+			{Name: "defaults", Env: defaults},
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	value1, source1, ok1 := stack.LookupEnvWithSource("PARAM1")
+	value2, source2, ok2 := stack.LookupEnvWithSource("PARAM2")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, ok1)
+	assert.Equal(t, "default value 1", value1)
+	assert.Equal(t, envish.VarSource{Index: 1, Name: "defaults"}, source1)
+
+	assert.True(t, ok2)
+	assert.Equal(t, "override value 2", value2)
+	assert.Equal(t, envish.VarSource{Index: 0, Name: "overrides"}, source2)
+}
+
+func TestOverlayEnvLookupEnvWithSourceWorksWithUnnamedEnvironments(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	localEnv := envish.NewLocalEnv()
+	localEnv.Setenv("PARAM1", "foo")
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			envish.NewLocalEnv(),
+			localEnv,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	value, source, ok := stack.LookupEnvWithSource("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, ok)
+	assert.Equal(t, "foo", value)
+	assert.Equal(t, envish.VarSource{Index: 1}, source)
+	assert.Equal(t, "layer 1", source.String())
+}
+
+func TestOverlayEnvLookupEnvWithSourceReturnsNoSourceIfVariableNotFound(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack := envish.NewNamedOverlayEnv(
+		[]envish.Layer{
+			{Name: "local", Env: envish.NewLocalEnv()},
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	value, source, ok := stack.LookupEnvWithSource("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, ok)
+	assert.Equal(t, "", value)
+	assert.Equal(t, envish.VarSource{Index: -1}, source)
+	assert.Equal(t, "not set", source.String())
+}
+
+func TestOverlayEnvLookupEnvWithSourceCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv = nil
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	value, source, ok := stack.LookupEnvWithSource("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, ok)
+	assert.Equal(t, "", value)
+	assert.Equal(t, envish.VarSource{Index: -1}, source)
+}
+
 func TestOverlayEnvMatchVarNamesSearchesTheStack(t *testing.T) {
 	// ----------------------------------------------------------------
 	// setup your test
@@ -1525,3 +1674,700 @@ func TestOverlayExpandReturnsOriginalStringIfExpansionFails(t *testing.T) {
 
 	assert.Equal(t, expectedResult, actualResult)
 }
+
+func TestOverlayExpandESearchesTheStack(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	// we don't use a program environment here because its contents are
+	// unpredictable
+	env1 := envish.NewLocalEnv(envish.SetAsExporter)
+	env1.Setenv("PARAM1_1", "hello")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM2_1", "trout")
+
+	expectedResult := "hello, trout"
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.ExpandE("${PARAM1_1}, ${PARAM2_1:?}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestOverlayExpandEReturnsErrUnsetVariableIfNoEnvironmentHasTheVariable(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.SetAsExporter)
+	env1.Setenv("PARAM1_1", "hello")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+
+	expectedError := envish.ErrUnsetVariable{Name: "PARAM2_1", Message: "not found"}
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := stack.ExpandE("${PARAM1_1}, ${PARAM2_1:?not found}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestOverlayExpandEReturnsErrBadSubstitutionForMalformedInput(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			envish.NewLocalEnv(),
+		},
+	)
+	expectedError := envish.ErrBadSubstitution{Pos: 6}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := stack.ExpandE("hello ${PARAM1_1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestOverlayExpandWithNoUnsetSearchesTheStack(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.SetAsExporter)
+	env1.Setenv("PARAM1_1", "hello")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM2_1", "trout")
+
+	expectedError := envish.ErrUnboundVariables{Names: []string{"PARAM3_1"}}
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := stack.ExpandWith(
+		"${PARAM1_1}, ${PARAM2_1} ${PARAM3_1}",
+		envish.ExpandOptions{NoUnset: true},
+	)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+// ================================================================
+//
+// Change notifications
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvOnChangeReportsEveryChange(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM1", "foo")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	var actualResult []envish.ChangeEvent
+	stack.OnChange(func(ev envish.ChangeEvent) {
+		actualResult = append(actualResult, ev)
+	})
+
+	expectedResult := []envish.ChangeEvent{
+		{Op: envish.ChangeSet, Key: "PARAM1", OldValue: "foo", WasSet: true, NewValue: "bar"},
+		{Op: envish.ChangeSet, Key: "PARAM2", NewValue: "default"},
+		{Op: envish.ChangeSet, Key: "PARAM3", NewValue: "exported"},
+		{Op: envish.ChangeUnset, Key: "PARAM1", OldValue: "bar", WasSet: true},
+		{Op: envish.ChangeClear},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Setenv("PARAM1", "bar")
+	stack.Expand("${PARAM2:=default}")
+	stack.Export("PARAM3", "exported")
+	stack.Unsetenv("PARAM1")
+	stack.Unsetenv("PARAM1")
+	stack.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
+func TestOverlayEnvOnChangeDoesNotReportFailedChanges(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			envish.NewLocalEnv(),
+			&brokenExporter{},
+		},
+	)
+
+	called := false
+	stack.OnChange(func(ev envish.ChangeEvent) {
+		called = true
+	})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Setenv("", "foo")
+	stack.Export("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, called)
+}
+
+// ================================================================
+//
+// Exported variables
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvironFollowsPerVariableExports(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "hello")
+	env1.Setenv("PARAM2", "world")
+	env1.Export("PARAM2")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM3", "trout")
+	env2.Setenv("PARAM4", "haddock")
+	env2.Unexport("PARAM4")
+
+	expectedEnviron := []string{"PARAM2=world", "PARAM3=trout"}
+	expectedAllVars := []string{
+		"PARAM1=hello",
+		"PARAM2=world",
+		"PARAM3=trout",
+		"PARAM4=haddock",
+	}
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualEnviron := stack.Environ()
+	actualAllVars := stack.AllVars()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedEnviron, actualEnviron)
+	assert.Equal(t, expectedAllVars, actualAllVars)
+}
+
+func TestOverlayEnvironLeavesOutExportedVariablesHiddenByUnexportedOnes(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "hello")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM1", "trout")
+	env2.Setenv("PARAM2", "haddock")
+
+	expectedResult := []string{"PARAM2=haddock"}
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := stack.Environ()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+	assert.False(t, stack.IsExported("PARAM1"))
+	assert.True(t, stack.IsExported("PARAM2"))
+}
+
+func TestOverlayEnvExportMarksTheVariableAsExported(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "hello")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+
+	expectedResult := []string{"PARAM1=world"}
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.Export("PARAM1", "world")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.True(t, env1.IsExported("PARAM1"))
+	assert.Equal(t, expectedResult, stack.Environ())
+}
+
+func TestOverlayEnvIsExportedAsksTheFirstEnvironmentForUnsetVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Export("PARAM1")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult1 := stack.IsExported("PARAM1")
+	actualResult2 := stack.IsExported("PARAM2")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, actualResult1)
+	assert.False(t, actualResult2)
+}
+
+func TestOverlayEnvIsExportedCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult1 := stack.IsExported("PARAM1")
+	actualResult2 := stack.AllVars()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, actualResult1)
+	assert.Equal(t, []string{}, actualResult2)
+}
+
+// ================================================================
+//
+// Read-only variables
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvSetenvHonoursReadOnlyVariablesInAnyLayer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.SetAsExporter)
+	env1.Setenv("PARAM1", "hello")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM1", "trout")
+	env2.SetReadOnly("PARAM1")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.Setenv("PARAM1", "world")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, "hello", env1.Getenv("PARAM1"))
+	assert.Equal(t, "trout", env2.Getenv("PARAM1"))
+	assert.True(t, stack.IsReadOnly("PARAM1"))
+}
+
+func TestOverlayEnvUnsetenvHonoursReadOnlyVariablesInAnyLayer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.SetAsExporter)
+	env1.Setenv("PARAM1", "hello")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM1", "trout")
+	env2.SetReadOnly("PARAM1")
+
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "hello", env1.Getenv("PARAM1"))
+	assert.Equal(t, "trout", env2.Getenv("PARAM1"))
+}
+
+func TestOverlayEnvExportHonoursReadOnlyVariablesInAnyLayer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.SetReadOnly("PARAM1")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.Export("PARAM1", "world")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, 0, env1.Length())
+}
+
+func TestOverlayEnvExpandERespectsReadOnlyVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	env2.SetReadOnly("PARAM1")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			env1,
+			env2,
+		},
+	)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := stack.ExpandE("${PARAM1:=foo}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, 0, env1.Length())
+}
+
+func TestOverlayEnvIsReadOnlyCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := stack.IsReadOnly("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, actualResult)
+}
+
+// ================================================================
+//
+// Arrays
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvExpandSupportsArrays(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "foo")
+
+	env2 := envish.NewLocalEnv()
+	env2.SetArray("ARR", "one", "two")
+	env2.SetArray("PARAM1", "bar", "baz")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := stack.ExpandE("${ARR[1]} ${#ARR[@]} ${PARAM1[@]}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+
+	// PARAM1 in env1 hides the array in env2
+	assert.Equal(t, "two 2 foo", actualResult)
+}
+
+func TestOverlayEnvExpandCannotAssignToArrayElements(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.SetArray("ARR", "one")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := stack.ExpandE("${ARR[1]:=two}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrBadSubstitution{Pos: 2}, err)
+}
+
+// ================================================================
+//
+// Case-insensitive keys
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvIgnoresCaseWhenAnyEnvIsCaseInsensitive(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.SetAsExporter, envish.CaseInsensitiveKeys)
+	env1.Setenv("Path", "C:\\Tools")
+
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PATH", "C:\\Windows")
+	env2.Setenv("Temp", "C:\\Temp")
+	env2.Setenv("TEMP", "D:\\Temp")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualEnviron := stack.Environ()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, stack.IsCaseInsensitive())
+	assert.Equal(t, []string{"Path=C:\\Tools", "Temp=C:\\Temp"}, actualEnviron)
+	assert.Equal(t, []string{"Path=C:\\Tools", "Temp=C:\\Temp"}, stack.AllVars())
+	assert.Equal(t, []string{"Path", "Temp"}, stack.MatchVarNames(""))
+	assert.Equal(t, "C:\\Tools", stack.Getenv("PATH"))
+}
+
+func TestOverlayEnvIgnoresCaseWhenLookingUpVariablesInCaseSensitiveEnvs(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.SetAsExporter, envish.CaseInsensitiveKeys)
+
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PATH", "C:\\Windows")
+	env2.Setenv("TEMP", "C:\\Temp")
+	env2.SetReadOnly("TEMP")
+	env2.SetArray("ARR", "foo", "bar")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualValue, actualSource, ok := stack.LookupEnvWithSource("path")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, ok)
+	assert.Equal(t, "C:\\Windows", actualValue)
+	assert.Equal(t, 1, actualSource.Index)
+	assert.True(t, stack.IsExported("path"))
+	assert.True(t, stack.IsReadOnly("temp"))
+	assert.Equal(t, "bar", stack.Expand("${arr[1]}"))
+
+	// Setenv updates the variable where it is, and keeps its name
+	err := stack.Setenv("path", "C:\\Tools")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"PATH=C:\\Tools", "TEMP=C:\\Temp"}, env2.AllVars())
+	assert.Empty(t, env1.AllVars())
+
+	// Unsetenv finds it too
+	stack.Unsetenv("path")
+	_, ok = stack.LookupEnv("PATH")
+	assert.False(t, ok)
+}
+
+func TestOverlayEnvIgnoresCaseWhenCaseSensitiveEnvsChange(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.CaseInsensitiveKeys)
+	env2 := envish.NewLocalEnv()
+	env2.Setenv("PATH", "C:\\Windows")
+	env3 := envish.NewSyncLocalEnv()
+	env3.Setenv("TEMP", "C:\\Temp")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2, env3})
+
+	// make sure that we have looked for variables at least once
+	assert.Equal(t, "C:\\Windows", stack.Getenv("path"))
+	assert.Equal(t, "C:\\Temp", stack.Getenv("temp"))
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env2.Unsetenv("PATH")
+	env2.Setenv("Path", "C:\\Tools")
+	env2.SetArray("ARR", "foo", "bar")
+	env3.Unsetenv("TEMP")
+	env3.Setenv("Temp", "D:\\Temp")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "C:\\Tools", stack.Getenv("PATH"))
+	assert.Equal(t, "D:\\Temp", stack.Getenv("TEMP"))
+	assert.Equal(t, "bar", stack.Expand("${arr[1]}"))
+
+	env2.Unsetenv("Path")
+	_, ok := stack.LookupEnv("PATH")
+	assert.False(t, ok)
+	env2.Unsetenv("ARR")
+	_, ok = stack.LookupEnv("arr")
+	assert.False(t, ok)
+}
+
+func TestOverlayEnvIgnoresCaseWhenLookingUpProgramEnvVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	localEnv := envish.NewLocalEnv(envish.CaseInsensitiveKeys)
+	progEnv := envish.NewProgramEnv()
+	stack := envish.NewOverlayEnv([]envish.Expander{localEnv, progEnv})
+
+	// we'll need to put the program's environment back afterwards!
+	origEnviron := os.Environ()
+	defer progEnv.RestoreEnvironment(origEnviron)
+
+	progEnv.Setenv("ENVISH_CASE_TEST", "found it")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualValue, ok := stack.LookupEnv("envish_case_test")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, ok)
+	assert.Equal(t, "found it", actualValue)
+	assert.Contains(t, stack.Environ(), "ENVISH_CASE_TEST=found it")
+}
+
+func TestOverlayEnvIsCaseSensitiveByDefault(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv(envish.SetAsExporter)
+	env1.Setenv("Path", "C:\\Tools")
+
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PATH", "C:\\Windows")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualEnviron := stack.Environ()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.False(t, stack.IsCaseInsensitive())
+	assert.Equal(t, []string{"PATH=C:\\Windows", "Path=C:\\Tools"}, actualEnviron)
+}
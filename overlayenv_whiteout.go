@@ -0,0 +1,209 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+// UnsetMode decides what OverlayEnv.Unsetenv does.
+type UnsetMode int
+
+const (
+	// UnsetEverywhere deletes the variable from every environment in
+	// the OverlayEnv. This is the default.
+	UnsetEverywhere UnsetMode = iota
+
+	// UnsetWithWhiteout only deletes the variable from the first
+	// environment in the OverlayEnv. If any of the other environments
+	// have the variable, the OverlayEnv records a whiteout against the
+	// first environment, so that the variable appears to be unset.
+	//
+	// The other environments are not changed. This is how overlay
+	// filesystems delete files from read-only layers.
+	UnsetWithWhiteout
+)
+
+// layerMeta is what an OverlayEnv knows about one of its environments
+type layerMeta struct {
+	// name is the environment's name, or "" if it has no name
+	name string
+
+	// whiteouts holds the keys that this environment hides from the
+	// environments after it, using the OverlayEnv's keyFolder
+	whiteouts map[string]bool
+}
+
+// ================================================================
+//
+// Whiteouts
+//
+// ----------------------------------------------------------------
+
+// SetUnsetMode decides what Unsetenv does from now on.
+//
+// Use UnsetWithWhiteout when the OverlayEnv shares environments (such
+// as a ProgramEnv) with other parts of your program, and you do not want
+// Unsetenv to delete variables from them.
+//
+// Changing the mode does not remove any whiteouts that have already
+// been recorded. Setenv and Export remove a whiteout when they set the
+// variable again.
+func (e *OverlayEnv) SetUnsetMode(mode UnsetMode) {
+	// do we have a stack to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.unsetMode = mode
+}
+
+// UnsetMode returns what Unsetenv does.
+func (e *OverlayEnv) UnsetMode() UnsetMode {
+	// do we have a stack to work with?
+	if e == nil {
+		return UnsetEverywhere
+	}
+
+	// yes we do
+	return e.unsetMode
+}
+
+// ================================================================
+//
+// Internal helpers
+//
+// ----------------------------------------------------------------
+
+// findEnv returns the index of the first environment that has the
+// variable named by the key, and the name that environment uses for it
+//
+// a whiteout hides the variable in every environment after the one
+// that it is recorded against
+func (e *OverlayEnv) findEnv(key string) (int, string, bool) {
+	ignoreCase := e.IsCaseInsensitive()
+	foldKey := e.keyFolder()
+	for i, env := range e.envs {
+		layerKey, ok := findKeyIn(env, key, ignoreCase)
+		if ok {
+			return i, layerKey, true
+		}
+
+		if e.hasWhiteout(i, foldKey(key)) {
+			return -1, key, false
+		}
+	}
+
+	// no joy
+	return -1, key, false
+}
+
+// isWhitedOut returns true if a whiteout hides the variable named by
+// the key in the environment at the given index
+func (e *OverlayEnv) isWhitedOut(key string, index int) bool {
+	foldKey := e.keyFolder()
+	for i := 0; i < index; i++ {
+		if e.hasWhiteout(i, foldKey(key)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasWhiteout returns true if a whiteout for the (already folded) key
+// has been recorded against the environment at the given index
+func (e *OverlayEnv) hasWhiteout(index int, foldedKey string) bool {
+	if index >= len(e.meta) {
+		return false
+	}
+
+	return e.meta[index].whiteouts[foldedKey]
+}
+
+// addWhiteout records a whiteout for the variable named by the key
+// against the environment at the given index
+func (e *OverlayEnv) addWhiteout(index int, key string) {
+	// make sure we have somewhere to record it
+	//
+	// OverlayEnv{} has no meta at all
+	for len(e.meta) < len(e.envs) {
+		e.meta = append(e.meta, layerMeta{})
+	}
+
+	meta := &e.meta[index]
+	if meta.whiteouts == nil {
+		meta.whiteouts = make(map[string]bool)
+	}
+	meta.whiteouts[e.keyFolder()(key)] = true
+}
+
+// removeWhiteouts removes any whiteouts for the variable named by the
+// key, from the environments up to and including the given index
+func (e *OverlayEnv) removeWhiteouts(key string, index int) {
+	foldedKey := e.keyFolder()(key)
+	for i := 0; i <= index && i < len(e.meta); i++ {
+		delete(e.meta[i].whiteouts, foldedKey)
+	}
+}
+
+// copyMeta returns a deep copy of what we know about our environments
+func (e *OverlayEnv) copyMeta() []layerMeta {
+	return copyLayerMeta(e.meta)
+}
+
+// copyLayerMeta returns a copy of the given layer meta data, that is
+// safe to change
+func copyLayerMeta(metas []layerMeta) []layerMeta {
+	retval := make([]layerMeta, len(metas))
+	for i, meta := range metas {
+		retval[i].name = meta.name
+		if meta.whiteouts == nil {
+			continue
+		}
+
+		retval[i].whiteouts = make(map[string]bool, len(meta.whiteouts))
+		for key := range meta.whiteouts {
+			retval[i].whiteouts[key] = true
+		}
+	}
+
+	return retval
+}
+
+// clearWhiteouts removes every whiteout
+func (e *OverlayEnv) clearWhiteouts() {
+	for i := range e.meta {
+		e.meta[i].whiteouts = nil
+	}
+}
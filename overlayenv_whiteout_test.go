@@ -0,0 +1,472 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"os"
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newWhiteoutStack returns an OverlayEnv that uses whiteouts, with a
+// variable set in its lower environment
+func newWhiteoutStack() (*envish.OverlayEnv, *envish.LocalEnv, *envish.LocalEnv) {
+	topEnv := envish.NewLocalEnv(envish.SetAsExporter)
+	lowerEnv := envish.NewLocalEnv(envish.SetAsExporter)
+	lowerEnv.Setenv("PARAM1", "lower value 1")
+	lowerEnv.Setenv("PARAM2", "lower value 2")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{topEnv, lowerEnv})
+	stack.SetUnsetMode(envish.UnsetWithWhiteout)
+
+	return stack, topEnv, lowerEnv
+}
+
+// ================================================================
+//
+// SetUnsetMode / UnsetMode
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvUnsetModeDefaultsToUnsetEverywhere(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack := envish.NewOverlayEnv([]envish.Expander{envish.NewLocalEnv()})
+	var nilStack *envish.OverlayEnv
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.UnsetEverywhere, stack.UnsetMode())
+	assert.Equal(t, envish.UnsetEverywhere, nilStack.UnsetMode())
+}
+
+func TestOverlayEnvSetUnsetModeChangesTheMode(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack := envish.NewOverlayEnv([]envish.Expander{envish.NewLocalEnv()})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.SetUnsetMode(envish.UnsetWithWhiteout)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.UnsetWithWhiteout, stack.UnsetMode())
+}
+
+func TestOverlayEnvSetUnsetModeCopesWithNilPointer(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var stack *envish.OverlayEnv
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.SetUnsetMode(envish.UnsetWithWhiteout)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.UnsetEverywhere, stack.UnsetMode())
+}
+
+// ================================================================
+//
+// Unsetenv
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvUnsetenvWithWhiteoutLeavesLowerEnvironmentsAlone(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, topEnv, lowerEnv := newWhiteoutStack()
+	topEnv.Setenv("PARAM1", "top value 1")
+
+	var events []envish.ChangeEvent
+	stack.OnChange(func(ev envish.ChangeEvent) {
+		events = append(events, ev)
+	})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	_, ok := stack.LookupEnv("PARAM1")
+	assert.False(t, ok)
+	assert.Equal(t, "", stack.Getenv("PARAM1"))
+
+	_, ok = topEnv.LookupEnv("PARAM1")
+	assert.False(t, ok)
+	assert.Equal(t, "lower value 1", lowerEnv.Getenv("PARAM1"))
+
+	assert.Equal(
+		t,
+		[]envish.ChangeEvent{
+			{Op: envish.ChangeUnset, Key: "PARAM1", OldValue: "top value 1", WasSet: true},
+		},
+		events,
+	)
+}
+
+func TestOverlayEnvUnsetenvWithWhiteoutLeavesProgramEnvAlone(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	t.Setenv("ENVISH_WHITEOUT_TEST", "program value")
+
+	stack := envish.NewOverlayEnv(
+		[]envish.Expander{
+			envish.NewLocalEnv(),
+			envish.NewProgramEnv(),
+		},
+	)
+	stack.SetUnsetMode(envish.UnsetWithWhiteout)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("ENVISH_WHITEOUT_TEST")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	_, ok := stack.LookupEnv("ENVISH_WHITEOUT_TEST")
+	assert.False(t, ok)
+	assert.Equal(t, "program value", os.Getenv("ENVISH_WHITEOUT_TEST"))
+}
+
+func TestOverlayEnvUnsetenvWithWhiteoutRespectsReadOnlyVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, lowerEnv := newWhiteoutStack()
+	lowerEnv.SetReadOnly("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "lower value 1", stack.Getenv("PARAM1"))
+}
+
+func TestOverlayEnvUnsetenvWithoutWhiteoutStillUnsetsEverywhere(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, lowerEnv := newWhiteoutStack()
+	stack.SetUnsetMode(envish.UnsetEverywhere)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	_, ok := lowerEnv.LookupEnv("PARAM1")
+	assert.False(t, ok)
+}
+
+// ================================================================
+//
+// Reader interface
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvWhiteoutHidesVariableFromEnviron(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, _ := newWhiteoutStack()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM2=lower value 2"}, stack.Environ())
+	assert.Equal(t, []string{"PARAM2=lower value 2"}, stack.AllVars())
+}
+
+func TestOverlayEnvWhiteoutHidesVariableFromMatchVarNames(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, _ := newWhiteoutStack()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM2"}, stack.MatchVarNames("PARAM"))
+}
+
+func TestOverlayEnvWhiteoutHidesVariableFromExpand(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, lowerEnv := newWhiteoutStack()
+	lowerEnv.SetArray("ARR", "zero", "one")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("PARAM1")
+	stack.Unsetenv("ARR")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "[] [lower value 2] [PARAM2] []", stack.Expand("[${PARAM1}] [${PARAM2}] [${!PARAM*}] [${ARR[1]}]"))
+
+	_, err := stack.ExpandWith("${PARAM1}", envish.ExpandOptions{NoUnset: true})
+	assert.Equal(t, envish.ErrUnboundVariables{Names: []string{"PARAM1"}}, err)
+}
+
+func TestOverlayEnvWhiteoutHidesVariableFromLookupEnvWithSource(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, _ := newWhiteoutStack()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	_, source, ok := stack.LookupEnvWithSource("PARAM1")
+	assert.False(t, ok)
+	assert.Equal(t, envish.VarSource{Index: -1}, source)
+
+	explanation := stack.Explain()
+	assert.Equal(t, 1, len(explanation))
+	assert.Equal(t, "PARAM2", explanation[0].Key)
+}
+
+// ================================================================
+//
+// Writer interface
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvSetenvRemovesWhiteout(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, topEnv, lowerEnv := newWhiteoutStack()
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.Setenv("PARAM1", "new value")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "new value", stack.Getenv("PARAM1"))
+	assert.Equal(t, "new value", topEnv.Getenv("PARAM1"))
+	assert.Equal(t, "lower value 1", lowerEnv.Getenv("PARAM1"))
+
+	// and it stays visible once it has been set again
+	topEnv.Unsetenv("PARAM1")
+	assert.Equal(t, "lower value 1", stack.Getenv("PARAM1"))
+}
+
+func TestOverlayEnvExportRemovesWhiteout(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	topEnv := envish.NewLocalEnv()
+	lowerEnv := envish.NewLocalEnv(envish.SetAsExporter)
+	lowerEnv.Setenv("PARAM1", "lower value 1")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{topEnv, lowerEnv})
+	stack.SetUnsetMode(envish.UnsetWithWhiteout)
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := stack.Export("PARAM1", "new value")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "new value", stack.Getenv("PARAM1"))
+	assert.Equal(t, []string{"PARAM1=new value"}, stack.Environ())
+}
+
+func TestOverlayEnvClearenvRemovesWhiteouts(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, lowerEnv := newWhiteoutStack()
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.Clearenv()
+	lowerEnv.Setenv("PARAM1", "lower value 1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "lower value 1", stack.Getenv("PARAM1"))
+}
+
+// ================================================================
+//
+// Layer management
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvPopEnvRemovesItsWhiteouts(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, _ := newWhiteoutStack()
+	stack.PushEnv(envish.NewLocalEnv())
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := stack.PopEnv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "lower value 1", stack.Getenv("PARAM1"))
+}
+
+func TestOverlayEnvPushEnvShowsVariablesAboveWhiteouts(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	stack, _, _ := newWhiteoutStack()
+	stack.Unsetenv("PARAM1")
+
+	stepEnv := envish.NewLocalEnv()
+	stepEnv.Setenv("PARAM1", "step value 1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	stack.PushEnv(stepEnv)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "step value 1", stack.Getenv("PARAM1"))
+}
+
+// ================================================================
+//
+// Transactions
+//
+// ----------------------------------------------------------------
+
+func TestOverlayEnvCommitFailurePutsWhiteoutsBack(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	topEnv := envish.NewLocalEnv()
+	lowerEnv := envish.NewLocalEnv()
+	lowerEnv.Setenv("PARAM1", "lower value 1")
+	lowerEnv.Setenv("PARAM2", "lower value 2")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{topEnv, lowerEnv})
+	stack.SetUnsetMode(envish.UnsetWithWhiteout)
+	stack.Unsetenv("PARAM2")
+
+	tx := stack.Begin()
+	tx.Unsetenv("PARAM1")
+	tx.Setenv("PARAM2", "new value 2")
+	tx.Setenv("PARAM3", "value")
+
+	// this will make the commit fail
+	topEnv.Setenv("PARAM3", "top value 3")
+	topEnv.SetReadOnly("PARAM3")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := tx.Commit()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "PARAM3"}, err)
+	assert.Equal(t, "lower value 1", stack.Getenv("PARAM1"))
+	_, ok := stack.LookupEnv("PARAM2")
+	assert.False(t, ok)
+}
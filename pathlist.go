@@ -0,0 +1,214 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import "strings"
+
+// ListOptions changes how the list helpers (SplitList, JoinList,
+// PrependPath, AppendPath, RemovePath and DedupePath) treat a variable
+// that holds a list, such as PATH or LD_LIBRARY_PATH.
+//
+// The zero value uses the UNIX list separator `:`.
+type ListOptions struct {
+	// Separator goes between each entry in the list. Use `;` for
+	// Windows-style lists.
+	Separator string
+}
+
+// DefaultListSeparator is the list separator that the list helpers use
+// when you do not give them one.
+const DefaultListSeparator = ":"
+
+// WithListSeparator returns an option that makes the list helpers use
+// sep between each entry in the list.
+func WithListSeparator(sep string) func(*ListOptions) {
+	return func(o *ListOptions) {
+		o.Separator = sep
+	}
+}
+
+// ================================================================
+//
+// Lists
+//
+// ----------------------------------------------------------------
+
+// SplitList splits value up into its entries.
+//
+// An empty value is an empty list. Otherwise, every entry is kept,
+// including empty entries. In PATH-like variables, an empty entry means
+// the current directory.
+func SplitList(value string, options ...func(*ListOptions)) []string {
+	// an empty list has no entries
+	if len(value) == 0 {
+		return []string{}
+	}
+
+	return strings.Split(value, listSeparator(options))
+}
+
+// JoinList puts the entries back together into a single value, ready
+// to store in a variable.
+//
+// A list that only holds one empty entry becomes an empty value, which
+// SplitList treats as an empty list. Use `.` instead of an empty entry
+// if you need to keep the current directory in that list.
+func JoinList(entries []string, options ...func(*ListOptions)) string {
+	return strings.Join(entries, listSeparator(options))
+}
+
+// ================================================================
+//
+// List variables
+//
+// ----------------------------------------------------------------
+
+// PrependPath adds dir to the front of the list stored in the variable
+// named by the key. Any copies of dir already in the list are removed
+// first, so that dir appears in the list exactly once.
+//
+// If the variable is not set, or is empty, it is set to dir. No empty
+// entry is added.
+func PrependPath(w ReaderWriter, key, dir string, options ...func(*ListOptions)) error {
+	return updateList("PrependPath", w, key, true, options, func(entries []string) []string {
+		return append([]string{dir}, removeEntry(entries, dir)...)
+	})
+}
+
+// AppendPath adds dir to the end of the list stored in the variable
+// named by the key. Any copies of dir already in the list are removed
+// first, so that dir appears in the list exactly once.
+//
+// If the variable is not set, or is empty, it is set to dir. No empty
+// entry is added.
+func AppendPath(w ReaderWriter, key, dir string, options ...func(*ListOptions)) error {
+	return updateList("AppendPath", w, key, true, options, func(entries []string) []string {
+		return append(removeEntry(entries, dir), dir)
+	})
+}
+
+// RemovePath removes every copy of dir from the list stored in the
+// variable named by the key.
+//
+// Pass an empty dir to remove the empty entries (i.e. the current
+// directory) from the list.
+//
+// If the variable is not set, RemovePath does nothing.
+func RemovePath(w ReaderWriter, key, dir string, options ...func(*ListOptions)) error {
+	return updateList("RemovePath", w, key, false, options, func(entries []string) []string {
+		return removeEntry(entries, dir)
+	})
+}
+
+// DedupePath removes repeated entries from the list stored in the
+// variable named by the key. The first copy of each entry is kept, so
+// the search order of the list does not change.
+//
+// If the variable is not set, DedupePath does nothing.
+func DedupePath(w ReaderWriter, key string, options ...func(*ListOptions)) error {
+	return updateList("DedupePath", w, key, false, options, func(entries []string) []string {
+		seen := make(map[string]bool, len(entries))
+		retval := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if seen[entry] {
+				continue
+			}
+			seen[entry] = true
+			retval = append(retval, entry)
+		}
+
+		return retval
+	})
+}
+
+// ================================================================
+//
+// Internal helpers
+//
+// ----------------------------------------------------------------
+
+// listSeparator works out which separator the given options ask for
+func listSeparator(options []func(*ListOptions)) string {
+	opts := ListOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if len(opts.Separator) == 0 {
+		return DefaultListSeparator
+	}
+
+	return opts.Separator
+}
+
+// updateList applies fn to the list stored in the variable named by the
+// key, and writes the result back
+//
+// if the variable is not set, it is only created if create is true. The
+// variable is only written to if its value changes.
+func updateList(method string, w ReaderWriter, key string, create bool, options []func(*ListOptions), fn func([]string) []string) error {
+	// do we have an environment to work with?
+	if w == nil {
+		return ErrNilPointer{method}
+	}
+
+	// do we have a list to change?
+	oldValue, ok := w.LookupEnv(key)
+	if !ok && !create {
+		return nil
+	}
+
+	newValue := JoinList(fn(SplitList(oldValue, options...)), options...)
+
+	// did anything change?
+	if ok && newValue == oldValue {
+		return nil
+	}
+
+	return w.Setenv(key, newValue)
+}
+
+// removeEntry returns a copy of entries, without any copies of entry
+func removeEntry(entries []string, entry string) []string {
+	retval := make([]string, 0, len(entries))
+	for _, candidate := range entries {
+		if candidate != entry {
+			retval = append(retval, candidate)
+		}
+	}
+
+	return retval
+}
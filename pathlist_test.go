@@ -0,0 +1,434 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// SplitList / JoinList
+//
+// ----------------------------------------------------------------
+
+func TestSplitListKeepsEmptyEntries(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := map[string][]string{
+		"":               {},
+		"/bin":           {"/bin"},
+		"/usr/bin:/bin":  {"/usr/bin", "/bin"},
+		":/bin":          {"", "/bin"},
+		"/bin:":          {"/bin", ""},
+		"/usr/bin::/bin": {"/usr/bin", "", "/bin"},
+		":":              {"", ""},
+	}
+
+	for input, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult := envish.SplitList(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedResult, actualResult, input)
+		assert.Equal(t, input, envish.JoinList(actualResult), input)
+	}
+}
+
+func TestSplitListSupportsOtherSeparators(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	input := `C:\Windows;C:\Program Files\Go\bin`
+	expectedResult := []string{`C:\Windows`, `C:\Program Files\Go\bin`}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := envish.SplitList(input, envish.WithListSeparator(";"))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+	assert.Equal(t, input, envish.JoinList(actualResult, envish.WithListSeparator(";")))
+}
+
+// ================================================================
+//
+// PrependPath
+//
+// ----------------------------------------------------------------
+
+func TestPrependPathAddsToTheFrontOfTheList(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", "/usr/bin:/bin")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.PrependPath(env, "PATH", "/opt/tools/bin")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/opt/tools/bin:/usr/bin:/bin", env.Getenv("PATH"))
+}
+
+func TestPrependPathMovesExistingEntryToTheFront(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", "/usr/bin:/opt/tools/bin:/bin:/opt/tools/bin")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.PrependPath(env, "PATH", "/opt/tools/bin")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/opt/tools/bin:/usr/bin:/bin", env.Getenv("PATH"))
+}
+
+func TestPrependPathDoesNotAddAnEmptyEntry(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unsetEnv := envish.NewLocalEnv()
+	emptyEnv := envish.NewLocalEnv()
+	emptyEnv.Setenv("LD_LIBRARY_PATH", "")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := envish.PrependPath(unsetEnv, "LD_LIBRARY_PATH", "/opt/lib")
+	err2 := envish.PrependPath(emptyEnv, "LD_LIBRARY_PATH", "/opt/lib")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err1)
+	assert.Nil(t, err2)
+	assert.Equal(t, "/opt/lib", unsetEnv.Getenv("LD_LIBRARY_PATH"))
+	assert.Equal(t, "/opt/lib", emptyEnv.Getenv("LD_LIBRARY_PATH"))
+}
+
+func TestPrependPathKeepsExistingEmptyEntries(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", "/usr/bin::/bin")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.PrependPath(env, "PATH", "/opt/tools/bin")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/opt/tools/bin:/usr/bin::/bin", env.Getenv("PATH"))
+}
+
+func TestPrependPathReturnsErrorsFromSetenv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", "/usr/bin:/bin")
+	env.SetReadOnly("PATH")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.PrependPath(env, "PATH", "/opt/tools/bin")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "PATH"}, err)
+	assert.Equal(t, "/usr/bin:/bin", env.Getenv("PATH"))
+}
+
+// ================================================================
+//
+// AppendPath
+//
+// ----------------------------------------------------------------
+
+func TestAppendPathAddsToTheEndOfTheList(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", `C:\Windows;C:\Windows\System32`)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.AppendPath(env, "PATH", `C:\Go\bin`, envish.WithListSeparator(";"))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, `C:\Windows;C:\Windows\System32;C:\Go\bin`, env.Getenv("PATH"))
+}
+
+func TestAppendPathMovesExistingEntryToTheEnd(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("MANPATH", "/opt/man:/usr/share/man")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.AppendPath(env, "MANPATH", "/opt/man")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/usr/share/man:/opt/man", env.Getenv("MANPATH"))
+}
+
+func TestAppendPathDoesNotAddAnEmptyEntry(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PYTHONPATH", "")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.AppendPath(env, "PYTHONPATH", "/opt/python")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/opt/python", env.Getenv("PYTHONPATH"))
+}
+
+// ================================================================
+//
+// RemovePath
+//
+// ----------------------------------------------------------------
+
+func TestRemovePathRemovesEveryCopy(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", "/opt/tools/bin:/usr/bin:/opt/tools/bin:/bin")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.RemovePath(env, "PATH", "/opt/tools/bin")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/usr/bin:/bin", env.Getenv("PATH"))
+}
+
+func TestRemovePathCanRemoveEmptyEntries(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", ":/usr/bin::/bin:")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.RemovePath(env, "PATH", "")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/usr/bin:/bin", env.Getenv("PATH"))
+}
+
+func TestRemovePathDoesNotSetMissingVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.RemovePath(env, "PATH", "/bin")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	_, ok := env.LookupEnv("PATH")
+	assert.False(t, ok)
+}
+
+// ================================================================
+//
+// DedupePath
+//
+// ----------------------------------------------------------------
+
+func TestDedupePathKeepsTheFirstCopy(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", "/usr/bin:/bin:/usr/bin::/bin:")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.DedupePath(env, "PATH")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/usr/bin:/bin:", env.Getenv("PATH"))
+}
+
+func TestDedupePathOnlyWritesWhenTheListChanges(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PATH", "/usr/bin:/bin")
+	env.SetReadOnly("PATH")
+
+	var events []envish.ChangeEvent
+	env.OnChange(func(ev envish.ChangeEvent) {
+		events = append(events, ev)
+	})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.DedupePath(env, "PATH")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+}
+
+// ================================================================
+//
+// Nil pointers
+//
+// ----------------------------------------------------------------
+
+func TestListVariableHelpersCopeWithNilEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := envish.PrependPath(nil, "PATH", "/bin")
+	err2 := envish.AppendPath(nil, "PATH", "/bin")
+	err3 := envish.RemovePath(nil, "PATH", "/bin")
+	err4 := envish.DedupePath(nil, "PATH")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrNilPointer{"PrependPath"}, err1)
+	assert.Equal(t, envish.ErrNilPointer{"AppendPath"}, err2)
+	assert.Equal(t, envish.ErrNilPointer{"RemovePath"}, err3)
+	assert.Equal(t, envish.ErrNilPointer{"DedupePath"}, err4)
+}
@@ -43,6 +43,9 @@ import (
 // ProgramEnv puts helper wrapper functions around your program's
 // environment.
 type ProgramEnv struct {
+	// hooks are called whenever a variable is changed through this
+	// ProgramEnv
+	hooks changeHooks
 }
 
 // ================================================================
@@ -125,11 +128,32 @@ func (e *ProgramEnv) MatchVarNames(prefix string) []string {
 // Use with extreme caution!
 func (e *ProgramEnv) Clearenv() {
 	os.Clearenv()
+
+	// tell anyone who is interested
+	e.notify(ChangeEvent{Op: ChangeClear})
 }
 
 // Setenv sets the value of the variable named by the key.
 func (e *ProgramEnv) Setenv(key, value string) error {
-	return os.Setenv(key, value)
+	// remember what we are replacing
+	oldValue, wasSet := os.LookupEnv(key)
+
+	err := os.Setenv(key, value)
+	if err != nil {
+		return err
+	}
+
+	// tell anyone who is interested
+	e.notify(ChangeEvent{
+		Op:       ChangeSet,
+		Key:      key,
+		OldValue: oldValue,
+		WasSet:   wasSet,
+		NewValue: value,
+	})
+
+	// all done
+	return nil
 }
 
 // Unsetenv deletes the variable named by the key.
@@ -137,7 +161,21 @@ func (e *ProgramEnv) Setenv(key, value string) error {
 // This will remove the given variable from your program's environment.
 // Use with caution!
 func (e *ProgramEnv) Unsetenv(key string) {
+	// do we have this variable?
+	oldValue, wasSet := os.LookupEnv(key)
+	if !wasSet {
+		return
+	}
+
 	_ = os.Unsetenv(key)
+
+	// tell anyone who is interested
+	e.notify(ChangeEvent{
+		Op:       ChangeUnset,
+		Key:      key,
+		OldValue: oldValue,
+		WasSet:   true,
+	})
 }
 
 // ================================================================
@@ -156,19 +194,51 @@ func (e *ProgramEnv) Expand(fmt string) string {
 	return expand(e, fmt)
 }
 
+// ExpandE replaces ${var} or $var in the input string, by looking up
+// values from your program's environment. It returns an error if the
+// input string cannot be expanded.
+func (e *ProgramEnv) ExpandE(fmt string) (string, error) {
+	return expandE(e, fmt)
+}
+
+// ExpandWith works like ExpandE. Use the options to choose what
+// counts as an error.
+func (e *ProgramEnv) ExpandWith(fmt string, options ExpandOptions) (string, error) {
+	return expandWith(e, fmt, options)
+}
+
 // ================================================================
 //
 // Unique methods
 //
 // ----------------------------------------------------------------
 
+// OnChange registers a function to call whenever Setenv, Unsetenv or
+// Clearenv changes your program's environment through this ProgramEnv.
+// That includes changes made during string expansion, such as
+// `${var:=word}`.
+//
+// Changes made any other way (for example, by calling os.Setenv
+// directly, or through a different ProgramEnv) are not reported.
+func (e *ProgramEnv) OnChange(fn func(ev ChangeEvent)) {
+	// do we have a ProgramEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.add(fn)
+}
+
 // RestoreEnvironment writes the given "key=value" pairs into your
 // program's environment.
 //
 // It does *not* empty your program's environment first!
 //
 // It was originally added so that our unit tests could put the 'go test'
-// program environment back in place after each test had run.
+// program environment back in place after each test had run. Use
+// TakeSnapshot and Snapshot.Restore if you also need to remove variables
+// that have been added since.
 func (e *ProgramEnv) RestoreEnvironment(pairs []string) {
 	for _, pair := range pairs {
 		key := GetKeyFromPair(pair)
@@ -177,3 +247,37 @@ func (e *ProgramEnv) RestoreEnvironment(pairs []string) {
 		e.Setenv(key, value)
 	}
 }
+
+// notify tells anyone who is interested about a change
+func (e *ProgramEnv) notify(ev ChangeEvent) {
+	// do we have a ProgramEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.notify(ev)
+}
+
+// holdHooks queues up any change events, instead of passing them on
+func (e *ProgramEnv) holdHooks(q *heldEvents) {
+	// do we have a ProgramEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.hold(q)
+}
+
+// releaseHooks passes on (if deliver is true) or throws away (if deliver
+// is false) any change events that have been queued up
+func (e *ProgramEnv) releaseHooks(deliver bool) {
+	// do we have a ProgramEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.hooks.release(deliver)
+}
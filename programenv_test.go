@@ -359,6 +359,42 @@ func TestProgramEnvUnsetenvUpdatesTheProgramEnvironment(t *testing.T) {
 	assert.Equal(t, expectedResult, actualResult)
 }
 
+func TestProgramEnvOnChangeReportsChangesMadeThroughTheProgramEnv(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewProgramEnv()
+
+	// clean up after ourselves
+	defer os.Unsetenv("TestProgramEnvOnChange")
+
+	var actualResult []envish.ChangeEvent
+	env.OnChange(func(ev envish.ChangeEvent) {
+		actualResult = append(actualResult, ev)
+	})
+
+	expectedResult := []envish.ChangeEvent{
+		{Op: envish.ChangeSet, Key: "TestProgramEnvOnChange", NewValue: "foo"},
+		{Op: envish.ChangeSet, Key: "TestProgramEnvOnChange", OldValue: "foo", WasSet: true, NewValue: "bar"},
+		{Op: envish.ChangeUnset, Key: "TestProgramEnvOnChange", OldValue: "bar", WasSet: true},
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Setenv("TestProgramEnvOnChange", "foo")
+	os.Setenv("TestProgramEnvOnChange", "ignored")
+	os.Setenv("TestProgramEnvOnChange", "foo")
+	env.Setenv("TestProgramEnvOnChange", "bar")
+	env.Unsetenv("TestProgramEnvOnChange")
+	env.Unsetenv("TestProgramEnvOnChange")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedResult, actualResult)
+}
+
 // ================================================================
 //
 // Expander interface
@@ -412,3 +448,64 @@ func TestProgramEnvExpandReturnsOriginalStringIfExpansionGeneratesAnError(t *tes
 
 	assert.Equal(t, expectedResult, actualResult)
 }
+
+func TestProgramEnvExpandEPerformsStringExpansion(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewProgramEnv()
+	env.Setenv("PARAM1", "foo")
+	expectedResult := "FOO"
+
+	// clean up after ourselves
+	defer os.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandE("${PARAM1:?}${PARAM1^^}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo"+expectedResult, actualResult)
+}
+
+func TestProgramEnvExpandEReturnsErrUnsetVariable(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewProgramEnv()
+	env.Unsetenv("PARAM1")
+	expectedError := envish.ErrUnsetVariable{Name: "PARAM1", Message: "must be set"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := env.ExpandE("${PARAM1:?must be set}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestProgramEnvExpandWithNoUnsetReturnsErrUnboundVariables(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewProgramEnv()
+	env.Unsetenv("PARAM1")
+	expectedError := envish.ErrUnboundVariables{Names: []string{"PARAM1"}}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := env.ExpandWith("${PARAM1}", envish.ExpandOptions{NoUnset: true})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
@@ -0,0 +1,457 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+// ScopedEnv emulates the way UNIX shell functions handle `local`
+// variables.
+//
+// It is built on an OverlayEnv. Each call to PushScope adds an empty
+// LocalEnv to the top of the overlay, and PopScope throws it away again.
+// The environment you pass into NewScopedEnv is the global scope, and is
+// always at the bottom of the overlay.
+//
+// Variables are looked up in the innermost scope first, then in each
+// outer scope in turn, and finally in the global scope (ie, dynamic
+// scoping, just like bash).
+type ScopedEnv struct {
+	overlay *OverlayEnv
+}
+
+// ================================================================
+//
+// Constructors
+//
+// ----------------------------------------------------------------
+
+// NewScopedEnv creates a ScopedEnv that uses the given environment as
+// its global scope.
+//
+// The new ScopedEnv has no local scopes. Call PushScope to add one.
+func NewScopedEnv(global Expander) *ScopedEnv {
+	retval := ScopedEnv{
+		overlay: NewOverlayEnv([]Expander{global}),
+	}
+
+	// all done
+	return &retval
+}
+
+// ================================================================
+//
+// Reader interface
+//
+// ----------------------------------------------------------------
+
+// Environ returns a copy of all exported variables that are visible from
+// the innermost scope, in the form "key=value".
+//
+// It follows the same rules as OverlayEnv.Environ. Local variables are
+// not exported, unless you call Export on the LocalEnv that holds them.
+func (e *ScopedEnv) Environ() []string {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	return e.overlay.Environ()
+}
+
+// Getenv returns the value of the variable named by the key, from the
+// innermost scope that has it.
+//
+// If the key is not found, an empty string is returned.
+func (e *ScopedEnv) Getenv(key string) string {
+	value, _ := e.LookupEnv(key)
+	return value
+}
+
+// IsExporter returns `true` if any of the scopes hold variables that
+// should be exported to external programs.
+func (e *ScopedEnv) IsExporter() bool {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	return e.overlay.IsExporter()
+}
+
+// LookupEnv returns the value of the variable named by the key, from the
+// innermost scope that has it.
+//
+// If the key is not found, an empty string is returned, and the returned
+// boolean is false.
+func (e *ScopedEnv) LookupEnv(key string) (string, bool) {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return "", false
+	}
+
+	// yes we do
+	return e.overlay.LookupEnv(key)
+}
+
+// MatchVarNames returns a list of variable names that start with the
+// given prefix, from every scope.
+//
+// It's a feature needed for `${!prefix*}` string expansion syntax.
+func (e *ScopedEnv) MatchVarNames(prefix string) []string {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	return e.overlay.MatchVarNames(prefix)
+}
+
+// ================================================================
+//
+// Writer interface
+//
+// ----------------------------------------------------------------
+
+// Clearenv deletes all variables in every scope, including the global
+// scope.
+//
+// If your global scope includes a ProgramEnv, this *WILL* delete all of
+// your program's environment variables.
+func (e *ScopedEnv) Clearenv() {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.overlay.Clearenv()
+}
+
+// Setenv creates a variable (if it doesn't already exist) or updates its
+// value (if it does exist).
+//
+// * it updates the variable in the innermost scope that has it, or that
+// has declared it with DeclareLocal
+//
+// * if no scope has the variable, it is created in the global scope
+//
+// Use DeclareLocal first if you want to create a variable in the
+// innermost scope.
+func (e *ScopedEnv) Setenv(key, value string) error {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return ErrNilPointer{"ScopedEnv.Setenv"}
+	}
+
+	// are we allowed to change it?
+	if e.overlay.IsReadOnly(key) {
+		return ErrReadOnlyVar{Key: key}
+	}
+
+	// yes we are
+	i := e.scopeIndexFor(key)
+	err := e.overlay.envs[i].Setenv(key, value)
+	if err != nil {
+		return err
+	}
+
+	// if it was declared, it has a value now
+	e.overlay.removeWhiteouts(key, i)
+
+	// all done
+	return nil
+}
+
+// Unsetenv deletes the variable named by the key from the innermost
+// scope that has it (or that has declared it with DeclareLocal).
+//
+// Just like bash, a local variable in the innermost scope stays declared:
+// it is unset until you give it a new value, and it still hides any
+// variable with the same name in the outer scopes until you call
+// PopScope. If the variable belongs to an outer scope instead, any value
+// in the scopes beyond that becomes visible again.
+//
+// It does nothing if the variable is read-only in any scope.
+func (e *ScopedEnv) Unsetenv(key string) {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// are we allowed to delete it?
+	if e.overlay.IsReadOnly(key) {
+		return
+	}
+
+	// yes we are
+	i := e.scopeIndexFor(key)
+	e.overlay.envs[i].Unsetenv(key)
+
+	// is it a local variable in the innermost scope?
+	if i == 0 && e.Depth() > 0 {
+		// yes it is, so it must keep hiding the outer scopes
+		e.overlay.addWhiteout(0, key)
+		return
+	}
+
+	e.overlay.removeWhiteouts(key, i)
+}
+
+// ================================================================
+//
+// Expander interface
+//
+// ----------------------------------------------------------------
+
+// Expand replaces ${var} or $var in the input string, using the
+// variables that are visible from the innermost scope.
+//
+// Any `${var:=word}` assignments follow the same rules as Setenv.
+func (e *ScopedEnv) Expand(fmt string) string {
+	return expand(e, fmt)
+}
+
+// ExpandE works like Expand, except that it returns an error if the
+// input string cannot be expanded.
+func (e *ScopedEnv) ExpandE(fmt string) (string, error) {
+	return expandE(e, fmt)
+}
+
+// ExpandWith works like ExpandE. Use the options to choose what
+// counts as an error.
+func (e *ScopedEnv) ExpandWith(fmt string, options ExpandOptions) (string, error) {
+	return expandWith(e, fmt, options)
+}
+
+// ================================================================
+//
+// ExportChecker and ReadOnlyChecker interfaces
+//
+// ----------------------------------------------------------------
+
+// AllVars returns a copy of all variables that are visible from the
+// innermost scope, in the form "key=value", whether they have been
+// exported or not.
+func (e *ScopedEnv) AllVars() []string {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	return e.overlay.AllVars()
+}
+
+// IsExported returns true if the variable named by the key will be
+// included in the output of Environ.
+func (e *ScopedEnv) IsExported(key string) bool {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	return e.overlay.IsExported(key)
+}
+
+// IsReadOnly returns true if the variable named by the key is read-only
+// in any scope.
+func (e *ScopedEnv) IsReadOnly(key string) bool {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	return e.overlay.IsReadOnly(key)
+}
+
+// ================================================================
+//
+// Struct-unique functions
+//
+// ----------------------------------------------------------------
+
+// DeclareLocal emulates UNIX shell `local XXX` behaviour. It declares the
+// variable named by the key in the innermost scope, without giving it a
+// value. From then on, Setenv and Unsetenv work on this local variable,
+// and it hides any variable with the same name in the outer scopes.
+//
+// Just like bash, the variable is unset until you give it a value, so
+// `${XXX-default}` expands to `default`.
+//
+// If the variable has already been declared in the innermost scope, its
+// value is left alone.
+//
+// It returns ErrNoLocalScope if you haven't called PushScope.
+func (e *ScopedEnv) DeclareLocal(key string) error {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return ErrNilPointer{"ScopedEnv.DeclareLocal"}
+	}
+
+	// do we have a local scope to declare it in?
+	if e.Depth() == 0 {
+		return ErrNoLocalScope{"ScopedEnv.DeclareLocal"}
+	}
+
+	// make sure we have a key that we can work with
+	err := checkKeyOf(e.overlay.envs[0], key)
+	if err != nil {
+		return err
+	}
+
+	// are we allowed to hide it?
+	if e.overlay.IsReadOnly(key) {
+		return ErrReadOnlyVar{Key: key}
+	}
+
+	// has it already been declared?
+	_, ok := e.overlay.envs[0].LookupEnv(key)
+	if ok {
+		return nil
+	}
+
+	// no, so declare it now
+	//
+	// a whiteout hides the variable in the outer scopes, without
+	// giving it a value in this one
+	e.overlay.addWhiteout(0, key)
+
+	// all done
+	return nil
+}
+
+// Depth returns the number of local scopes. It returns 0 if only the
+// global scope remains.
+func (e *ScopedEnv) Depth() int {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return 0
+	}
+
+	// yes we do
+	return e.overlay.Len() - 1
+}
+
+// PopScope throws away the innermost scope, and every local variable
+// declared in it.
+//
+// It returns ErrNoLocalScope if only the global scope remains.
+func (e *ScopedEnv) PopScope() error {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return ErrNilPointer{"ScopedEnv.PopScope"}
+	}
+
+	// do we have a local scope to throw away?
+	if e.Depth() == 0 {
+		return ErrNoLocalScope{"ScopedEnv.PopScope"}
+	}
+
+	// yes we do
+	_, err := e.overlay.PopEnv()
+	return err
+}
+
+// PushScope adds a new, empty scope. Call it whenever your shell
+// function starts.
+func (e *ScopedEnv) PushScope() {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.overlay.PushEnv(NewLocalEnv())
+}
+
+// ================================================================
+//
+// Internal helpers
+//
+// ----------------------------------------------------------------
+
+// lookupArray returns the array named by the key, from the innermost
+// scope that has the key
+func (e *ScopedEnv) lookupArray(key string) (arrayVar, bool) {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return arrayVar{}, false
+	}
+
+	// yes we do
+	return e.overlay.lookupArray(key)
+}
+
+// scopeIndexFor returns the index of the innermost scope that has (or
+// has declared) the variable named by the key, or the index of the global
+// scope if none of them do
+func (e *ScopedEnv) scopeIndexFor(key string) int {
+	foldedKey := e.overlay.keyFolder()(key)
+	for i, env := range e.overlay.envs {
+		_, ok := env.LookupEnv(key)
+		if ok || e.overlay.hasWhiteout(i, foldedKey) {
+			return i
+		}
+	}
+
+	return len(e.overlay.envs) - 1
+}
+
+// holdHooks queues up any change events, instead of passing them on
+func (e *ScopedEnv) holdHooks(q *heldEvents) {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.overlay.holdHooks(q)
+}
+
+// releaseHooks passes on (if deliver is true) or throws away (if deliver
+// is false) any change events that have been queued up
+func (e *ScopedEnv) releaseHooks(deliver bool) {
+	// do we have a ScopedEnv to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.overlay.releaseHooks(deliver)
+}
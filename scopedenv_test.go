@@ -0,0 +1,656 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// Constructors
+//
+// ----------------------------------------------------------------
+
+func TestNewScopedEnvStartsWithNoLocalScopes(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv(envish.SetAsExporter)
+	global.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit := envish.NewScopedEnv(global)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, 0, unit.Depth())
+	assert.Equal(t, "foo", unit.Getenv("PARAM1"))
+	assert.Equal(t, []string{"PARAM1=foo"}, unit.Environ())
+}
+
+// ================================================================
+//
+// Scopes
+//
+// ----------------------------------------------------------------
+
+func TestScopedEnvPushScopeAddsALocalScope(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewScopedEnv(envish.NewLocalEnv())
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.PushScope()
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, 2, unit.Depth())
+}
+
+func TestScopedEnvPopScopeThrowsAwayLocalVariables(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "foo")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+	unit.DeclareLocal("PARAM1")
+	unit.Setenv("PARAM1", "local")
+	unit.DeclareLocal("PARAM2")
+	unit.Setenv("PARAM2", "bar")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.PopScope()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, unit.Depth())
+	assert.Equal(t, "foo", unit.Getenv("PARAM1"))
+	_, ok := unit.LookupEnv("PARAM2")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"PARAM1=foo"}, global.AllVars())
+}
+
+func TestScopedEnvPopScopeReturnsErrorWhenOnlyGlobalScopeRemains(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewScopedEnv(envish.NewLocalEnv())
+	expectedError := envish.ErrNoLocalScope{Method: "ScopedEnv.PopScope"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.PopScope()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, 0, unit.Depth())
+}
+
+// ================================================================
+//
+// Lookups
+//
+// ----------------------------------------------------------------
+
+func TestScopedEnvUsesDynamicScoping(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "global")
+	global.Setenv("PARAM2", "global")
+
+	unit := envish.NewScopedEnv(global)
+
+	// the outer function
+	unit.PushScope()
+	unit.DeclareLocal("PARAM1")
+	unit.Setenv("PARAM1", "outer")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	// the inner function
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	// the inner function sees the outer function's locals
+	assert.Equal(t, "outer", unit.Getenv("PARAM1"))
+	assert.Equal(t, "global", unit.Getenv("PARAM2"))
+	assert.Equal(t, "outer global", unit.Expand("$PARAM1 $PARAM2"))
+	assert.Equal(t, []string{"PARAM1", "PARAM2"}, unit.MatchVarNames("PARAM"))
+}
+
+func TestScopedEnvLocalsAreNotExported(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv(envish.SetAsExporter)
+	global.Setenv("PARAM1", "foo")
+	global.Setenv("PARAM2", "bar")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.DeclareLocal("PARAM1")
+	unit.Setenv("PARAM1", "")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM2=bar"}, unit.Environ())
+	assert.Equal(t, []string{"PARAM1=", "PARAM2=bar"}, unit.AllVars())
+	assert.False(t, unit.IsExported("PARAM1"))
+	assert.True(t, unit.IsExported("PARAM2"))
+}
+
+// ================================================================
+//
+// DeclareLocal
+//
+// ----------------------------------------------------------------
+
+func TestScopedEnvDeclareLocalHidesOuterVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "foo")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.DeclareLocal("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	actualValue, ok := unit.LookupEnv("PARAM1")
+	assert.False(t, ok)
+	assert.Equal(t, "", actualValue)
+	assert.Equal(t, "default", unit.Expand("${PARAM1-default}"))
+	assert.Equal(t, "foo", global.Getenv("PARAM1"))
+}
+
+func TestScopedEnvDeclareLocalVariableCanBeSetLater(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "foo")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+	unit.DeclareLocal("PARAM1")
+	unit.DeclareLocal("PARAM2")
+
+	// the inner function
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.Setenv("PARAM1", "local")
+	result := unit.Expand("${PARAM2:=assigned}")
+	unit.PopScope()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "assigned", result)
+	assert.Equal(t, "local", unit.Getenv("PARAM1"))
+	assert.Equal(t, "assigned", unit.Getenv("PARAM2"))
+	assert.Equal(t, []string{"PARAM1=foo"}, global.AllVars())
+
+	// once the function returns, the globals are visible again
+	unit.PopScope()
+	assert.Equal(t, "foo", unit.Getenv("PARAM1"))
+	_, ok := unit.LookupEnv("PARAM2")
+	assert.False(t, ok)
+}
+
+func TestScopedEnvUnsetenvKeepsDeclarationWithoutAValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "foo")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+	unit.DeclareLocal("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	_, ok := unit.LookupEnv("PARAM1")
+	assert.False(t, ok)
+	assert.Equal(t, "foo", global.Getenv("PARAM1"))
+
+	// once the function returns, the global is visible again
+	unit.PopScope()
+	assert.Equal(t, "foo", unit.Getenv("PARAM1"))
+}
+
+func TestScopedEnvDeclareLocalKeepsExistingLocalValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewScopedEnv(envish.NewLocalEnv())
+	unit.PushScope()
+	unit.DeclareLocal("PARAM1")
+	unit.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.DeclareLocal("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", unit.Getenv("PARAM1"))
+}
+
+func TestScopedEnvDeclareLocalReturnsErrorWithNoLocalScope(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	unit := envish.NewScopedEnv(global)
+	expectedError := envish.ErrNoLocalScope{Method: "ScopedEnv.DeclareLocal"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.DeclareLocal("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	_, ok := global.LookupEnv("PARAM1")
+	assert.False(t, ok)
+}
+
+func TestScopedEnvDeclareLocalReturnsErrorForEmptyKey(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewScopedEnv(envish.NewLocalEnv())
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.DeclareLocal("  ")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrEmptyKey{}, err)
+}
+
+func TestScopedEnvDeclareLocalReturnsErrorForReadOnlyVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "foo")
+	global.SetReadOnly("PARAM1")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.DeclareLocal("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "PARAM1"}, err)
+	assert.Equal(t, "foo", unit.Getenv("PARAM1"))
+}
+
+// ================================================================
+//
+// Writes
+//
+// ----------------------------------------------------------------
+
+func TestScopedEnvSetenvUpdatesNearestScopeThatHasTheVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "global")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+	unit.DeclareLocal("PARAM1")
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.Setenv("PARAM1", "changed")
+	unit.PopScope()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+
+	// the outer function's local was changed
+	assert.Equal(t, "changed", unit.Getenv("PARAM1"))
+
+	// the global was not
+	assert.Equal(t, "global", global.Getenv("PARAM1"))
+}
+
+func TestScopedEnvSetenvCreatesNewVariablesInGlobalScope(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.Setenv("PARAM1", "foo")
+	unit.PopScope()
+	unit.PopScope()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", unit.Getenv("PARAM1"))
+	assert.Equal(t, "foo", global.Getenv("PARAM1"))
+}
+
+func TestScopedEnvSetenvReturnsErrorForReadOnlyVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "foo")
+	global.SetReadOnly("PARAM1")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.Setenv("PARAM1", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "PARAM1"}, err)
+	assert.True(t, unit.IsReadOnly("PARAM1"))
+	assert.Equal(t, "foo", unit.Getenv("PARAM1"))
+}
+
+func TestScopedEnvExpandAssignmentsFollowSetenvRules(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := unit.Expand("${PARAM1:=foo}")
+	unit.PopScope()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "foo", actualResult)
+	assert.Equal(t, "foo", global.Getenv("PARAM1"))
+}
+
+func TestScopedEnvUnsetenvKeepsLocalVariableUnsetUntilPopScope(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "global")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+	unit.DeclareLocal("PARAM1")
+	unit.Setenv("PARAM1", "local")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	_, ok := unit.LookupEnv("PARAM1")
+	assert.False(t, ok)
+
+	// a second unset does not reach the global scope
+	unit.Unsetenv("PARAM1")
+	assert.Equal(t, "global", global.Getenv("PARAM1"))
+
+	// it is still a local variable
+	unit.Setenv("PARAM1", "changed")
+	assert.Equal(t, "changed", unit.Getenv("PARAM1"))
+	assert.Equal(t, "global", global.Getenv("PARAM1"))
+
+	// once the function returns, the global is visible again
+	unit.PopScope()
+	assert.Equal(t, "global", unit.Getenv("PARAM1"))
+}
+
+func TestScopedEnvUnsetenvRevealsVariableBeyondOuterScope(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "global")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+	unit.DeclareLocal("PARAM1")
+	unit.Setenv("PARAM1", "outer")
+	unit.PushScope()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	// just like bash, unsetting the caller's local variable reveals
+	// the global one
+	assert.Equal(t, "global", unit.Getenv("PARAM1"))
+
+	unit.PopScope()
+	assert.Equal(t, "global", unit.Getenv("PARAM1"))
+}
+
+func TestScopedEnvClearenvEmptiesEveryScope(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	global := envish.NewLocalEnv()
+	global.Setenv("PARAM1", "foo")
+
+	unit := envish.NewScopedEnv(global)
+	unit.PushScope()
+	unit.DeclareLocal("PARAM2")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{}, unit.AllVars())
+	assert.Equal(t, 1, unit.Depth())
+}
+
+// ================================================================
+//
+// Nil pointers
+//
+// ----------------------------------------------------------------
+
+func TestScopedEnvNilPointerIsSafe(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var unit *envish.ScopedEnv
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.PushScope()
+	unit.Unsetenv("PARAM1")
+	unit.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, 0, unit.Depth())
+	assert.Equal(t, []string{}, unit.Environ())
+	assert.Equal(t, []string{}, unit.AllVars())
+	assert.Equal(t, []string{}, unit.MatchVarNames("PARAM"))
+	assert.Equal(t, "", unit.Getenv("PARAM1"))
+	assert.False(t, unit.IsExporter())
+	assert.False(t, unit.IsExported("PARAM1"))
+	assert.False(t, unit.IsReadOnly("PARAM1"))
+	assert.Equal(t, envish.ErrNilPointer{Method: "ScopedEnv.Setenv"}, unit.Setenv("PARAM1", "foo"))
+	assert.Equal(t, envish.ErrNilPointer{Method: "ScopedEnv.DeclareLocal"}, unit.DeclareLocal("PARAM1"))
+	assert.Equal(t, envish.ErrNilPointer{Method: "ScopedEnv.PopScope"}, unit.PopScope())
+}
@@ -0,0 +1,587 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ShellDialect tells WriteShellExports and ReadShellExports which UNIX
+// shell they are working with.
+type ShellDialect int
+
+// These are the shells that we support.
+const (
+	// ShellSh is the POSIX shell (including dash and busybox sh)
+	ShellSh ShellDialect = iota
+	// ShellBash is the GNU Bourne-Again shell
+	ShellBash
+	// ShellZsh is the Z shell
+	ShellZsh
+	// ShellFish is the friendly interactive shell
+	ShellFish
+)
+
+// String returns the name of the shell.
+func (d ShellDialect) String() string {
+	switch d {
+	case ShellSh:
+		return "sh"
+	case ShellBash:
+		return "bash"
+	case ShellZsh:
+		return "zsh"
+	case ShellFish:
+		return "fish"
+	default:
+		return "ShellDialect(" + strconv.Itoa(int(d)) + ")"
+	}
+}
+
+// ================================================================
+//
+// Writing
+//
+// ----------------------------------------------------------------
+
+// WriteShellExports writes the contents of the given environment to w,
+// as a script that the given shell can source.
+//
+// For sh, bash and zsh, each variable is written as `export KEY='value'`.
+// For fish, each variable is written as `set -gx KEY 'value'`. fish treats
+// any variable whose name ends in `PATH` as a list, so these are written
+// as `set -gx KEY 'entry1' 'entry2' ...` instead.
+//
+// Every value is single-quoted, so that the shell will not perform any
+// expansion on it when the script is sourced.
+//
+// It returns ErrShellKey if the environment contains a variable name
+// that the shell cannot export, such as `$#`.
+func WriteShellExports(w io.Writer, r Reader, dialect ShellDialect) error {
+	// do we have an environment to work with?
+	if r == nil {
+		return ErrNilPointer{"WriteShellExports"}
+	}
+
+	// do we know how to write for this shell?
+	var format func(key, value string) string
+	switch dialect {
+	case ShellSh, ShellBash, ShellZsh:
+		format = formatPosixShellExport
+	case ShellFish:
+		format = formatFishShellExport
+	default:
+		return ErrUnsupportedShellDialect{dialect}
+	}
+
+	for _, pair := range r.Environ() {
+		key := GetKeyFromPair(pair)
+		if !isValidShellKey(key) {
+			return ErrShellKey{key}
+		}
+
+		_, err := io.WriteString(w, format(key, GetValueFromPair(pair, key))+"\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	// all done
+	return nil
+}
+
+// formatPosixShellExport returns the command that sh, bash and zsh use
+// to export the given variable
+func formatPosixShellExport(key, value string) string {
+	return "export " + key + "=" + quotePosixShellValue(value)
+}
+
+// formatFishShellExport returns the command that fish uses to export
+// the given variable
+func formatFishShellExport(key, value string) string {
+	if !isFishPathVar(key) {
+		return "set -gx " + key + " " + quoteFishShellValue(value)
+	}
+
+	// fish joins the entries back together with ':' when it exports
+	// a path variable
+	var buf strings.Builder
+	buf.WriteString("set -gx " + key)
+	for _, entry := range SplitList(value) {
+		buf.WriteString(" " + quoteFishShellValue(entry))
+	}
+	return buf.String()
+}
+
+// isFishPathVar returns true if fish treats the given variable as a
+// list of paths
+func isFishPathVar(key string) bool {
+	return strings.HasSuffix(key, "PATH")
+}
+
+// quotePosixShellValue wraps the value in single quotes
+//
+// POSIX shells do not support any escapes inside single quotes, so we
+// have to close the quotes to add a single quote
+func quotePosixShellValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteFishShellValue wraps the value in single quotes
+//
+// fish supports `\'` and `\\` inside single quotes
+func quoteFishShellValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// isValidShellKey returns true if the given key is a valid shell
+// variable name
+func isValidShellKey(key string) bool {
+	return isShellName(key) && matchParamName(key) == len(key)
+}
+
+// ================================================================
+//
+// Reading
+//
+// ----------------------------------------------------------------
+
+// LoadShellExports creates a new LocalEnv, and loads the output of
+// `export -p` (or `declare -x`) from the given shell into it. The new
+// LocalEnv is an exporter.
+//
+// See ReadShellExports for details.
+func LoadShellExports(r io.Reader, dialect ShellDialect) (*LocalEnv, error) {
+	retval := NewLocalEnv(SetAsExporter)
+
+	err := ReadShellExports(retval, r, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	// all done
+	return retval, nil
+}
+
+// LoadShellExportsFile creates a new LocalEnv, and loads the given file
+// into it. The file must contain the output of `export -p` (or
+// `declare -x`) from the given shell.
+//
+// See ReadShellExports for details.
+func LoadShellExportsFile(path string, dialect ShellDialect) (*LocalEnv, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadShellExports(f, dialect)
+}
+
+// ReadShellExports parses the output of `export -p` (or `declare -x`)
+// from the given shell, and sets each variable that it finds in the
+// given environment.
+//
+// For sh, bash and zsh, it understands `export`, `declare` and `typeset`
+// commands. For fish, it understands `set` commands. It also reads back
+// anything written by WriteShellExports.
+//
+// It understands the shell's quoting rules (including `$'...'` quoting
+// for sh, bash and zsh), but it never performs any expansion or runs
+// any commands. Variables that are exported without a value are skipped.
+//
+// It returns ErrShellSyntax if anything goes wrong, or ErrShellSetenv if
+// the environment refuses to set a variable. Any variables before the
+// problem will already have been set.
+func ReadShellExports(w Writer, r io.Reader, dialect ShellDialect) error {
+	// do we have an environment to work with?
+	if w == nil {
+		return ErrNilPointer{"ReadShellExports"}
+	}
+
+	// do we know how to read this shell?
+	if dialect < ShellSh || dialect > ShellFish {
+		return ErrUnsupportedShellDialect{dialect}
+	}
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	lexer := shellLexer{
+		input:   string(raw),
+		line:    1,
+		dialect: dialect,
+	}
+	for {
+		words, line, err := lexer.nextCommand()
+		if err != nil {
+			return err
+		}
+		if words == nil {
+			return nil
+		}
+
+		err = setShellExports(w, words, line, dialect)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// setShellExports works out which variables the given command exports,
+// and sets them in the environment
+func setShellExports(w Writer, words []string, line int, dialect ShellDialect) error {
+	// what command are we looking at?
+	switch {
+	case dialect == ShellFish && words[0] == "set":
+		return setFishShellExport(w, words[1:], line)
+	case dialect != ShellFish && (words[0] == "export" || words[0] == "declare" || words[0] == "typeset"):
+		return setPosixShellExports(w, words[1:], line)
+	default:
+		return ErrShellSyntax{line, "unsupported command '" + words[0] + "'"}
+	}
+}
+
+func setPosixShellExports(w Writer, args []string, line int) error {
+	for _, arg := range args {
+		// skip over any options
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		// skip anything exported without a value
+		eq := strings.IndexByte(arg, '=')
+		if eq < 0 {
+			if !isValidShellKey(arg) {
+				return ErrShellSyntax{line, "invalid variable name '" + arg + "'"}
+			}
+			continue
+		}
+
+		key := arg[:eq]
+		if !isValidShellKey(key) {
+			return ErrShellSyntax{line, "invalid variable name '" + key + "'"}
+		}
+
+		err := w.Setenv(key, arg[eq+1:])
+		if err != nil {
+			return ErrShellSetenv{line, err}
+		}
+	}
+
+	// all done
+	return nil
+}
+
+func setFishShellExport(w Writer, args []string, line int) error {
+	// skip over any options
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		i++
+	}
+	if i == len(args) {
+		return ErrShellSyntax{line, "missing variable name"}
+	}
+
+	key := args[i]
+	if !isValidShellKey(key) {
+		return ErrShellSyntax{line, "invalid variable name '" + key + "'"}
+	}
+
+	// fish variables are lists, which are exported separated by spaces
+	// (or by ':' for path variables)
+	sep := " "
+	if isFishPathVar(key) {
+		sep = DefaultListSeparator
+	}
+
+	err := w.Setenv(key, strings.Join(args[i+1:], sep))
+	if err != nil {
+		return ErrShellSetenv{line, err}
+	}
+
+	// all done
+	return nil
+}
+
+// shellLexer breaks the output of `export -p` up into commands, and
+// the commands up into words
+type shellLexer struct {
+	// input is everything that we have been asked to parse
+	input string
+
+	// pos is where we are in the input
+	pos int
+
+	// line is the line number of input[pos]
+	line int
+
+	// dialect is the shell whose quoting rules we follow
+	dialect ShellDialect
+}
+
+// nextCommand returns the words of the next command in the input, or
+// nil if there are no more commands
+func (l *shellLexer) nextCommand() ([]string, int, error) {
+	var words []string
+	line := l.line
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '\n' || c == ';':
+			l.pos++
+			if c == '\n' {
+				l.line++
+			}
+			if words != nil {
+				return words, line, nil
+			}
+			line = l.line
+		case c == '#':
+			// comments run to the end of the line
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			word, err := l.nextWord()
+			if err != nil {
+				return nil, line, err
+			}
+			words = append(words, word)
+		}
+	}
+
+	// if we get here, we have run out of input
+	return words, line, nil
+}
+
+// nextWord returns the next word in the input, with all quoting removed
+func (l *shellLexer) nextWord() (string, error) {
+	var buf strings.Builder
+	line := l.line
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ';':
+			return buf.String(), nil
+		case c == '\\':
+			l.pos++
+			if l.pos < len(l.input) {
+				if l.input[l.pos] == '\n' {
+					// line continuation
+					l.line++
+				} else {
+					buf.WriteByte(l.input[l.pos])
+				}
+				l.pos++
+			}
+		case c == '\'':
+			l.pos++
+			ok := l.readSingleQuoted(&buf)
+			if !ok {
+				return "", ErrShellSyntax{line, "missing closing quote"}
+			}
+		case c == '"':
+			l.pos++
+			ok := l.readDoubleQuoted(&buf)
+			if !ok {
+				return "", ErrShellSyntax{line, "missing closing quote"}
+			}
+		case c == '$' && l.dialect != ShellFish && strings.HasPrefix(l.input[l.pos:], "$'"):
+			l.pos += 2
+			ok := l.readANSICQuoted(&buf)
+			if !ok {
+				return "", ErrShellSyntax{line, "missing closing quote"}
+			}
+		default:
+			buf.WriteByte(c)
+			l.pos++
+		}
+	}
+
+	// if we get here, we have run out of input
+	return buf.String(), nil
+}
+
+// readSingleQuoted adds everything up to the closing single quote
+func (l *shellLexer) readSingleQuoted(buf *strings.Builder) bool {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		l.pos++
+
+		switch {
+		case c == '\'':
+			return true
+		case c == '\\' && l.dialect == ShellFish && l.pos < len(l.input) && (l.input[l.pos] == '\'' || l.input[l.pos] == '\\'):
+			buf.WriteByte(l.input[l.pos])
+			l.pos++
+		default:
+			if c == '\n' {
+				l.line++
+			}
+			buf.WriteByte(c)
+		}
+	}
+
+	// if we get here, the quote was never closed
+	return false
+}
+
+// readDoubleQuoted adds everything up to the closing double quote
+func (l *shellLexer) readDoubleQuoted(buf *strings.Builder) bool {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		l.pos++
+
+		switch {
+		case c == '"':
+			return true
+		case c == '\\' && l.pos < len(l.input):
+			next := l.input[l.pos]
+			l.pos++
+			switch next {
+			case '"', '\\', '$', '`':
+				buf.WriteByte(next)
+			case '\n':
+				// line continuation
+				l.line++
+			default:
+				buf.WriteByte(c)
+				buf.WriteByte(next)
+			}
+		default:
+			if c == '\n' {
+				l.line++
+			}
+			buf.WriteByte(c)
+		}
+	}
+
+	// if we get here, the quote was never closed
+	return false
+}
+
+// readANSICQuoted adds everything up to the closing quote of a `$'...'`
+// string, decoding any escape sequences along the way
+func (l *shellLexer) readANSICQuoted(buf *strings.Builder) bool {
+	simpleEscapes := map[byte]byte{
+		'a': '\a', 'b': '\b', 'e': 0x1b, 'E': 0x1b, 'f': '\f', 'n': '\n',
+		'r': '\r', 't': '\t', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"',
+		'?': '?',
+	}
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		l.pos++
+
+		if c == '\'' {
+			return true
+		}
+		if c != '\\' || l.pos >= len(l.input) {
+			if c == '\n' {
+				l.line++
+			}
+			buf.WriteByte(c)
+			continue
+		}
+
+		// if we get here, we have an escape sequence
+		next := l.input[l.pos]
+		l.pos++
+		if escaped, ok := simpleEscapes[next]; ok {
+			buf.WriteByte(escaped)
+			continue
+		}
+
+		var value uint64
+		ok := false
+		switch next {
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			l.pos--
+			if value, ok = l.readNumber(8, 3); ok {
+				buf.WriteByte(byte(value))
+			}
+		case 'x':
+			if value, ok = l.readNumber(16, 2); ok {
+				buf.WriteByte(byte(value))
+			}
+		case 'u':
+			if value, ok = l.readNumber(16, 4); ok {
+				buf.WriteRune(rune(value))
+			}
+		case 'U':
+			if value, ok = l.readNumber(16, 8); ok {
+				buf.WriteRune(rune(value))
+			}
+		}
+
+		// anything else is not an escape sequence after all
+		if !ok {
+			buf.WriteByte(c)
+			buf.WriteByte(next)
+		}
+	}
+
+	// if we get here, the quote was never closed
+	return false
+}
+
+// readNumber reads up to maxDigits digits in the given base
+//
+// it returns false if there are no digits to read
+func (l *shellLexer) readNumber(base int, maxDigits int) (uint64, bool) {
+	start := l.pos
+	for l.pos < len(l.input) && l.pos-start < maxDigits {
+		_, err := strconv.ParseUint(l.input[l.pos:l.pos+1], base, 8)
+		if err != nil {
+			break
+		}
+		l.pos++
+	}
+
+	value, err := strconv.ParseUint(l.input[start:l.pos], base, 32)
+	return value, err == nil
+}
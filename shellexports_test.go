@@ -0,0 +1,486 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellDialectString(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := map[envish.ShellDialect]string{
+		envish.ShellSh:          "sh",
+		envish.ShellBash:        "bash",
+		envish.ShellZsh:         "zsh",
+		envish.ShellFish:        "fish",
+		envish.ShellDialect(99): "ShellDialect(99)",
+	}
+
+	for dialect, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult := dialect.String()
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedResult, actualResult)
+	}
+}
+
+func TestWriteShellExportsSingleQuotesEveryValue(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "it's $(rm -rf /) `and` \\ \"more\"\nline 2")
+	env.Setenv("PARAM3", "")
+
+	expectedResult := `export PARAM1='foo'
+export PARAM2='it'\''s $(rm -rf /) ` + "`and`" + ` \ "more"
+line 2'
+export PARAM3=''
+`
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteShellExports(&buf, env, envish.ShellBash)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, buf.String())
+}
+
+func TestWriteShellExportsSupportsFish(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "it's C:\\")
+
+	expectedResult := `set -gx PARAM1 'foo'
+set -gx PARAM2 'it\'s C:\\'
+`
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteShellExports(&buf, env, envish.ShellFish)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, buf.String())
+}
+
+func TestWriteShellExportsWritesFishPathVariablesAsLists(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("PATH", "/usr/local/bin:/usr/bin:it's")
+	env.Setenv("MANPATH", "")
+	env.Setenv("PARAM1", "a:b")
+
+	expectedResult := `set -gx PATH '/usr/local/bin' '/usr/bin' 'it\'s'
+set -gx MANPATH
+set -gx PARAM1 'a:b'
+`
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteShellExports(&buf, env, envish.ShellFish)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, buf.String())
+}
+
+func TestWriteShellExportsReturnsErrorForKeysThatCannotBeExported(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("$#", "2")
+	expectedError := envish.ErrShellKey{"$#"}
+
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteShellExports(&buf, env, envish.ShellSh)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestWriteShellExportsReturnsErrorForUnknownDialect(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	expectedError := envish.ErrUnsupportedShellDialect{envish.ShellDialect(99)}
+
+	var buf bytes.Buffer
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.WriteShellExports(&buf, env, envish.ShellDialect(99))
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestLoadShellExportsParsesBashDeclareOutput(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := `declare -x HOME="/home/stuart"
+declare -x NOVALUE
+declare -rx QUOTED="it's \"q\" \$x \\"
+declare -x MULTILINE=$'a\nb\tc\x41\101\u00e9\q'
+declare -x SPANS="line 1
+line 2"
+`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadShellExports(strings.NewReader(testData), envish.ShellBash)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/stuart", env.Getenv("HOME"))
+	assert.Equal(t, `it's "q" $x \`, env.Getenv("QUOTED"))
+	assert.Equal(t, "a\nb\tcAAé\\q", env.Getenv("MULTILINE"))
+	assert.Equal(t, "line 1\nline 2", env.Getenv("SPANS"))
+
+	_, ok := env.LookupEnv("NOVALUE")
+	assert.False(t, ok)
+}
+
+func TestLoadShellExportsParsesShExportOutput(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := `export BAR='it'"'"'s'
+export FOO='a
+b'
+export PARAM1=plain PARAM2='two'; export PARAM3=three
+`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadShellExports(strings.NewReader(testData), envish.ShellSh)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "it's", env.Getenv("BAR"))
+	assert.Equal(t, "a\nb", env.Getenv("FOO"))
+	assert.Equal(t, "plain", env.Getenv("PARAM1"))
+	assert.Equal(t, "two", env.Getenv("PARAM2"))
+	assert.Equal(t, "three", env.Getenv("PARAM3"))
+}
+
+func TestLoadShellExportsParsesFishSetCommands(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := `set -gx PARAM1 'it\'s C:\\'
+set -x PARAM2 a b c
+`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadShellExports(strings.NewReader(testData), envish.ShellFish)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, `it's C:\`, env.Getenv("PARAM1"))
+	assert.Equal(t, "a b c", env.Getenv("PARAM2"))
+}
+
+func TestLoadShellExportsJoinsFishPathVariablesWithColons(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := `set -gx PATH /usr/local/bin '/usr/bin'
+set -gx MANPATH
+`
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadShellExports(strings.NewReader(testData), envish.ShellFish)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/usr/local/bin:/usr/bin", env.Getenv("PATH"))
+	value, ok := env.LookupEnv("MANPATH")
+	assert.True(t, ok)
+	assert.Equal(t, "", value)
+}
+
+func TestLoadShellExportsNeverExpandsValues(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := "export PARAM1=$HOME PARAM2=\"$(whoami)\" PARAM3=`id`\n"
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env, err := envish.LoadShellExports(strings.NewReader(testData), envish.ShellBash)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "$HOME", env.Getenv("PARAM1"))
+	assert.Equal(t, "$(whoami)", env.Getenv("PARAM2"))
+	assert.Equal(t, "`id`", env.Getenv("PARAM3"))
+}
+
+func TestLoadShellExportsReturnsLineNumberedSyntaxErrors(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	testData := map[string]envish.ErrShellSyntax{
+		"export A=1\nrm -rf /\n":          {Line: 2, Reason: "unsupported command 'rm'"},
+		"export A=1\n\nexport 1A=2\n":     {Line: 3, Reason: "invalid variable name '1A'"},
+		"export A=1\nexport B='2\n\n":     {Line: 2, Reason: "missing closing quote"},
+		"export A=\"1\nexport B=2\n":      {Line: 1, Reason: "missing closing quote"},
+		"export A=$'1\n":                  {Line: 1, Reason: "missing closing quote"},
+		"export A='1\nb' C=2\nexport 1=2": {Line: 3, Reason: "invalid variable name '1'"},
+	}
+
+	for input, expectedError := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		_, err := envish.LoadShellExports(strings.NewReader(input), envish.ShellBash)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedError, err, input)
+	}
+}
+
+func TestReadShellExportsReturnsLineNumberedSetenvErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, dialect := range []envish.ShellDialect{envish.ShellBash, envish.ShellFish} {
+		// ----------------------------------------------------------------
+		// setup your test
+
+		env := envish.NewLocalEnv()
+		env.Setenv("PARAM2", "foo")
+		env.SetReadOnly("PARAM2")
+
+		testData := "export PARAM1=one\n\nexport PARAM2=two\n"
+		if dialect == envish.ShellFish {
+			testData = "set -gx PARAM1 one\n\nset -gx PARAM2 two\n"
+		}
+		expectedError := envish.ErrShellSetenv{Line: 3, Err: envish.ErrReadOnlyVar{Key: "PARAM2"}}
+
+		// ----------------------------------------------------------------
+		// perform the change
+
+		err := envish.ReadShellExports(env, strings.NewReader(testData), dialect)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, expectedError, err, dialect.String())
+		assert.True(t, errors.Is(err, envish.ErrReadOnlyVar{Key: "PARAM2"}), dialect.String())
+		assert.Equal(t, "one", env.Getenv("PARAM1"), dialect.String())
+		assert.Equal(t, "foo", env.Getenv("PARAM2"), dialect.String())
+	}
+}
+
+func TestReadShellExportsCopesWithNilWriter(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	expectedError := envish.ErrNilPointer{"ReadShellExports"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := envish.ReadShellExports(nil, strings.NewReader(""), envish.ShellSh)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestWriteShellExportsOutputRoundTripsThroughLoadShellExports(t *testing.T) {
+	t.Parallel()
+
+	for _, dialect := range []envish.ShellDialect{envish.ShellSh, envish.ShellBash, envish.ShellZsh, envish.ShellFish} {
+		// ----------------------------------------------------------------
+		// setup your test
+
+		env := envish.NewLocalEnv()
+		env.Setenv("PARAM1", "it's $HOME and `id` and \\ and \"quotes\"")
+		env.Setenv("PARAM2", "line 1\nline 2\n")
+		env.Setenv("PARAM3", "")
+		env.Setenv("PATH", "/usr/bin::it's")
+
+		var buf bytes.Buffer
+
+		// ----------------------------------------------------------------
+		// perform the change
+
+		err := envish.WriteShellExports(&buf, env, dialect)
+		assert.Nil(t, err)
+		actualResult, err := envish.LoadShellExports(&buf, dialect)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Nil(t, err)
+		assert.Equal(t, env.Environ(), actualResult.Environ(), dialect.String())
+	}
+}
+
+func TestWriteShellExportsOutputRoundTripsThroughARealShell(t *testing.T) {
+	t.Parallel()
+
+	for _, dialect := range []envish.ShellDialect{envish.ShellSh, envish.ShellBash} {
+		// ----------------------------------------------------------------
+		// setup your test
+
+		shell, err := exec.LookPath(dialect.String())
+		if err != nil {
+			t.Logf("skipping %s: not installed", dialect)
+			continue
+		}
+
+		env := envish.NewLocalEnv()
+		env.Setenv("ENVISH_PARAM1", "it's $HOME and `id` and \\ and \"quotes\"")
+		env.Setenv("ENVISH_PARAM2", "line 1\nline 2")
+
+		var script bytes.Buffer
+		err = envish.WriteShellExports(&script, env, dialect)
+		assert.Nil(t, err)
+		script.WriteString("export -p\n")
+
+		// ----------------------------------------------------------------
+		// perform the change
+
+		cmd := exec.Command(shell, "-s")
+		cmd.Env = []string{}
+		cmd.Stdin = &script
+		output, err := cmd.Output()
+		assert.Nil(t, err)
+		actualResult, err := envish.LoadShellExports(bytes.NewReader(output), dialect)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Nil(t, err)
+		for _, key := range []string{"ENVISH_PARAM1", "ENVISH_PARAM2"} {
+			assert.Equal(t, env.Getenv(key), actualResult.Getenv(key), dialect.String())
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ShellParams holds the positional parameters ($1, $2 ...) and special
+// parameters ($#, $@, $*, $? and $$) of an emulated UNIX shell script.
+//
+// It is an Expander, so that you can add it to an OverlayEnv underneath
+// your script's variables:
+//
+//	params := envish.NewShellParams("foo", "bar")
+//	env := envish.NewOverlayEnv([]envish.Expander{
+//		envish.NewLocalEnv(),
+//		params,
+//	})
+//	env.Expand("$1 and $#") // "foo and 2"
+//
+// ShellParams is read-only through the Writer interface. Use SetArgs,
+// Shift, SetExitStatus and SetPID to change it instead.
+type ShellParams struct {
+	// args are the positional parameters; args[0] is $1
+	args []string
+
+	// exitStatus is the value of $?
+	exitStatus int
+
+	// pid is the value of $$
+	pid int
+}
+
+// ================================================================
+//
+// Constructors
+//
+// ----------------------------------------------------------------
+
+// NewShellParams creates a ShellParams that holds the given positional
+// parameters.
+//
+// $? starts at 0, and $$ starts as the process ID of your program.
+func NewShellParams(args ...string) *ShellParams {
+	retval := ShellParams{
+		pid: os.Getpid(),
+	}
+	retval.SetArgs(args...)
+
+	// all done
+	return &retval
+}
+
+// ================================================================
+//
+// Reader interface
+//
+// ----------------------------------------------------------------
+
+// Environ always returns an empty list. Positional and special
+// parameters are never exported to external programs.
+func (e *ShellParams) Environ() []string {
+	return []string{}
+}
+
+// Getenv returns the value of the parameter named by the key.
+//
+// If the key is not found, an empty string is returned.
+func (e *ShellParams) Getenv(key string) string {
+	value, _ := e.LookupEnv(key)
+	return value
+}
+
+// IsExporter always returns `false`.
+//
+// It is used by OverlayEnv.Environ to work out which keys and values
+// the OverlayEnv should include in its output.
+func (e *ShellParams) IsExporter() bool {
+	return false
+}
+
+// LookupEnv returns the value of the parameter named by the key.
+//
+// The key can be written with or without its leading '$' (ie, both
+// "1" and "$1" return the first positional parameter). String expansion
+// always uses the "$1" form.
+//
+// If the key is not found, an empty string is returned, and the returned
+// boolean is false.
+func (e *ShellParams) LookupEnv(key string) (string, bool) {
+	// do we have a ShellParams to work with?
+	if e == nil {
+		return "", false
+	}
+
+	// yes we do
+	//
+	// shellexpand asks for "$1", "$#" and so on
+	name := key
+	if len(key) > 1 && key[0] == '$' {
+		name = key[1:]
+	}
+
+	switch name {
+	case "#":
+		return strconv.Itoa(len(e.args)), true
+	case "@", "*":
+		return strings.Join(e.args, " "), true
+	case "?":
+		return strconv.Itoa(e.exitStatus), true
+	case "$":
+		return strconv.Itoa(e.pid), true
+	}
+
+	// is it a positional parameter?
+	i, ok := e.argIndex(name)
+	if !ok {
+		return "", false
+	}
+
+	return e.args[i], true
+}
+
+// MatchVarNames always returns an empty list. Positional and special
+// parameters do not have names that `${!prefix*}` can match.
+func (e *ShellParams) MatchVarNames(prefix string) []string {
+	return []string{}
+}
+
+// ================================================================
+//
+// Writer interface
+//
+// ----------------------------------------------------------------
+
+// Clearenv does nothing. Use SetArgs to remove the positional parameters.
+func (e *ShellParams) Clearenv() {
+	// do nothing
+}
+
+// Setenv always returns ErrReadOnlyVar. Use SetArgs, SetExitStatus and
+// SetPID instead.
+func (e *ShellParams) Setenv(key, value string) error {
+	return ErrReadOnlyVar{Key: key}
+}
+
+// Unsetenv does nothing. Use Shift or SetArgs to remove positional
+// parameters.
+func (e *ShellParams) Unsetenv(key string) {
+	// do nothing
+}
+
+// ================================================================
+//
+// Expander interface
+//
+// ----------------------------------------------------------------
+
+// Expand replaces $1, $# and friends in the input string.
+//
+// Internally, it uses https://github.com/ganbarodigital/go_shellexpand
+// to do the shell expansion. It supports the vast majority of UNIX shell
+// string expansion operations.
+func (e *ShellParams) Expand(fmt string) string {
+	return expand(e, fmt)
+}
+
+// ExpandE replaces $1, $# and friends in the input string. It returns
+// an error if the input string cannot be expanded.
+func (e *ShellParams) ExpandE(fmt string) (string, error) {
+	return expandE(e, fmt)
+}
+
+// ExpandWith works like ExpandE. Use the options to choose what
+// counts as an error.
+func (e *ShellParams) ExpandWith(fmt string, options ExpandOptions) (string, error) {
+	return expandWith(e, fmt, options)
+}
+
+// ================================================================
+//
+// ReadOnlyChecker interface
+//
+// ----------------------------------------------------------------
+
+// IsReadOnly returns true if the key names a parameter that this
+// ShellParams currently holds.
+//
+// This stops an OverlayEnv from trying to change them.
+func (e *ShellParams) IsReadOnly(key string) bool {
+	_, ok := e.LookupEnv(key)
+	return ok
+}
+
+// ================================================================
+//
+// Struct-unique functions
+//
+// ----------------------------------------------------------------
+
+// Args returns a copy of the positional parameters.
+func (e *ShellParams) Args() []string {
+	// do we have a ShellParams to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	retval := make([]string, len(e.args))
+	copy(retval, e.args)
+	return retval
+}
+
+// ExitStatus returns the value of $?
+func (e *ShellParams) ExitStatus() int {
+	// do we have a ShellParams to work with?
+	if e == nil {
+		return 0
+	}
+
+	// yes we do
+	return e.exitStatus
+}
+
+// PID returns the value of $$
+func (e *ShellParams) PID() int {
+	// do we have a ShellParams to work with?
+	if e == nil {
+		return 0
+	}
+
+	// yes we do
+	return e.pid
+}
+
+// SetArgs replaces all of the positional parameters, just like the UNIX
+// shell `set -- arg1 arg2 ...` command.
+func (e *ShellParams) SetArgs(args ...string) {
+	// do we have a ShellParams to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.args = make([]string, len(args))
+	copy(e.args, args)
+}
+
+// SetExitStatus sets the value of $?
+func (e *ShellParams) SetExitStatus(status int) {
+	// do we have a ShellParams to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.exitStatus = status
+}
+
+// SetPID sets the value of $$
+func (e *ShellParams) SetPID(pid int) {
+	// do we have a ShellParams to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.pid = pid
+}
+
+// Shift emulates the UNIX shell `shift n` command. It removes the first
+// n positional parameters, and renumbers the rest.
+//
+// It returns ErrShiftOutOfRange if n is negative, or if n is greater
+// than $#. The positional parameters are left alone when that happens.
+func (e *ShellParams) Shift(n int) error {
+	// do we have a ShellParams to work with?
+	if e == nil {
+		return ErrNilPointer{"ShellParams.Shift"}
+	}
+
+	// can we shift that many?
+	if n < 0 || n > len(e.args) {
+		return ErrShiftOutOfRange{Count: n, NumArgs: len(e.args)}
+	}
+
+	// yes we can
+	e.args = e.args[n:]
+
+	// all done
+	return nil
+}
+
+// argIndex converts the name of a positional parameter into an index
+// into our args
+func (e *ShellParams) argIndex(name string) (int, bool) {
+	// positional params never have a leading zero
+	if len(name) == 0 || name[0] < '1' || name[0] > '9' {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(name)
+	if err != nil || n > len(e.args) {
+		return 0, false
+	}
+
+	return n - 1, true
+}
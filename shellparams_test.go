@@ -0,0 +1,414 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// Constructors
+//
+// ----------------------------------------------------------------
+
+func TestNewShellParamsSetsDefaults(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit := envish.NewShellParams("foo", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"foo", "bar"}, unit.Args())
+	assert.Equal(t, 0, unit.ExitStatus())
+	assert.Equal(t, os.Getpid(), unit.PID())
+}
+
+// ================================================================
+//
+// Lookups
+//
+// ----------------------------------------------------------------
+
+func TestShellParamsLookupEnvAcceptsKeysWithAndWithoutDollar(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewShellParams("foo", "bar", "baz")
+	unit.SetExitStatus(3)
+	unit.SetPID(1234)
+
+	testData := map[string]string{
+		"1": "foo",
+		"2": "bar",
+		"3": "baz",
+		"#": "3",
+		"@": "foo bar baz",
+		"*": "foo bar baz",
+		"?": "3",
+		"$": "1234",
+	}
+
+	for key, expectedValue := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualValue1, ok1 := unit.LookupEnv(key)
+		actualValue2, ok2 := unit.LookupEnv("$" + key)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.True(t, ok1, key)
+		assert.Equal(t, expectedValue, actualValue1, key)
+		assert.True(t, ok2, "$"+key)
+		assert.Equal(t, expectedValue, actualValue2, "$"+key)
+	}
+}
+
+func TestShellParamsLookupEnvReturnsFalseForUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewShellParams("foo")
+
+	for _, key := range []string{"", "0", "01", "2", "$2", "PARAM1", "!"} {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualValue, ok := unit.LookupEnv(key)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.False(t, ok, key)
+		assert.Equal(t, "", actualValue, key)
+	}
+}
+
+func TestShellParamsSupportsMoreThanNineArgs(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	args := []string{}
+	for i := 1; i <= 11; i++ {
+		args = append(args, "arg"+strconv.Itoa(i))
+	}
+	unit := envish.NewShellParams(args...)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := unit.Expand("${10} ${11} $10")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "arg10 arg11 arg10", actualResult)
+}
+
+func TestShellParamsIsNeverExported(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewShellParams("foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualEnviron := unit.Environ()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{}, actualEnviron)
+	assert.Equal(t, []string{}, unit.MatchVarNames(""))
+	assert.False(t, unit.IsExporter())
+}
+
+// ================================================================
+//
+// Writes
+//
+// ----------------------------------------------------------------
+
+func TestShellParamsSetenvReturnsErrReadOnlyVar(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewShellParams("foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.Setenv("1", "bar")
+	unit.Unsetenv("1")
+	unit.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "1"}, err)
+	assert.Equal(t, "foo", unit.Getenv("1"))
+}
+
+func TestShellParamsSetArgsReplacesAllArgs(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewShellParams("foo", "bar", "baz")
+	args := []string{"one"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.SetArgs(args...)
+	args[0] = "changed"
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"one"}, unit.Args())
+	assert.Equal(t, "1", unit.Getenv("#"))
+	_, ok := unit.LookupEnv("2")
+	assert.False(t, ok)
+}
+
+func TestShellParamsShiftRenumbersArgs(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewShellParams("foo", "bar", "baz")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := unit.Shift(2)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "baz", unit.Getenv("1"))
+	assert.Equal(t, "1", unit.Getenv("#"))
+}
+
+func TestShellParamsShiftReturnsErrorWhenOutOfRange(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewShellParams("foo", "bar")
+
+	for _, n := range []int{-1, 3} {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		err := unit.Shift(n)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Equal(t, envish.ErrShiftOutOfRange{Count: n, NumArgs: 2}, err)
+		assert.Equal(t, []string{"foo", "bar"}, unit.Args())
+	}
+}
+
+// ================================================================
+//
+// Expansion
+//
+// ----------------------------------------------------------------
+
+func TestShellParamsCanBeStackedInAnOverlayEnv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	params := envish.NewShellParams("foo", "bar")
+	params.SetExitStatus(1)
+
+	localVars := envish.NewLocalEnv()
+	localVars.Setenv("PARAM1", "hello")
+
+	env := envish.NewOverlayEnv([]envish.Expander{localVars, params})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult, err := env.ExpandE("$PARAM1 $1 and $# args $@ status $? ${#1}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hello foo and 2 args foo bar status 1 3", actualResult)
+}
+
+func TestShellParamsExpandsBareDollarDollar(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	params := envish.NewShellParams("foo")
+	params.SetPID(1234)
+
+	env := envish.NewOverlayEnv([]envish.Expander{envish.NewLocalEnv(), params})
+
+	testData := map[string]string{
+		"$$":           "1234",
+		"pid $$ end":   "pid 1234 end",
+		"$$$$":         "12341234",
+		"$$-$1":        "1234-foo",
+		"${$}":         "1234",
+		"/tmp/lock.$$": "/tmp/lock.1234",
+	}
+
+	for input, expectedResult := range testData {
+		// ----------------------------------------------------------------
+		// perform the change
+
+		actualResult, err := env.ExpandE(input)
+
+		// ----------------------------------------------------------------
+		// test the results
+
+		assert.Nil(t, err, input)
+		assert.Equal(t, expectedResult, actualResult, input)
+		assert.Equal(t, expectedResult, params.Expand(input), input)
+	}
+}
+
+func TestShellParamsCannotBeChangedThroughAnOverlayEnv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	params := envish.NewShellParams("foo")
+	env := envish.NewOverlayEnv([]envish.Expander{envish.NewLocalEnv(), params})
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Setenv("$1", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "$1"}, err)
+	assert.Equal(t, "foo", env.Getenv("$1"))
+}
+
+func TestShellParamsWorksWithNoUnset(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewShellParams("foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, err := unit.ExpandWith("$1 $2", envish.ExpandOptions{NoUnset: true})
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrUnboundVariables{Names: []string{"2"}}, err)
+}
+
+// ================================================================
+//
+// Nil pointers
+//
+// ----------------------------------------------------------------
+
+func TestShellParamsNilPointerIsSafe(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var unit *envish.ShellParams
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	unit.SetArgs("foo")
+	unit.SetExitStatus(1)
+	unit.SetPID(1)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{}, unit.Args())
+	assert.Equal(t, 0, unit.ExitStatus())
+	assert.Equal(t, 0, unit.PID())
+	assert.Equal(t, "", unit.Getenv("#"))
+	assert.False(t, unit.IsReadOnly("1"))
+	assert.Equal(t, envish.ErrNilPointer{Method: "ShellParams.Shift"}, unit.Shift(1))
+}
@@ -0,0 +1,418 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"strings"
+)
+
+// Snapshot is a copy of an environment, taken at a moment in time.
+//
+// Use TakeSnapshot to create one, and Snapshot.Restore to put the
+// environment (or any other environment) back into the same state.
+//
+// Snapshot implements the Reader interface, so you can also use it as a
+// read-only copy of the environment.
+//
+// A snapshot of an OverlayEnv also holds a separate snapshot of each
+// environment inside the OverlayEnv, so that Restore can put every
+// variable back into the right environment.
+type Snapshot struct {
+	// pairs holds every variable, exported or not, in the order that
+	// the environment gave them to us
+	pairs []string
+
+	// values is a lookup table into pairs
+	values map[string]string
+
+	// exports holds the export attribute of every variable in pairs
+	exports map[string]bool
+
+	// inEnviron holds whether the environment's Environ included each
+	// variable in pairs
+	inEnviron map[string]bool
+
+	// trackExports is true if the environment tracks exports per
+	// variable
+	trackExports bool
+
+	// isExporter is what the environment's IsExporter returned
+	isExporter bool
+
+	// arrays holds a copy of any array variables, if the environment
+	// supports them
+	arrays map[string]arrayVar
+
+	// layers holds a snapshot of each environment inside an OverlayEnv
+	layers []*Snapshot
+
+	// meta holds a copy of the OverlayEnv's layer names and whiteouts
+	meta []layerMeta
+}
+
+// arraySnapshotter is the interface that wraps environments that let
+// a Snapshot copy and put back their array variables
+type arraySnapshotter interface {
+	// copyArrays returns a copy of every array variable
+	copyArrays() map[string]arrayVar
+
+	// restoreArrays replaces every array variable with the given arrays.
+	// Read-only arrays are left alone.
+	restoreArrays(arrays map[string]arrayVar)
+}
+
+// ================================================================
+//
+// Constructors
+//
+// ----------------------------------------------------------------
+
+// TakeSnapshot makes a copy of every variable in the given environment.
+//
+// If the environment implements the ExportChecker interface, it also
+// remembers which variables were exported. Array variables (such as those
+// in a LocalEnv) are copied too.
+//
+// If the environment is an OverlayEnv, it also takes a snapshot of each
+// environment inside the OverlayEnv, and copies the OverlayEnv's
+// whiteouts.
+func TakeSnapshot(r Reader) *Snapshot {
+	retval := Snapshot{
+		values:    make(map[string]string),
+		exports:   make(map[string]bool),
+		inEnviron: make(map[string]bool),
+	}
+
+	// do we have an environment to copy?
+	if r == nil {
+		return &retval
+	}
+
+	// yes we do
+	retval.isExporter = r.IsExporter()
+	checker, ok := r.(ExportChecker)
+	retval.trackExports = ok
+
+	for _, pair := range allVarsOf(r) {
+		key := GetKeyFromPair(pair)
+		retval.pairs = append(retval.pairs, pair)
+		retval.values[key] = GetValueFromPair(pair, key)
+
+		if ok {
+			retval.exports[key] = checker.IsExported(key)
+		} else {
+			retval.exports[key] = retval.isExporter
+		}
+		retval.inEnviron[key] = isInEnvironOf(r, key)
+	}
+
+	// do we have arrays to copy?
+	arrays, ok := r.(arraySnapshotter)
+	if ok {
+		retval.arrays = arrays.copyArrays()
+	}
+
+	// do we need to copy each layer too?
+	overlay, ok := r.(*OverlayEnv)
+	if ok && overlay != nil {
+		retval.meta = overlay.copyMeta()
+		for _, env := range overlay.envs {
+			retval.layers = append(retval.layers, TakeSnapshot(env))
+		}
+	}
+
+	// all done
+	return &retval
+}
+
+// ================================================================
+//
+// Reader interface
+//
+// ----------------------------------------------------------------
+
+// Environ returns a copy of the variables that the environment's Environ
+// returned when the snapshot was taken, in the form "key=value".
+func (s *Snapshot) Environ() []string {
+	// our return value
+	retval := []string{}
+
+	// do we have a snapshot to work with?
+	if s == nil {
+		return retval
+	}
+
+	// yes we do
+	for _, pair := range s.pairs {
+		if s.inEnviron[GetKeyFromPair(pair)] {
+			retval = append(retval, pair)
+		}
+	}
+
+	// all done
+	return retval
+}
+
+// Getenv returns the value of the variable named by the key, at the time
+// the snapshot was taken.
+//
+// If the key is not found, an empty string is returned.
+func (s *Snapshot) Getenv(key string) string {
+	value, _ := s.LookupEnv(key)
+	return value
+}
+
+// IsExporter returns whatever the environment's IsExporter method returned
+// when the snapshot was taken.
+func (s *Snapshot) IsExporter() bool {
+	// do we have a snapshot to work with?
+	if s == nil {
+		return false
+	}
+
+	// yes we do
+	return s.isExporter
+}
+
+// LookupEnv returns the value of the variable named by the key, at the
+// time the snapshot was taken.
+//
+// If the key is not found, an empty string is returned, and the returned
+// boolean is false.
+func (s *Snapshot) LookupEnv(key string) (string, bool) {
+	// do we have a snapshot to work with?
+	if s == nil {
+		return "", false
+	}
+
+	// yes we do
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// MatchVarNames returns a list of variable names that start with the
+// given prefix.
+func (s *Snapshot) MatchVarNames(prefix string) []string {
+	// our return value
+	retval := []string{}
+
+	// do we have a snapshot to work with?
+	if s == nil {
+		return retval
+	}
+
+	// yes we do
+	for _, pair := range s.pairs {
+		if strings.HasPrefix(pair, prefix) {
+			retval = append(retval, GetKeyFromPair(pair))
+		}
+	}
+
+	// all done
+	return retval
+}
+
+// ================================================================
+//
+// ExportChecker interface
+//
+// ----------------------------------------------------------------
+
+// AllVars returns a copy of all the variables in the snapshot, in the
+// form "key=value", whether they were exported or not.
+func (s *Snapshot) AllVars() []string {
+	// do we have a snapshot to work with?
+	if s == nil {
+		return []string{}
+	}
+
+	// yes we do
+	retval := make([]string, len(s.pairs))
+	copy(retval, s.pairs)
+	return retval
+}
+
+// IsExported returns true if the variable named by the key was exported
+// when the snapshot was taken.
+func (s *Snapshot) IsExported(key string) bool {
+	// do we have a snapshot to work with?
+	if s == nil {
+		return false
+	}
+
+	// yes we do
+	exported, ok := s.exports[key]
+	if ok {
+		return exported
+	}
+
+	return s.isExporter
+}
+
+// ================================================================
+//
+// Struct-unique functions
+//
+// ----------------------------------------------------------------
+
+// Restore puts the given environment back into the state that the
+// snapshot recorded:
+//
+// * variables that are not in the snapshot are deleted
+//
+// * variables that have a different value are changed back
+//
+// * variables that have been deleted are put back
+//
+// If the snapshot tracked exports, and the given environment supports
+// exporting individual variables (like LocalEnv does), the export
+// attribute of each variable is put back too. So are any array variables,
+// if both the snapshot and the given environment support them.
+//
+// If the snapshot was taken from an OverlayEnv, and you restore it into
+// an OverlayEnv that holds the same number of environments, each
+// environment is restored from its own snapshot, and the OverlayEnv's
+// whiteouts are put back too. Otherwise, the OverlayEnv is treated like
+// any other environment, and variables are put back using its Setenv and
+// Unsetenv methods.
+//
+// Restore stops, and returns the error, if any variable cannot be set.
+// Read-only variables that have been added since the snapshot was taken
+// cannot be deleted.
+func (s *Snapshot) Restore(w ReaderWriter) error {
+	// do we have a snapshot to work with?
+	if s == nil {
+		return ErrNilPointer{"Snapshot.Restore"}
+	}
+
+	// do we have an environment to restore?
+	if w == nil {
+		return ErrNilPointer{"Snapshot.Restore"}
+	}
+
+	// can we restore it one layer at a time?
+	overlay, ok := w.(*OverlayEnv)
+	if ok && s.layers != nil && len(overlay.envs) == len(s.layers) {
+		return s.restoreLayers(overlay)
+	}
+
+	// do we have arrays to put back?
+	arrays, ok := w.(arraySnapshotter)
+	if ok && s.arrays != nil {
+		arrays.restoreArrays(s.arrays)
+	}
+
+	// get rid of anything that has been added since
+	for _, pair := range allVarsOf(w) {
+		key := GetKeyFromPair(pair)
+		_, ok := s.values[key]
+		if !ok {
+			w.Unsetenv(key)
+		}
+	}
+
+	// put back anything that has been changed or deleted
+	for _, pair := range s.pairs {
+		key := GetKeyFromPair(pair)
+		value := s.values[key]
+
+		current, ok := w.LookupEnv(key)
+		if ok && current == value {
+			continue
+		}
+
+		err := w.Setenv(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	// put back the export attributes, if we can
+	exporter, ok := w.(varExporter)
+	unexporter, ok2 := w.(varUnexporter)
+	if !s.trackExports || !ok || !ok2 {
+		return nil
+	}
+
+	for key, exported := range s.exports {
+		var err error
+		if exported {
+			err = exporter.Export(key)
+		} else {
+			err = unexporter.Unexport(key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// all done
+	return nil
+}
+
+// ================================================================
+//
+// Internal helpers
+//
+// ----------------------------------------------------------------
+
+// restoreLayers puts each environment inside the OverlayEnv back into
+// the state that its own snapshot recorded
+func (s *Snapshot) restoreLayers(overlay *OverlayEnv) error {
+	for i, layer := range s.layers {
+		err := layer.Restore(overlay.envs[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	// put back the whiteouts too
+	overlay.meta = copyLayerMeta(s.meta)
+
+	// all done
+	return nil
+}
+
+// allVarsOf returns every variable in the given environment, whether
+// it has been exported or not
+func allVarsOf(r Reader) []string {
+	checker, ok := r.(ExportChecker)
+	if ok {
+		return checker.AllVars()
+	}
+
+	return r.Environ()
+}
@@ -0,0 +1,395 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"os"
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// TakeSnapshot
+//
+// ----------------------------------------------------------------
+
+func TestTakeSnapshotCopiesEveryVariable(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+	env.Export("PARAM2")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	snap := envish.TakeSnapshot(env)
+	env.Setenv("PARAM1", "changed")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM1=foo", "PARAM2=bar"}, snap.AllVars())
+	assert.Equal(t, []string{"PARAM1=foo", "PARAM2=bar"}, snap.Environ())
+	assert.Equal(t, "foo", snap.Getenv("PARAM1"))
+	assert.False(t, snap.IsExported("PARAM1"))
+	assert.True(t, snap.IsExported("PARAM2"))
+	assert.Equal(t, []string{"PARAM1", "PARAM2"}, snap.MatchVarNames("PARAM"))
+	assert.False(t, snap.IsExporter())
+}
+
+func TestTakeSnapshotCopesWithNilReader(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	snap := envish.TakeSnapshot(nil)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Empty(t, snap.AllVars())
+	_, ok := snap.LookupEnv("PARAM1")
+	assert.False(t, ok)
+}
+
+// ================================================================
+//
+// Snapshot.Restore
+//
+// ----------------------------------------------------------------
+
+func TestSnapshotRestorePutsLocalEnvBackExactly(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+	env.Setenv("PARAM3", "baz")
+	env.Export("PARAM3")
+
+	snap := envish.TakeSnapshot(env)
+
+	env.Setenv("PARAM1", "changed")
+	env.Unsetenv("PARAM2")
+	env.Unexport("PARAM3")
+	env.Setenv("PARAM4", "added")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := snap.Restore(env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"PARAM1=foo", "PARAM2=bar", "PARAM3=baz"}, env.AllVars())
+	assert.Equal(t, []string{"PARAM3=baz"}, env.ExportedVars())
+}
+
+func TestSnapshotRestorePutsProgramEnvBackExactly(t *testing.T) {
+	// ----------------------------------------------------------------
+	// setup your test
+
+	os.Setenv("TestSnapshotRestore1", "foo")
+	os.Setenv("TestSnapshotRestore2", "bar")
+	defer os.Unsetenv("TestSnapshotRestore1")
+	defer os.Unsetenv("TestSnapshotRestore2")
+
+	env := envish.NewProgramEnv()
+	expectedResult := os.Environ()
+	snap := envish.TakeSnapshot(env)
+
+	env.Setenv("TestSnapshotRestore1", "changed")
+	env.Unsetenv("TestSnapshotRestore2")
+	env.Setenv("TestSnapshotRestore3", "added")
+	defer os.Unsetenv("TestSnapshotRestore3")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := snap.Restore(env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, expectedResult, os.Environ())
+	_, ok := os.LookupEnv("TestSnapshotRestore3")
+	assert.False(t, ok)
+}
+
+func TestSnapshotRestorePutsOverlayEnvBackExactly(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "foo")
+	env2 := envish.NewLocalEnv(envish.SetAsExporter)
+	env2.Setenv("PARAM2", "bar")
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	expectedResult := stack.AllVars()
+	snap := envish.TakeSnapshot(stack)
+
+	stack.Setenv("PARAM2", "changed")
+	stack.Unsetenv("PARAM1")
+	stack.Setenv("PARAM3", "added")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := snap.Restore(stack)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, stack.AllVars())
+	assert.Equal(t, "bar", env2.Getenv("PARAM2"))
+}
+
+func TestSnapshotRestorePutsArraysBack(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR1", "a", "b", "c")
+	env.UnsetElement("ARR1", "1")
+	env.SetAssoc("ARR2", map[string]string{"x": "1"})
+	env.Setenv("PARAM1", "foo")
+
+	snap := envish.TakeSnapshot(env)
+
+	env.SetElement("ARR1", "0", "changed")
+	env.Unsetenv("ARR2")
+	env.SetArray("ARR3", "added")
+	env.SetArray("PARAM1", "now an array")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := snap.Restore(env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	arr1, _ := env.GetAssoc("ARR1")
+	assert.Equal(t, map[string]string{"0": "a", "2": "c"}, arr1)
+	arr2, _ := env.GetAssoc("ARR2")
+	assert.Equal(t, map[string]string{"x": "1"}, arr2)
+	assert.False(t, env.IsArray("ARR3"))
+	assert.False(t, env.IsArray("PARAM1"))
+	assert.Equal(t, []string{"PARAM1=foo"}, env.AllVars())
+}
+
+func TestSnapshotRestorePutsEachOverlayEnvLayerBack(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "foo")
+	env1.Setenv("PARAM2", "bar")
+	env2 := envish.NewLocalEnv()
+	env2.Setenv("PARAM1", "shadowed")
+	env2.Setenv("PARAM3", "baz")
+	env2.Export("PARAM3")
+	env2.SetArray("ARR1", "a", "b")
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	snap := envish.TakeSnapshot(stack)
+
+	stack.Unsetenv("PARAM1")
+	env2.Unexport("PARAM3")
+	env1.Export("PARAM2")
+	env2.SetElement("ARR1", "0", "changed")
+	env1.SetArray("ARR2", "added")
+	stack.SetUnsetMode(envish.UnsetWithWhiteout)
+	stack.Unsetenv("PARAM3")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := snap.Restore(stack)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"PARAM1=foo", "PARAM2=bar"}, env1.AllVars())
+	assert.Empty(t, env1.ExportedVars())
+	assert.False(t, env1.IsArray("ARR2"))
+	assert.ElementsMatch(t, []string{"PARAM1=shadowed", "PARAM3=baz"}, env2.AllVars())
+	assert.Equal(t, []string{"PARAM3=baz"}, env2.ExportedVars())
+	arr1, _ := env2.GetArray("ARR1")
+	assert.Equal(t, []string{"a", "b"}, arr1)
+	assert.Equal(t, "baz", stack.Getenv("PARAM3"))
+}
+
+func TestSnapshotRestoreUsesSetenvIfOverlayEnvLayersHaveChanged(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	env2.Setenv("PARAM1", "foo")
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	snap := envish.TakeSnapshot(stack)
+
+	// once the layers have changed, the snapshot of each layer no longer
+	// lines up with the OverlayEnv
+	env3 := envish.NewLocalEnv()
+	stack.PushEnv(env3)
+	stack.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := snap.Restore(stack)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", stack.Getenv("PARAM1"))
+	assert.Equal(t, []string{"PARAM1=foo"}, env3.AllVars())
+	assert.Empty(t, env1.AllVars())
+	assert.Empty(t, env2.AllVars())
+}
+
+func TestSnapshotRestoreCanRestoreADifferentEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "foo")
+	env2 := envish.NewLocalEnv()
+	env2.Setenv("PARAM2", "bar")
+
+	snap := envish.TakeSnapshot(env1)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := snap.Restore(env2)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"PARAM1=foo"}, env2.AllVars())
+}
+
+func TestSnapshotRestoreReturnsErrorIfSetenvFails(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	snap := envish.TakeSnapshot(env)
+
+	env.Setenv("PARAM1", "changed")
+	env.SetReadOnly("PARAM1")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := snap.Restore(env)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+}
+
+func TestSnapshotRestoreCopesWithNilPointers(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var snap *envish.Snapshot
+	expectedError := envish.ErrNilPointer{"Snapshot.Restore"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := snap.Restore(envish.NewLocalEnv())
+	err2 := envish.TakeSnapshot(envish.NewLocalEnv()).Restore(nil)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err1)
+	assert.Equal(t, expectedError, err2)
+	assert.Empty(t, snap.Environ())
+	assert.Empty(t, snap.AllVars())
+	assert.Empty(t, snap.MatchVarNames(""))
+	assert.Equal(t, "", snap.Getenv("PARAM1"))
+	assert.False(t, snap.IsExporter())
+	assert.False(t, snap.IsExported("PARAM1"))
+}
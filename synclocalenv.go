@@ -0,0 +1,646 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"sync"
+)
+
+// SyncLocalEnv is a LocalEnv that is safe to share between goroutines.
+//
+// LocalEnv updates its internal lookup table even when you only read
+// from it, so you cannot safely share one between goroutines. Use a
+// SyncLocalEnv instead.
+type SyncLocalEnv struct {
+	// mu guards every access to env
+	mu sync.RWMutex
+
+	// env is the environment store that we are protecting
+	env LocalEnv
+}
+
+// ================================================================
+//
+// Constructors
+//
+// ----------------------------------------------------------------
+
+// NewSyncLocalEnv creates an empty environment store that is safe for
+// concurrent use.
+//
+// It accepts the same functional options as NewLocalEnv.
+func NewSyncLocalEnv(options ...func(*LocalEnv)) *SyncLocalEnv {
+	retval := SyncLocalEnv{
+		env: *NewLocalEnv(options...),
+	}
+
+	// all done
+	return &retval
+}
+
+// ================================================================
+//
+// Reader interface
+//
+// ----------------------------------------------------------------
+
+// Environ returns a copy of all entries in the form "key=value".
+// This is compatible with any Golang standard library, such as `os/exec`.
+//
+// It includes variables that haven't been exported. Use ExportedVars if
+// you only want the exported ones.
+//
+// Unlike LocalEnv.Environ, the returned slice is always a copy, so that
+// it is safe to use after other goroutines have changed the store.
+func (e *SyncLocalEnv) Environ() []string {
+	// do we have an environment store to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.AllVars()
+}
+
+// Getenv returns the value of the variable named by the key.
+//
+// If the key is not found, an empty string is returned.
+func (e *SyncLocalEnv) Getenv(key string) string {
+	value, _ := e.LookupEnv(key)
+	return value
+}
+
+// IsExporter returns true if this backing store holds variables that
+// should be exported to external programs.
+func (e *SyncLocalEnv) IsExporter() bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.IsExporter()
+}
+
+// IsCaseInsensitive returns true if keys that only differ by case refer
+// to the same variable.
+func (e *SyncLocalEnv) IsCaseInsensitive() bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.IsCaseInsensitive()
+}
+
+// LookupEnv returns the value of the variable named by the key.
+//
+// If the key is not found, an empty string is returned, and the returned
+// boolean is false.
+func (e *SyncLocalEnv) LookupEnv(key string) (string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return "", false
+	}
+
+	// yes we do
+	//
+	// we must not update the lookup table while we only hold a read lock
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.peekEnv(key)
+}
+
+// MatchVarNames returns a list of variable names that start with the
+// given prefix.
+//
+// It's a feature needed for `${!prefix*}` string expansion syntax.
+func (e *SyncLocalEnv) MatchVarNames(prefix string) []string {
+	// do we have an environment store to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.MatchVarNames(prefix)
+}
+
+// ================================================================
+//
+// Writer interface
+//
+// ----------------------------------------------------------------
+
+// Clearenv deletes all entries from the given SyncLocalEnv. The program's
+// environment remains unchanged.
+func (e *SyncLocalEnv) Clearenv() {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.env.Clearenv()
+}
+
+// Setenv sets the value of the variable named by the key. The program's
+// environment remains unchanged.
+func (e *SyncLocalEnv) Setenv(key, value string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"SyncLocalEnv.Setenv"}
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.env.Setenv(key, value)
+}
+
+// Unsetenv deletes the variable named by the key.
+func (e *SyncLocalEnv) Unsetenv(key string) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.env.Unsetenv(key)
+}
+
+// ================================================================
+//
+// Expander interface
+//
+// ----------------------------------------------------------------
+
+// Expand replaces ${var} or $var in the input string.
+//
+// Each variable is looked up (or assigned, for `${var:=word}`) under its
+// own lock. Another goroutine can change the store part-way through an
+// expansion.
+//
+// Internally, it uses https://github.com/ganbarodigital/go_shellexpand
+// to do the expansion.
+func (e *SyncLocalEnv) Expand(fmt string) string {
+	return expand(e, fmt)
+}
+
+// ExpandE replaces ${var} or $var in the input string. It returns an
+// error if the input string cannot be expanded.
+//
+// It has the same locking behaviour as Expand.
+func (e *SyncLocalEnv) ExpandE(fmt string) (string, error) {
+	return expandE(e, fmt)
+}
+
+// ExpandWith works like ExpandE. Use the options to choose what
+// counts as an error.
+func (e *SyncLocalEnv) ExpandWith(fmt string, options ExpandOptions) (string, error) {
+	return expandWith(e, fmt, options)
+}
+
+// ================================================================
+//
+// Exported variables
+//
+// ----------------------------------------------------------------
+
+// AllVars returns a copy of all entries in the form "key=value", whether
+// they have been exported or not.
+func (e *SyncLocalEnv) AllVars() []string {
+	// do we have an environment store to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.AllVars()
+}
+
+// Export emulates UNIX shell `export XXX` behaviour. The variable named
+// by the key will be included in the output of ExportedVars (and of
+// OverlayEnv.Environ).
+func (e *SyncLocalEnv) Export(key string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"SyncLocalEnv.Export"}
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.env.Export(key)
+}
+
+// IsExported returns true if the variable named by the key will be
+// included in the output of ExportedVars (once it is set).
+func (e *SyncLocalEnv) IsExported(key string) bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.IsExported(key)
+}
+
+// Unexport emulates UNIX shell `export -n XXX` behaviour. The variable
+// named by the key will be left out of the output of ExportedVars (and
+// of OverlayEnv.Environ).
+func (e *SyncLocalEnv) Unexport(key string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"SyncLocalEnv.Unexport"}
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.env.Unexport(key)
+}
+
+// ExportedVars returns a copy of all exported entries in the form
+// "key=value".
+func (e *SyncLocalEnv) ExportedVars() []string {
+	// do we have an environment store to work with?
+	if e == nil {
+		return []string{}
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	pairs := e.env.exportedPairs()
+	retval := make([]string, len(pairs))
+	copy(retval, pairs)
+	return retval
+}
+
+// isInEnviron returns true if Environ includes the variable named by
+// the key (once it is set). It always does, whether the variable has
+// been exported or not.
+func (e *SyncLocalEnv) isInEnviron(key string) bool {
+	return true
+}
+
+// ================================================================
+//
+// Read-only variables
+//
+// ----------------------------------------------------------------
+
+// IsReadOnly returns true if the variable named by the key has been
+// marked as read-only.
+func (e *SyncLocalEnv) IsReadOnly(key string) bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.IsReadOnly(key)
+}
+
+// SetReadOnly emulates UNIX shell `readonly XXX` behaviour. Once it has
+// been called, the variable named by the key cannot be changed or deleted.
+func (e *SyncLocalEnv) SetReadOnly(key string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"SyncLocalEnv.SetReadOnly"}
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.env.SetReadOnly(key)
+}
+
+// ================================================================
+//
+// Arrays
+//
+// ----------------------------------------------------------------
+
+// GetArray returns a copy of the values held in the array named by the
+// key, just like `${arr[@]}` in bash.
+//
+// It returns false if the key does not name an array.
+func (e *SyncLocalEnv) GetArray(key string) ([]string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return nil, false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.GetArray(key)
+}
+
+// GetAssoc returns a copy of the array named by the key, as a map of
+// index to value.
+//
+// It returns false if the key does not name an array.
+func (e *SyncLocalEnv) GetAssoc(key string) (map[string]string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return nil, false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.GetAssoc(key)
+}
+
+// GetElement returns the value of a single element of the array named
+// by the key, just like `${arr[index]}` in bash.
+//
+// It returns false if the array, or the element, does not exist.
+func (e *SyncLocalEnv) GetElement(key, index string) (string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return "", false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.GetElement(key, index)
+}
+
+// IsArray returns true if the key names an indexed or associative array.
+func (e *SyncLocalEnv) IsArray(key string) bool {
+	// do we have an environment store to work with?
+	if e == nil {
+		return false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.IsArray(key)
+}
+
+// SetArray emulates bash's `arr=(value1 value2 ...)` behaviour. It
+// replaces the variable named by the key with an indexed array that
+// holds the given values.
+func (e *SyncLocalEnv) SetArray(key string, values ...string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"SyncLocalEnv.SetArray"}
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.env.SetArray(key, values...)
+}
+
+// SetAssoc emulates bash's `declare -A map=(...)` behaviour. It replaces
+// the variable named by the key with an associative array that holds a
+// copy of the given values.
+func (e *SyncLocalEnv) SetAssoc(key string, values map[string]string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"SyncLocalEnv.SetAssoc"}
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.env.SetAssoc(key, values)
+}
+
+// SetElement emulates bash's `arr[index]=value` behaviour.
+func (e *SyncLocalEnv) SetElement(key, index, value string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return ErrNilPointer{"SyncLocalEnv.SetElement"}
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.env.SetElement(key, index, value)
+}
+
+// UnsetElement emulates bash's `unset arr[index]` behaviour. It deletes
+// a single element from the array named by the key.
+func (e *SyncLocalEnv) UnsetElement(key, index string) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.env.UnsetElement(key, index)
+}
+
+// ================================================================
+//
+// Internal helpers
+//
+// ----------------------------------------------------------------
+
+// Length returns the number of key/value pairs stored in the SyncLocalEnv.
+func (e *SyncLocalEnv) Length() int {
+	// do we have an environment store to work with?
+	if e == nil {
+		return 0
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.Length()
+}
+
+// findUpperKey returns the name of a variable whose key is the same as
+// the given key, once they are both in upper case
+func (e *SyncLocalEnv) findUpperKey(key string) (string, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return key, false
+	}
+
+	// yes we do
+	//
+	// we need a write lock, because the LocalEnv may build its
+	// lookup table
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.env.findUpperKey(key)
+}
+
+// lookupArray returns a copy of the array named by the key
+func (e *SyncLocalEnv) lookupArray(key string) (arrayVar, bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return arrayVar{}, false
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	arr, ok := e.env.lookupArray(key)
+	if !ok {
+		return arrayVar{}, false
+	}
+
+	return arr.clone(), true
+}
+
+// checkKey returns an error if the key cannot be used as the name of
+// a new variable
+func (e *SyncLocalEnv) checkKey(key string) error {
+	// do we have an environment store to work with?
+	if e == nil {
+		return checkKeyOf(nil, key)
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.checkKey(key)
+}
+
+// copyArrays returns a copy of every array variable, for Snapshot to use
+func (e *SyncLocalEnv) copyArrays() map[string]arrayVar {
+	// do we have an environment store to work with?
+	if e == nil {
+		return nil
+	}
+
+	// yes we do
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.env.copyArrays()
+}
+
+// restoreArrays replaces every array variable with a copy of the given
+// arrays, for Snapshot to use
+func (e *SyncLocalEnv) restoreArrays(arrays map[string]arrayVar) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.env.restoreArrays(arrays)
+}
+
+// holdHooks queues up any change events, instead of passing them on
+func (e *SyncLocalEnv) holdHooks(q *heldEvents) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.env.holdHooks(q)
+}
+
+// releaseHooks passes on (if deliver is true) or throws away (if deliver
+// is false) any change events that have been queued up
+func (e *SyncLocalEnv) releaseHooks(deliver bool) {
+	// do we have an environment store to work with?
+	if e == nil {
+		return
+	}
+
+	// yes we do
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.env.releaseHooks(deliver)
+}
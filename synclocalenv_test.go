@@ -0,0 +1,557 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// Constructors
+//
+// ----------------------------------------------------------------
+
+func TestNewSyncLocalEnvReturnsAnEmptyEnvironmentStore(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env := envish.NewSyncLocalEnv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Empty(t, env.Environ())
+	assert.Equal(t, 0, env.Length())
+}
+
+func TestNewSyncLocalEnvRunsAnySuppliedOptionFunctions(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	op := func(e *envish.LocalEnv) {
+		e.Setenv("TestNewSyncLocalEnv", "this is my value")
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env := envish.NewSyncLocalEnv(op, envish.SetAsExporter)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "this is my value", env.Getenv("TestNewSyncLocalEnv"))
+	assert.True(t, env.IsExporter())
+}
+
+// ================================================================
+//
+// Interface compatibility
+//
+// ----------------------------------------------------------------
+
+func TestSyncLocalEnvImplementsExpander(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	unit := envish.NewSyncLocalEnv()
+	var i interface{} = unit
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	_, ok := i.(envish.Expander)
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, ok)
+}
+
+// ================================================================
+//
+// Reader and Writer compatibility
+//
+// ----------------------------------------------------------------
+
+func TestSyncLocalEnvSetenvLookupEnvUnsetenvWorkTogether(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Setenv("PARAM1", "foo")
+	value1, ok1 := env.LookupEnv("PARAM1")
+	env.Unsetenv("PARAM1")
+	value2, ok2 := env.LookupEnv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.True(t, ok1)
+	assert.Equal(t, "foo", value1)
+	assert.False(t, ok2)
+	assert.Equal(t, "", value2)
+}
+
+func TestSyncLocalEnvSetenvReturnsErrorForZeroLengthKey(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Setenv("", "foo")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrEmptyKey{}, err)
+}
+
+func TestSyncLocalEnvEnvironReturnsACopy(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv(envish.SetAsExporter)
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	environ := env.Environ()
+	env.Unsetenv("PARAM1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM1=foo", "PARAM2=bar"}, environ)
+	assert.Equal(t, []string{"PARAM2=bar"}, env.Environ())
+}
+
+func TestSyncLocalEnvMatchVarNamesAndClearenvWorkTogether(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+	env.Setenv("OTHER", "baz")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	names := env.MatchVarNames("PARAM")
+	env.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM1", "PARAM2"}, names)
+	assert.Equal(t, 0, env.Length())
+}
+
+func TestSyncLocalEnvExpandUsesAndUpdatesTheStore(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := env.Expand("${PARAM1} ${PARAM2:=bar}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "foo bar", actualResult)
+	assert.Equal(t, "bar", env.Getenv("PARAM2"))
+}
+
+func TestSyncLocalEnvSupportsExportedVariables(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Export("PARAM1")
+	env.Export("PARAM2")
+	env.Unexport("PARAM2")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM1=foo"}, env.ExportedVars())
+	assert.Equal(t, []string{"PARAM1=foo", "PARAM2=bar"}, env.AllVars())
+	assert.Equal(t, []string{"PARAM1=foo", "PARAM2=bar"}, env.Environ())
+	assert.True(t, env.IsExported("PARAM1"))
+	assert.False(t, env.IsExported("PARAM2"))
+}
+
+func TestSyncLocalEnvSupportsReadOnlyVariables(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	expectedError := envish.ErrReadOnlyVar{Key: "PARAM1"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.SetReadOnly("PARAM1")
+	err := env.Setenv("PARAM1", "bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.True(t, env.IsReadOnly("PARAM1"))
+	assert.Equal(t, "foo", env.Getenv("PARAM1"))
+}
+
+func TestSyncLocalEnvCopesWithNilPointer(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var env *envish.SyncLocalEnv
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Setenv("PARAM1", "foo")
+	env.Unsetenv("PARAM1")
+	env.Clearenv()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrNilPointer{"SyncLocalEnv.Setenv"}, err)
+	assert.Equal(t, envish.ErrNilPointer{"SyncLocalEnv.SetReadOnly"}, env.SetReadOnly("PARAM1"))
+	assert.Equal(t, envish.ErrNilPointer{"SyncLocalEnv.Export"}, env.Export("PARAM1"))
+	assert.Equal(t, envish.ErrNilPointer{"SyncLocalEnv.Unexport"}, env.Unexport("PARAM1"))
+	assert.Empty(t, env.AllVars())
+	assert.False(t, env.IsReadOnly("PARAM1"))
+	assert.Empty(t, env.Environ())
+	assert.Equal(t, "", env.Getenv("PARAM1"))
+	assert.False(t, env.IsExporter())
+	assert.Empty(t, env.MatchVarNames("PARAM"))
+	assert.Equal(t, 0, env.Length())
+}
+
+func TestSyncLocalEnvCopesWithEmptyStruct(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.SyncLocalEnv{}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := env.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", env.Getenv("PARAM1"))
+}
+
+// ================================================================
+//
+// Concurrency
+//
+// these tests are only meaningful when run with `go test -race`
+//
+// ----------------------------------------------------------------
+
+func TestSyncLocalEnvSupportsConcurrentReaders(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+	for i := 0; i < 10; i++ {
+		env.Setenv(fmt.Sprintf("PARAM%d", i), fmt.Sprintf("value%d", i))
+	}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("PARAM%d", j%10)
+				assert.Equal(t, fmt.Sprintf("value%d", j%10), env.Getenv(key))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, 10, env.Length())
+}
+
+func TestSyncLocalEnvSupportsConcurrentReadersAndWriters(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+	env.Setenv("SHARED", "shared")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("PARAM%d", i)
+			for j := 0; j < 100; j++ {
+				env.Setenv(key, fmt.Sprintf("value%d", j))
+				env.Expand("${SHARED} ${" + key + "} ${DEFAULT:=default}")
+				env.Environ()
+				env.MatchVarNames("PARAM")
+				env.LookupEnv(key)
+				env.Unsetenv(key)
+				if j%50 == 0 {
+					env.Unsetenv("DEFAULT")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "shared", env.Getenv("SHARED"))
+	assert.Empty(t, env.MatchVarNames("PARAM"))
+}
+
+func TestSyncLocalEnvSupportsConcurrentClearenv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				env.Setenv(fmt.Sprintf("PARAM%d", i), "foo")
+				if j%10 == 0 {
+					env.Clearenv()
+				}
+				env.Expand("$PARAM1")
+				env.Environ()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.LessOrEqual(t, env.Length(), 10)
+}
+
+// ================================================================
+//
+// Arrays
+//
+// ----------------------------------------------------------------
+
+func TestSyncLocalEnvSupportsArrays(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.SetArray("ARR", "foo", "bar")
+	err2 := env.SetAssoc("MAP", map[string]string{"one": "1"})
+	err3 := env.SetElement("ARR", "2", "baz")
+	env.UnsetElement("ARR", "0")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err1)
+	assert.Nil(t, err2)
+	assert.Nil(t, err3)
+	assert.True(t, env.IsArray("ARR"))
+
+	actualValues, _ := env.GetArray("ARR")
+	assert.Equal(t, []string{"bar", "baz"}, actualValues)
+	actualMap, _ := env.GetAssoc("MAP")
+	assert.Equal(t, map[string]string{"one": "1"}, actualMap)
+	actualValue, _ := env.GetElement("ARR", "1")
+	assert.Equal(t, "bar", actualValue)
+
+	assert.Equal(t, "bar baz 1", env.Expand("${ARR[@]} ${MAP[one]}"))
+	assert.Equal(t, "new", env.Expand("${ARR[0]:=new}"))
+	actualValue, _ = env.GetElement("ARR", "0")
+	assert.Equal(t, "new", actualValue)
+}
+
+func TestSyncLocalEnvArraysCopeWithNilPointer(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var env *envish.SyncLocalEnv
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := env.SetArray("ARR", "foo")
+	err2 := env.SetAssoc("ARR", map[string]string{})
+	err3 := env.SetElement("ARR", "0", "foo")
+	env.UnsetElement("ARR", "0")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrNilPointer{"SyncLocalEnv.SetArray"}, err1)
+	assert.Equal(t, envish.ErrNilPointer{"SyncLocalEnv.SetAssoc"}, err2)
+	assert.Equal(t, envish.ErrNilPointer{"SyncLocalEnv.SetElement"}, err3)
+	assert.False(t, env.IsArray("ARR"))
+	_, ok := env.GetArray("ARR")
+	assert.False(t, ok)
+	_, ok = env.GetAssoc("ARR")
+	assert.False(t, ok)
+	_, ok = env.GetElement("ARR", "0")
+	assert.False(t, ok)
+}
+
+// ================================================================
+//
+// Case-insensitive keys
+//
+// ----------------------------------------------------------------
+
+func TestSyncLocalEnvSupportsCaseInsensitiveKeys(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewSyncLocalEnv(envish.CaseInsensitiveKeys)
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	env.Setenv("Path", "C:\\Windows")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.True(t, env.IsCaseInsensitive())
+	assert.Equal(t, "C:\\Windows", env.Getenv("PATH"))
+
+	var nilEnv *envish.SyncLocalEnv
+	assert.False(t, nilEnv.IsCaseInsensitive())
+}
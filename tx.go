@@ -0,0 +1,519 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish
+
+import (
+	"strings"
+)
+
+// Tx is a transaction on a LocalEnv or an OverlayEnv.
+//
+// Use LocalEnv.Begin or OverlayEnv.Begin to start one. Any changes that
+// you make through the Tx are kept inside the Tx, until you call Commit.
+// Reads through the Tx see those changes; reads through the underlying
+// environment do not.
+//
+// Call Rollback to throw the changes away.
+type Tx struct {
+	// env is the environment that we will change when we commit
+	env Expander
+
+	// ops is the list of changes, in the order they were made
+	ops []txOp
+
+	// changes is the current value of every variable that the Tx has
+	// changed, indexed by foldKey(key)
+	changes map[string]txChange
+
+	// cleared is true if Clearenv has been called
+	cleared bool
+
+	// done is true once Commit or Rollback has been called
+	done bool
+}
+
+// txOpKind tells us which Writer method to call when we commit
+type txOpKind int
+
+const (
+	txOpClearenv txOpKind = iota
+	txOpSetenv
+	txOpUnsetenv
+)
+
+// txOp is a single change to make when we commit
+type txOp struct {
+	kind  txOpKind
+	key   string
+	value string
+}
+
+// txChange is the value of a variable, as far as the Tx is concerned
+type txChange struct {
+	value string
+	isSet bool
+}
+
+// ================================================================
+//
+// Constructors
+//
+// ----------------------------------------------------------------
+
+// newTx starts a transaction on the given environment
+func newTx(env Expander) *Tx {
+	return &Tx{
+		env:     env,
+		changes: make(map[string]txChange),
+	}
+}
+
+// ================================================================
+//
+// Reader interface
+//
+// ----------------------------------------------------------------
+
+// Environ returns a copy of the entries that the underlying environment's
+// Environ would return, in the form "key=value", including any changes
+// made in this transaction.
+func (tx *Tx) Environ() []string {
+	// our return value
+	retval := []string{}
+
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return retval
+	}
+
+	// yes we do
+	for _, pair := range tx.AllVars() {
+		if isInEnvironOf(tx.env, GetKeyFromPair(pair)) {
+			retval = append(retval, pair)
+		}
+	}
+
+	// all done
+	return retval
+}
+
+// Getenv returns the value of the variable named by the key, including
+// any changes made in this transaction.
+//
+// If the key is not found, an empty string is returned.
+func (tx *Tx) Getenv(key string) string {
+	value, _ := tx.LookupEnv(key)
+	return value
+}
+
+// IsExporter returns whatever the underlying environment's IsExporter
+// method returns.
+func (tx *Tx) IsExporter() bool {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return false
+	}
+
+	// yes we do
+	return tx.env.IsExporter()
+}
+
+// LookupEnv returns the value of the variable named by the key, including
+// any changes made in this transaction.
+//
+// If the key is not found, an empty string is returned, and the returned
+// boolean is false.
+func (tx *Tx) LookupEnv(key string) (string, bool) {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return "", false
+	}
+
+	// have we changed this variable?
+	change, ok := tx.changes[tx.foldKey(key)]
+	if ok {
+		return change.value, change.isSet
+	}
+
+	// has everything been deleted?
+	if tx.cleared && !tx.IsReadOnly(key) {
+		return "", false
+	}
+
+	// no, so the underlying environment has the answer
+	return tx.env.LookupEnv(key)
+}
+
+// MatchVarNames returns a list of variable names that start with the
+// given prefix, including any changes made in this transaction.
+func (tx *Tx) MatchVarNames(prefix string) []string {
+	// our return value
+	retval := []string{}
+
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return retval
+	}
+
+	// yes we do
+	foldKey := keyFolderOf(tx.env)
+	for _, pair := range tx.AllVars() {
+		key := GetKeyFromPair(pair)
+		if strings.HasPrefix(foldKey(key), foldKey(prefix)) {
+			retval = append(retval, key)
+		}
+	}
+
+	// all done
+	return retval
+}
+
+// ================================================================
+//
+// Writer interface
+//
+// ----------------------------------------------------------------
+
+// Clearenv deletes all entries, as far as this transaction is concerned.
+// The underlying environment is not changed until you call Commit.
+func (tx *Tx) Clearenv() {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil || tx.done {
+		return
+	}
+
+	// yes we do
+	tx.ops = append(tx.ops, txOp{kind: txOpClearenv})
+	tx.changes = make(map[string]txChange)
+	tx.cleared = true
+}
+
+// Setenv sets the value of the variable named by the key, as far as this
+// transaction is concerned. The underlying environment is not changed
+// until you call Commit.
+//
+// It returns ErrEmptyKey, ErrInvalidKey, ErrNULInValue and ErrReadOnlyVar
+// straight away, instead of waiting until you call Commit. It checks the
+// key in the same way that the underlying environment's Setenv does.
+func (tx *Tx) Setenv(key, value string) error {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return ErrNilPointer{"Tx.Setenv"}
+	}
+
+	// is it still open?
+	if tx.done {
+		return ErrTxDone{}
+	}
+
+	// make sure we have a key and value that we can work with
+	err := checkKeyOf(tx.env, key)
+	if err != nil {
+		return err
+	}
+	err = checkValue(key, value)
+	if err != nil {
+		return err
+	}
+
+	// are we allowed to change it?
+	if tx.IsReadOnly(key) {
+		return ErrReadOnlyVar{Key: key}
+	}
+
+	// yes we are
+	tx.ops = append(tx.ops, txOp{kind: txOpSetenv, key: key, value: value})
+	tx.changes[tx.foldKey(key)] = txChange{value: value, isSet: true}
+
+	// all done
+	return nil
+}
+
+// Unsetenv deletes the variable named by the key, as far as this
+// transaction is concerned. The underlying environment is not changed
+// until you call Commit.
+func (tx *Tx) Unsetenv(key string) {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil || tx.done {
+		return
+	}
+
+	// are we allowed to delete it?
+	if tx.IsReadOnly(key) {
+		return
+	}
+
+	// yes we are
+	tx.ops = append(tx.ops, txOp{kind: txOpUnsetenv, key: key})
+	tx.changes[tx.foldKey(key)] = txChange{}
+}
+
+// ================================================================
+//
+// Expander interface
+//
+// ----------------------------------------------------------------
+
+// Expand replaces ${var} or $var in the input string, using the values
+// that this transaction can see.
+//
+// Any `${var:=word}` assignments are kept inside the transaction.
+func (tx *Tx) Expand(fmt string) string {
+	return expand(tx, fmt)
+}
+
+// ExpandE works like Expand, except that it returns an error if the
+// input string cannot be expanded.
+func (tx *Tx) ExpandE(fmt string) (string, error) {
+	return expandE(tx, fmt)
+}
+
+// ExpandWith works like ExpandE. Use the options to choose what
+// counts as an error.
+func (tx *Tx) ExpandWith(fmt string, options ExpandOptions) (string, error) {
+	return expandWith(tx, fmt, options)
+}
+
+// ================================================================
+//
+// ExportChecker interface
+//
+// ----------------------------------------------------------------
+
+// AllVars returns a copy of all entries in the form "key=value", whether
+// they have been exported or not, including any changes made in this
+// transaction.
+func (tx *Tx) AllVars() []string {
+	// our return value
+	retval := []string{}
+
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return retval
+	}
+
+	// start with what the underlying environment has
+	seen := make(map[string]bool)
+	for _, pair := range allVarsOf(tx.env) {
+		key := GetKeyFromPair(pair)
+		seen[tx.foldKey(key)] = true
+
+		value, ok := tx.LookupEnv(key)
+		if ok {
+			retval = append(retval, key+"="+value)
+		}
+	}
+
+	// add anything that we have created
+	for _, op := range tx.ops {
+		if op.kind != txOpSetenv || seen[tx.foldKey(op.key)] {
+			continue
+		}
+		seen[tx.foldKey(op.key)] = true
+
+		value, ok := tx.LookupEnv(op.key)
+		if ok {
+			retval = append(retval, op.key+"="+value)
+		}
+	}
+
+	// all done
+	return retval
+}
+
+// IsExported returns true if the underlying environment will export the
+// variable named by the key.
+func (tx *Tx) IsExported(key string) bool {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return false
+	}
+
+	// yes we do
+	return isExportedBy(tx.env, key)
+}
+
+// IsReadOnly returns true if the variable named by the key is read-only
+// in the underlying environment.
+func (tx *Tx) IsReadOnly(key string) bool {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return false
+	}
+
+	// yes we do
+	checker, ok := tx.env.(ReadOnlyChecker)
+	return ok && checker.IsReadOnly(key)
+}
+
+// ================================================================
+//
+// Struct-unique functions
+//
+// ----------------------------------------------------------------
+
+// Commit makes all of the transaction's changes to the underlying
+// environment, in the order that they were made.
+//
+// If any change fails, Commit puts the underlying environment back the
+// way it was, and returns the error. Either every change is made, or
+// none of them are.
+//
+// Any functions registered with OnChange are only called once every
+// change has been made. If the commit fails, they are not called at all.
+//
+// Once Commit has been called, the Tx cannot be changed any more.
+func (tx *Tx) Commit() error {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return ErrNilPointer{"Tx.Commit"}
+	}
+
+	// is it still open?
+	if tx.done {
+		return ErrTxDone{}
+	}
+	tx.done = true
+
+	// we may need to undo everything
+	//
+	// a Snapshot of an OverlayEnv puts each variable back into the
+	// right environment
+	snap := TakeSnapshot(tx.env)
+
+	// no-one must hear about changes that we end up undoing
+	holder, ok := tx.env.(hookHolder)
+	if ok {
+		holder.holdHooks(nil)
+	}
+
+	err := tx.commitOps()
+	if err != nil {
+		// best effort; the original error is the one that matters
+		snap.Restore(tx.env)
+	}
+
+	if ok {
+		holder.releaseHooks(err == nil)
+	}
+
+	// all done
+	return err
+}
+
+// Rollback throws away all of the transaction's changes. The underlying
+// environment is not changed.
+//
+// Once Rollback has been called, the Tx cannot be changed any more.
+func (tx *Tx) Rollback() {
+	// do we have a transaction to work with?
+	if tx == nil {
+		return
+	}
+
+	// yes we do
+	tx.ops = nil
+	tx.changes = make(map[string]txChange)
+	tx.cleared = false
+	tx.done = true
+}
+
+// ================================================================
+//
+// Internal helpers
+//
+// ----------------------------------------------------------------
+
+// lookupArray returns the array named by the key, from the underlying
+// environment
+//
+// arrays cannot be changed through a Tx, so they are hidden once the
+// Tx has changed a variable with the same name
+func (tx *Tx) lookupArray(key string) (arrayVar, bool) {
+	// do we have a transaction to work with?
+	if tx == nil || tx.env == nil {
+		return arrayVar{}, false
+	}
+
+	// have we changed this variable?
+	_, ok := tx.changes[tx.foldKey(key)]
+	if ok {
+		return arrayVar{}, false
+	}
+
+	// has everything been deleted?
+	if tx.cleared && !tx.IsReadOnly(key) {
+		return arrayVar{}, false
+	}
+
+	// does the underlying environment support arrays?
+	reader, ok := tx.env.(arrayReader)
+	if !ok {
+		return arrayVar{}, false
+	}
+
+	// yes it does
+	return reader.lookupArray(key)
+}
+
+// commitOps makes each of the transaction's changes to the underlying
+// environment, stopping at the first one that fails
+func (tx *Tx) commitOps() error {
+	for _, op := range tx.ops {
+		switch op.kind {
+		case txOpClearenv:
+			tx.env.Clearenv()
+		case txOpSetenv:
+			err := tx.env.Setenv(op.key, op.value)
+			if err != nil {
+				return err
+			}
+		case txOpUnsetenv:
+			tx.env.Unsetenv(op.key)
+		}
+	}
+
+	// all done
+	return nil
+}
+
+// foldKey returns the key that we use to index tx.changes
+//
+// it follows the underlying environment, so that keys that only differ
+// by case are the same variable if the environment ignores case
+func (tx *Tx) foldKey(key string) string {
+	return keyFolderOf(tx.env)(key)
+}
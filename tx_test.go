@@ -0,0 +1,583 @@
+// Envish is a library to help you emulate UNIX-like program environments
+// in Golang packages
+//
+// Copyright 2019-present Ganbaro Digital Ltd
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+//   * Redistributions of source code must retain the above copyright
+//     notice, this list of conditions and the following disclaimer.
+//
+//   * Redistributions in binary form must reproduce the above copyright
+//     notice, this list of conditions and the following disclaimer in
+//     the documentation and/or other materials provided with the
+//     distribution.
+//
+//   * Neither the names of the copyright holders nor the names of his
+//     contributors may be used to endorse or promote products derived
+//     from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package envish_test
+
+import (
+	"testing"
+
+	envish "github.com/ganbarodigital/go_envish/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ================================================================
+//
+// Reads and writes
+//
+// ----------------------------------------------------------------
+
+func TestTxSeesItsOwnWrites(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.SetAsExporter)
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+
+	tx := env.Begin()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	tx.Setenv("PARAM1", "changed")
+	tx.Unsetenv("PARAM2")
+	tx.Setenv("PARAM3", "new")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "changed", tx.Getenv("PARAM1"))
+	_, ok := tx.LookupEnv("PARAM2")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"PARAM1=changed", "PARAM3=new"}, tx.Environ())
+	assert.Equal(t, []string{"PARAM1", "PARAM3"}, tx.MatchVarNames("PARAM"))
+
+	// the LocalEnv has not changed yet
+	assert.Equal(t, []string{"PARAM1=foo", "PARAM2=bar"}, env.Environ())
+}
+
+func TestTxClearenvHidesEverything(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+	env.SetReadOnly("PARAM2")
+
+	tx := env.Begin()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	tx.Clearenv()
+	tx.Setenv("PARAM3", "new")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM2=bar", "PARAM3=new"}, tx.AllVars())
+	assert.Equal(t, 2, env.Length())
+}
+
+func TestTxExpandKeepsAssignmentsInTheTransaction(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	tx := env.Begin()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	actualResult := tx.Expand("${PARAM1} ${PARAM2:=bar}")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "foo bar", actualResult)
+	assert.Equal(t, "bar", tx.Getenv("PARAM2"))
+	_, ok := env.LookupEnv("PARAM2")
+	assert.False(t, ok)
+}
+
+func TestTxSetenvReturnsErrorsStraightAway(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetReadOnly("PARAM1")
+
+	tx := env.Begin()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := tx.Setenv(" ", "foo")
+	err2 := tx.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrEmptyKey{}, err1)
+	assert.Equal(t, envish.ErrReadOnlyVar{Key: "PARAM1"}, err2)
+}
+
+func TestTxSetenvChecksKeysAndValuesLikeTheEnvironment(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.WithKeyPolicy(envish.PortableKeys))
+	tx := env.Begin()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := tx.Setenv("BAD-KEY", "foo")
+	err2 := tx.Setenv("PARAM1", "foo\x00bar")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrInvalidKey{Key: "BAD-KEY", Reason: "name must only contain letters, digits and underscores"}, err1)
+	assert.Equal(t, envish.ErrNULInValue{Key: "PARAM1"}, err2)
+	assert.Empty(t, tx.AllVars())
+}
+
+func TestTxIgnoresCaseIfTheEnvironmentDoes(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv(envish.CaseInsensitiveKeys)
+	env.Setenv("Path", "/usr/bin")
+	env.Setenv("PARAM1", "foo")
+
+	tx := env.Begin()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	tx.Setenv("PATH", "/bin")
+	tx.Unsetenv("param1")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "/bin", tx.Getenv("path"))
+	_, ok := tx.LookupEnv("PARAM1")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"Path=/bin"}, tx.AllVars())
+	assert.Equal(t, []string{"Path"}, tx.MatchVarNames("pa"))
+}
+
+// ================================================================
+//
+// Commit and Rollback
+//
+// ----------------------------------------------------------------
+
+func TestTxCommitAppliesEveryChangeInOrder(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+	env.Setenv("PARAM2", "bar")
+
+	tx := env.Begin()
+	tx.Setenv("PARAM1", "changed")
+	tx.Unsetenv("PARAM2")
+	tx.Setenv("PARAM3", "new")
+
+	expectedResult := []string{"PARAM1=changed", "PARAM3=new"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := tx.Commit()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResult, env.AllVars())
+}
+
+func TestTxRollbackThrowsTheChangesAway(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	tx := env.Begin()
+	tx.Setenv("PARAM1", "changed")
+	tx.Clearenv()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	tx.Rollback()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, []string{"PARAM1=foo"}, env.AllVars())
+	assert.Equal(t, envish.ErrTxDone{}, tx.Setenv("PARAM1", "bar"))
+	assert.Equal(t, envish.ErrTxDone{}, tx.Commit())
+	assert.Equal(t, []string{"PARAM1=foo"}, env.AllVars())
+}
+
+func TestTxCommitCanOnlyBeCalledOnce(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	tx := env.Begin()
+	tx.Setenv("PARAM1", "foo")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := tx.Commit()
+	err2 := tx.Commit()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err1)
+	assert.Equal(t, envish.ErrTxDone{}, err2)
+}
+
+func TestTxCommitOnOverlayEnvIsAllOrNothing(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "foo")
+	env2 := &brokenExporter{}
+	env2.LocalEnv.Setenv("PARAM2", "bar")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	tx := stack.Begin()
+	tx.Setenv("PARAM1", "changed")
+	tx.Unsetenv("PARAM1")
+	tx.Setenv("PARAM3", "new")
+	tx.Setenv("PARAM2", "changed")
+
+	expectedError := errBrokenExporter{"brokenExporter.Setenv"}
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := tx.Commit()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, []string{"PARAM1=foo"}, env1.AllVars())
+	assert.Equal(t, "bar", env2.Getenv("PARAM2"))
+}
+
+func TestTxCommitOnOverlayEnvRoutesWritesLikeSetenv(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	env2.Setenv("PARAM1", "foo")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	tx := stack.Begin()
+	tx.Setenv("PARAM1", "changed")
+	tx.Setenv("PARAM2", "new")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := tx.Commit()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"PARAM2=new"}, env1.AllVars())
+	assert.Equal(t, []string{"PARAM1=changed"}, env2.AllVars())
+}
+
+func TestTxCommitOnlyCallsOnChangeOnceEveryChangeHasBeenMade(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.Setenv("PARAM1", "foo")
+
+	var events []envish.ChangeEvent
+	env.OnChange(func(ev envish.ChangeEvent) {
+		// every change must have been made by now
+		assert.Equal(t, "new", env.Getenv("PARAM2"))
+		events = append(events, ev)
+	})
+
+	tx := env.Begin()
+	tx.Setenv("PARAM1", "changed")
+	tx.Setenv("PARAM2", "new")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := tx.Commit()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		[]envish.ChangeEvent{
+			{Op: envish.ChangeSet, Key: "PARAM1", OldValue: "foo", WasSet: true, NewValue: "changed"},
+			{Op: envish.ChangeSet, Key: "PARAM2", NewValue: "new"},
+		},
+		events,
+	)
+}
+
+func TestTxCommitDoesNotCallOnChangeIfTheCommitFails(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env1.Setenv("PARAM1", "foo")
+	env2 := &brokenExporter{}
+	env2.LocalEnv.Setenv("PARAM2", "bar")
+
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	var events []envish.ChangeEvent
+	recordEvent := func(ev envish.ChangeEvent) {
+		events = append(events, ev)
+	}
+	stack.OnChange(recordEvent)
+	env1.OnChange(recordEvent)
+
+	tx := stack.Begin()
+	tx.Setenv("PARAM1", "changed")
+	tx.Setenv("PARAM3", "new")
+	tx.Setenv("PARAM2", "changed")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := tx.Commit()
+
+	// the environments must still call OnChange after a failed commit
+	env1.Setenv("PARAM4", "after")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, errBrokenExporter{"brokenExporter.Setenv"}, err)
+	assert.Equal(t, []string{"PARAM1=foo", "PARAM4=after"}, env1.AllVars())
+	assert.Equal(
+		t,
+		[]envish.ChangeEvent{
+			{Op: envish.ChangeSet, Key: "PARAM4", NewValue: "after"},
+		},
+		events,
+	)
+}
+
+func TestTxCommitCallsOnChangeInTheOrderThatChangesWereMade(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := envish.NewLocalEnv()
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	var events []string
+	stack.OnChange(func(ev envish.ChangeEvent) {
+		events = append(events, "stack "+ev.Key)
+	})
+	env1.OnChange(func(ev envish.ChangeEvent) {
+		events = append(events, "env1 "+ev.Key)
+	})
+
+	tx := stack.Begin()
+	tx.Setenv("PARAM1", "foo")
+	tx.Setenv("PARAM2", "bar")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := tx.Commit()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"env1 PARAM1",
+			"stack PARAM1",
+			"env1 PARAM2",
+			"stack PARAM2",
+		},
+		events,
+	)
+}
+
+func TestTxCommitCopesWithTransactionsInsideTheCommit(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env1 := envish.NewLocalEnv()
+	env2 := &mirroringEnv{mirror: env1}
+	env2.LocalEnv.Setenv("PARAM2", "foo")
+	stack := envish.NewOverlayEnv([]envish.Expander{env1, env2})
+
+	var events []envish.ChangeEvent
+	env1.OnChange(func(ev envish.ChangeEvent) {
+		// every change must have been made by now
+		assert.Equal(t, "changed", stack.Getenv("PARAM2"))
+		events = append(events, ev)
+	})
+
+	tx := stack.Begin()
+	tx.Setenv("PARAM1", "new")
+	tx.Setenv("PARAM2", "changed")
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err := tx.Commit()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		[]envish.ChangeEvent{
+			{Op: envish.ChangeSet, Key: "PARAM1", NewValue: "new"},
+			{Op: envish.ChangeSet, Key: "MIRRORED_PARAM2", NewValue: "changed"},
+		},
+		events,
+	)
+}
+
+func TestTxCopesWithNilPointer(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	var env *envish.LocalEnv
+	tx := env.Begin()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	err1 := tx.Setenv("PARAM1", "foo")
+	err2 := tx.Commit()
+	tx.Unsetenv("PARAM1")
+	tx.Clearenv()
+	tx.Rollback()
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, envish.ErrNilPointer{"Tx.Setenv"}, err1)
+	assert.Equal(t, envish.ErrNilPointer{"Tx.Commit"}, err2)
+	assert.Empty(t, tx.Environ())
+	assert.Empty(t, tx.AllVars())
+	assert.Empty(t, tx.MatchVarNames(""))
+	assert.False(t, tx.IsExporter())
+}
+
+// ================================================================
+//
+// Arrays
+//
+// ----------------------------------------------------------------
+
+func TestTxExpandSeesArraysUntilTheyAreChanged(t *testing.T) {
+	t.Parallel()
+
+	// ----------------------------------------------------------------
+	// setup your test
+
+	env := envish.NewLocalEnv()
+	env.SetArray("ARR1", "foo", "bar")
+	env.SetArray("ARR2", "foo", "bar")
+
+	tx := env.Begin()
+
+	// ----------------------------------------------------------------
+	// perform the change
+
+	tx.Setenv("ARR2", "changed")
+
+	// ----------------------------------------------------------------
+	// test the results
+
+	assert.Equal(t, "foo bar changed", tx.Expand("${ARR1[@]} ${ARR2[@]}"))
+}